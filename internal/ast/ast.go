@@ -1,6 +1,10 @@
 package ast
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/Daemon0x00000000/sel/internal/vm"
+)
 
 type AST struct {
 	root Node
@@ -10,11 +14,22 @@ func (ast *AST) String() string {
 	return treeString(ast.root, "", true)
 }
 
-func (ast *AST) Compile() ([]byte, error) {
+// Compile turns the AST into a program whose globals are the fields the
+// expression references and whose natives are the functions it calls
+func (ast *AST) Compile() (*vm.Program, error) {
 	if ast.root == nil {
 		return nil, fmt.Errorf("cannot compile AST with nil root")
 	}
-	return ast.root.compile()
+	c := newCompiler()
+	bytecode, err := ast.root.compile(c)
+	if err != nil {
+		return nil, err
+	}
+	program := c.program(bytecode)
+	if err := program.Verify(); err != nil {
+		return nil, fmt.Errorf("invalid bytecode: %w", err)
+	}
+	return program, nil
 }
 
 func newAST() *AST {
@@ -0,0 +1,145 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse_Aggregate(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{"COUNT(tags)>3", "COUNT(tags) > 3"},
+		{"SUM(line_totals)>=500", "SUM(line_totals) >= 500"},
+		{"AVG(scores)<2.5", "AVG(scores) < 2.5"},
+		{"MIN(prices)*2>MAX(limits)", "MIN(prices) * 2 > MAX(limits)"},
+		{"COUNT(SPLIT(path, '/'))=3", "COUNT(SPLIT(path, '/')) = 3"},
+		{"a INSUM(b)", "a IN SUM(b)"},
+		{"LEN(name)>COUNT(tags)", "LEN(name) > COUNT(tags)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			ast, err := Parse(tt.expr)
+			assertNoError(t, err)
+			if !strings.Contains(ast.String(), tt.expected) {
+				t.Errorf("AST %q does not contain %q", ast.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestParse_AggregateErrors(t *testing.T) {
+	tests := []struct {
+		expr        string
+		errContains string
+	}{
+		{"COUNT()>1", "COUNT expects 1 argument, got 0 at position 0"},
+		{"SUM(a, b)>1", "SUM expects 1 argument at position 0"},
+		{"MAX(LEN(a))>1", "MAX at position 0 expects an array, LEN(a) is integer"},
+		{"AVG('x')>1", "AVG at position 0 expects an array, 'x' is string"},
+		{"a INCOUNT(b)", "IN at position 4 expects an array, COUNT returns integer"},
+		{"LEN(COUNT(a))>1", "LEN argument 1 expects string, got integer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			assertError(t, err)
+			if !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("expected error containing %q, got: %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestAST_Compile_Aggregate(t *testing.T) {
+	program := compileProgram(t, "SUM(totals)>=500")
+
+	listing, err := program.Disassemble()
+	assertNoError(t, err)
+	expected := `0000  LOAD_GLOBAL "totals"
+0003  OP_SUM
+0004  PUSH INT16 500
+0009  OP_GTE
+`
+	if listing != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", listing, expected)
+	}
+	if program.MaxStack() != 2 {
+		t.Errorf("expected max stack 2, got %d", program.MaxStack())
+	}
+	assertNoError(t, program.Verify())
+}
+
+func TestIntegration_Aggregate(t *testing.T) {
+	record := map[string]interface{}{
+		"tags":        []interface{}{"vip", "urgent", "network", "emea"},
+		"line_totals": []interface{}{250, 199.5, "50.5"},
+		"scores":      []interface{}{int8(4), uint16(2)},
+		"empty":       []interface{}{},
+		"path":        "a/b/c",
+		"code":        "b",
+	}
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"COUNT(tags)>3", true},
+		{"COUNT(empty)=0", true},
+		{"SUM(line_totals)>=500", true},
+		{"SUM(empty)=0", true},
+		{"AVG(scores)=3", true},
+		{"MIN(scores)=2", true},
+		{"MAX(tags)=vip", true},
+		{"MAX(scores)-MIN(scores)=2", true},
+		{"COUNT(SPLIT(path, '/'))=3", true},
+		{"code INSPLIT(path, '/')", true},
+		{"COUNT(empty)>0^AVG(empty)>1", false},
+		{"!COUNT(tags)>3", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			testParseCompileEval(t, tt.expr, record, tt.expected)
+
+			ast, err := Parse(tt.expr)
+			assertNoError(t, err)
+			explanation, err := ast.Explain(record)
+			assertNoError(t, err)
+			if explanation.Result != tt.expected {
+				t.Errorf("Explain(%q) = %v, want %v", tt.expr, explanation.Result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIntegration_AggregateErrors(t *testing.T) {
+	record := map[string]interface{}{"empty": []interface{}{}, "mixed": []interface{}{1, true}, "name": "john"}
+	tests := []struct {
+		expr        string
+		errContains string
+	}{
+		{"AVG(empty)>1", "AVG of an empty array"},
+		{"SUM(mixed)>1", "SUM requires numbers, element 1 is BOOL"},
+		{"COUNT(name)>1", "COUNT requires an array, got STRING"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			program := compileProgram(t, tt.expr)
+			machine := program.NewVM()
+			assertNoError(t, machine.LoadRecords(record))
+			if err := machine.Execute(); err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Execute: expected error containing %q, got %v", tt.errContains, err)
+			}
+
+			ast, err := Parse(tt.expr)
+			assertNoError(t, err)
+			if _, err := ast.Explain(record); err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Explain: expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package ast
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestTokenize_Arithmetic(t *testing.T) {
+	tokens, err := tokenize("(end - start)*2<=3600^a%b INx", nil)
+	assertNoError(t, err)
+
+	var got []string
+	for _, tok := range tokens {
+		got = append(got, tok.Value)
+	}
+	expected := []string{"(", "end", "-", "start", ")", "*", "2", "<=", "3600", "^", "a", "%", "b", "IN", "x", ""}
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %q\nwant %q", got, expected)
+	}
+	if tokens[2].Type != TOKEN_ARITH || tokens[6].Type != TOKEN_FIELD {
+		t.Errorf("unexpected token types: %+v", tokens)
+	}
+}
+
+func TestParse_Arithmetic(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{"price*quantity>1000", "price * quantity > 1000"},
+		{"end-start<=3600", "end - start <= 3600"},
+		{"a+b*c=7", "a + b * c = 7"},
+		{"a*b+c=7", "a * b + c = 7"},
+		{"(a+b)*c=7", "(a + b) * c = 7"},
+		{"a-(b-c)=7", "a - (b - c) = 7"},
+		{"a-b-c=7", "a - b - c = 7"},
+		{"a/b%c=1", "a / b % c = 1"},
+		{"a / (b * c) = 1", "a / (b * c) = 1"},
+		{"-a+1=0", "0 - a + 1 = 0"},
+		{"a*-2>1", "a * -2 > 1"},
+		{"2*price>=1.5", "2 * price >= 1.5"},
+		{"total%2INb", "total % 2 IN [b]"},
+		{"((a+b))*2>1", "(a + b) * 2 > 1"},
+		{"(a+1>2^b=1)", "a + 1 > 2"},
+		{"LEN(name)*2>10", "LEN(name) * 2 > 10"},
+		{"SUBSTR(code, LEN(code)-2)=01", "SUBSTR(code, LEN(code) - 2) = 01"},
+		{"123=x", "123 = x"}, // seul, un identifiant numérique reste un champ
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			ast, err := Parse(tt.expr)
+			assertNoError(t, err)
+			if !strings.Contains(ast.String(), tt.expected) {
+				t.Errorf("AST %q does not contain %q", ast.String(), tt.expected)
+			}
+		})
+	}
+
+	ast, err := Parse("123=x")
+	assertNoError(t, err)
+	if _, ok := ast.root.(*ComparisonNode).left.(Field); !ok {
+		t.Error("expected field 123")
+	}
+}
+
+func TestParse_ArithmeticErrors(t *testing.T) {
+	tests := []struct {
+		expr        string
+		errContains string
+	}{
+		{"a+=1", "missing field before operator"},
+		{"a+*b=1", "expected field, number or function, got arithmetic operator at position 2"},
+		{"a*=1", "missing field before operator"},
+		{"a+1", `no comparison operator found after field "1"`},
+		{"*a=1", "expected field, number or function"},
+		{"'x'=1", "expected field or function at position 0"},
+		{"a+'x'=1", "+ at position 1 requires numbers, got 'x'"},
+		{"a*SPLIT(b, ',')=1", "* at position 1 requires numbers, SPLIT(b, ',') is array"},
+		{"(a+b=1", "unbalanced parentheses"},
+		{"(a+b))=1", "unbalanced parentheses"},
+		{"a+(b=1)", "missing field before operator at position 4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			assertError(t, err)
+			if !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("expected error containing %q, got: %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestAST_Compile_Arithmetic(t *testing.T) {
+	program := compileProgram(t, "a+b*2>10")
+
+	listing, err := program.Disassemble()
+	assertNoError(t, err)
+	expected := `0000  LOAD_GLOBAL "a"
+0003  LOAD_GLOBAL "b"
+0006  PUSH INT8 2
+0010  OP_MUL
+0011  OP_ADD
+0012  PUSH INT8 10
+0016  OP_GT
+`
+	if listing != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", listing, expected)
+	}
+	if program.MaxStack() != 3 {
+		t.Errorf("expected max stack 3, got %d", program.MaxStack())
+	}
+	assertNoError(t, program.Verify())
+}
+
+func TestIntegration_Arithmetic(t *testing.T) {
+	record := map[string]interface{}{
+		"price":    19.99,
+		"quantity": uint16(60),
+		"start":    int64(1700000000),
+		"end":      int32(1700003000),
+		"small":    int8(-128),
+		"big":      uint64(math.MaxUint64),
+		"text":     "42",
+	}
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"price*quantity>1000", true},
+		{"price*quantity>1200", false},
+		{"end-start<=3600", true},
+		{"start-end=-3000", true},
+		{"small-1=-129", true},
+		{"small*small=16384", true},
+		{"big-1=18446744073709551614", true},
+		{"big/quantity>1", true},
+		{"quantity%7=4", true},
+		{"quantity/7=8", true},
+		{"text+1=43", true},
+		{"(quantity+4)/8=8", true},
+		{"quantity+4/8=60", true},
+		{"!price*2<40", false},
+		{"LEN(text)*10=20", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			testParseCompileEval(t, tt.expr, record, tt.expected)
+
+			ast, err := Parse(tt.expr)
+			assertNoError(t, err)
+			explanation, err := ast.Explain(record)
+			assertNoError(t, err)
+			if explanation.Result != tt.expected {
+				t.Errorf("Explain(%q) = %v, want %v", tt.expr, explanation.Result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIntegration_ArithmeticErrors(t *testing.T) {
+	record := map[string]interface{}{"a": int64(math.MaxInt64), "zero": 0, "name": "john"}
+	tests := []struct {
+		expr        string
+		errContains string
+	}{
+		{"a*3>0", "integer overflow"},
+		{"a/zero>0", "division by zero"},
+		{"a%zero>0", "division by zero"},
+		{"name+1>0", `+ requires numbers, got string "john"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			program := compileProgram(t, tt.expr)
+			machine := program.NewVM()
+			assertNoError(t, machine.LoadRecords(record))
+			if err := machine.Execute(); err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Execute: expected error containing %q, got %v", tt.errContains, err)
+			}
+
+			ast, err := Parse(tt.expr)
+			assertNoError(t, err)
+			if _, err := ast.Explain(record); err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Explain: expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestAST_Explain_Arithmetic(t *testing.T) {
+	ast, err := Parse("price*quantity>100")
+	assertNoError(t, err)
+	explanation, err := ast.Explain(map[string]interface{}{"price": 10, "quantity": 12})
+	assertNoError(t, err)
+
+	expected := "└── price * quantity > 100 → true (price * quantity: 120)\n"
+	if explanation.String() != expected {
+		t.Errorf("got %q, want %q", explanation.String(), expected)
+	}
+}
@@ -0,0 +1,283 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Daemon0x00000000/sel/internal/vm"
+)
+
+// Helper pour créer un registre avec LOWER(s) et CONCAT(a, b, ...)
+func testFunctions(t *testing.T) *vm.Functions {
+	t.Helper()
+	funcs := vm.NewFunctions()
+	assertNoError(t, funcs.Register(vm.Function{Name: "LOWER", MinArgs: 1, MaxArgs: 1, Func: func(args []vm.Value) (vm.Value, error) {
+		return vm.StringValue(strings.ToLower(args[0].String())), nil
+	}}))
+	assertNoError(t, funcs.Register(vm.Function{Name: "CONCAT", MinArgs: 2, MaxArgs: -1, Func: func(args []vm.Value) (vm.Value, error) {
+		var sb strings.Builder
+		for _, arg := range args {
+			sb.WriteString(arg.String())
+		}
+		return vm.StringValue(sb.String()), nil
+	}}))
+	return funcs
+}
+
+func TestTokenize_Calls(t *testing.T) {
+	funcs := testFunctions(t)
+	tokens, err := tokenize("LOWER(email)ENDSWITH@corp.com^CONCAT(a, 'x,y', 12)=b", funcs)
+	assertNoError(t, err)
+
+	var got []string
+	for _, tok := range tokens {
+		got = append(got, tok.Type.String()+":"+tok.Value)
+	}
+	expected := []string{
+		"function:LOWER", "'(':(", "field:email", "')':)", "operator:ENDSWITH", "value:@corp.com",
+		"logical operator:^",
+		"function:CONCAT", "'(':(", "field:a", "',':,", "value:x,y", "',':,", "field:12", "')':)",
+		"operator:=", "value:b", "end of expression:",
+	}
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %v\nwant %v", got, expected)
+	}
+
+	// sans registre, LOWER( n'est pas un appel
+	if _, err := tokenize("LOWER(email)=x", nil); err == nil {
+		t.Error("expected error without registry")
+	}
+}
+
+func TestParse_Calls(t *testing.T) {
+	funcs := testFunctions(t)
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{"LOWER(email)ENDSWITH@corp.com", "LOWER(email) ENDSWITH @corp.com"},
+		{"LOWER( name )=john", "LOWER(name) = john"},
+		{"CONCAT(first,' ',last)=John Doe", "CONCAT(first, ' ', last) = John Doe"},
+		{"CONCAT(LOWER(a),LOWER(b), 1.5)=xy", "CONCAT(LOWER(a), LOWER(b), 1.5) = xy"},
+		{"!LOWER(role)INadmin,root", "LOWER(role) IN [admin root]"},
+		{"(LOWER(a)=x^LOWER_CASE=y)", "LOWER(a) = x"},
+		{"name=LOWER(other)", "name = LOWER(other)"},
+		{"LOWER(a)!=LOWER( b )", "LOWER(a) = LOWER(b)"},
+		{"x=LOWER('A')^y=1", "x = LOWER('A')"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			ast, err := ParseWith(tt.expr, funcs)
+			assertNoError(t, err)
+			if !strings.Contains(ast.String(), tt.expected) {
+				t.Errorf("AST %q does not contain %q", ast.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestParse_CallErrors(t *testing.T) {
+	funcs := testFunctions(t)
+	tests := []struct {
+		expr        string
+		errContains string
+	}{
+		{"LOWER()=x", "LOWER expects 1 arguments, got 0 at position 0"},
+		{"a=1^LOWER(a,b)=x", "LOWER expects 1 arguments, got 2 at position 4"},
+		{"CONCAT(a)=x", "CONCAT expects at least 2 arguments, got 1"},
+		{"UPPER(a)=x", `unknown function "UPPER" at position 0`},
+		{"a=1^FOO(email)=1", `unknown function "FOO" at position 4`},
+		{"LOWER(FOO(a))=x", `unknown function "FOO" at position 6`},
+		{"LOWER(a)+FOO(b)>1", `unknown function "FOO" at position 9`},
+		{"user.name(a)=x", `unexpected '(' after field "user.name" at position 9`},
+		{"LOWER(a)", "expected operator, got end of expression"},
+		{"LOWER(a) x", "expected comparison operator"},
+		{"x=LOWER(a),b", "unexpected character ','"},
+		{"x=LOWER(a)b", "unexpected character 'b'"},
+		{"LOWER(a,)=x", "argument of LOWER: expected field, number or function, got ')'"},
+		{"LOWER(^a)=x", "unexpected character '^' at position 6"},
+		{"LOWER(john doe)=x", "expected ')', got field at position 11"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseWith(tt.expr, funcs)
+			assertError(t, err)
+			if !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("expected error containing %q, got: %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestAST_Compile_Calls(t *testing.T) {
+	ast, err := ParseWith("LOWER(a)=x^CONCAT(LOWER(b),'-',a)=y-x", testFunctions(t))
+	assertNoError(t, err)
+	program, err := ast.Compile()
+	assertNoError(t, err)
+
+	// LOWER n'apparaît qu'une fois dans la table des fonctions
+	var names []string
+	for _, fn := range program.Natives() {
+		names = append(names, fn.Name)
+	}
+	if strings.Join(names, ",") != "LOWER,CONCAT" {
+		t.Errorf("expected natives [LOWER CONCAT], got %v", names)
+	}
+	if strings.Join(program.Fields(), ",") != "a,b" {
+		t.Errorf("expected fields [a b], got %v", program.Fields())
+	}
+	// b, LOWER(b), '-', a
+	if program.MaxStack() != 3 {
+		t.Errorf("expected max stack 3, got %d", program.MaxStack())
+	}
+	assertNoError(t, program.Verify())
+}
+
+func TestIntegration_Calls(t *testing.T) {
+	funcs := testFunctions(t)
+	record := map[string]interface{}{"email": "John@Corp.com", "first": "John", "last": "Doe", "n": 7}
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"LOWER(email)ENDSWITH@corp.com", true},
+		{"email ENDSWITH @corp.com", false},
+		{"LOWER(email)=john@corp.com^CONCAT(first,' ',last)=John Doe", true},
+		{"CONCAT(first,n)=John7", true},
+		{"CONCAT(LOWER(first),LOWER(last))INjohndoe,janedoe", true},
+		{"!LOWER(first)=john", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			ast, err := ParseWith(tt.expr, funcs)
+			assertNoError(t, err)
+			program, err := ast.Compile()
+			assertNoError(t, err)
+			if result := executeInVM(t, program, record); result != tt.expected {
+				t.Errorf("Eval(%q) = %v, want %v", tt.expr, result, tt.expected)
+			}
+
+			explanation, err := ast.Explain(record)
+			assertNoError(t, err)
+			if explanation.Result != tt.expected {
+				t.Errorf("Explain(%q) = %v, want %v", tt.expr, explanation.Result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAST_Explain_Calls(t *testing.T) {
+	ast, err := ParseWith("LOWER(email)ENDSWITH@corp.com", testFunctions(t))
+	assertNoError(t, err)
+	explanation, err := ast.Explain(map[string]interface{}{"email": "John@Corp.com"})
+	assertNoError(t, err)
+
+	expected := "└── LOWER(email) ENDSWITH @corp.com → true (LOWER(email): \"john@corp.com\")\n"
+	if explanation.String() != expected {
+		t.Errorf("got %q, want %q", explanation.String(), expected)
+	}
+}
+
+func TestParse_Builtins(t *testing.T) {
+	valid := []string{
+		"LOWER(TRIM(email))ENDSWITH@corp.com",
+		"LEN(name)>=3",
+		"SUBSTR(code, 0, 2)INFR,BE",
+		"REPLACE(phone, ' ', '')STARTSWITH+33",
+		"roleINSPLIT(roles, ',')",
+		"SPLIT(tags, ',')INvip,gold",
+		"UPPER(a)=UPPER(b)",
+	}
+	for _, expr := range valid {
+		t.Run(expr, func(t *testing.T) {
+			program := compileProgram(t, expr)
+			assertNoError(t, program.Verify())
+		})
+	}
+
+	invalid := []struct {
+		expr        string
+		errContains string
+	}{
+		{"LEN(12)=2", "LEN argument 1 expects string, got integer at position 0"},
+		{"SUBSTR(code, '1')=x", "SUBSTR argument 2 expects integer, got string"},
+		{"SUBSTR(code, 1.5)=x", "SUBSTR argument 2 expects integer, got float"},
+		{"UPPER(LEN(name))=x", "UPPER argument 1 expects string, got integer"},
+		{"LOWER(SPLIT(tags, ','))=x", "LOWER argument 1 expects string, got array"},
+		{"roleINLOWER(roles)", "IN at position 6 expects an array, LOWER returns string"},
+		{"REPLACE(a, b)=x", "REPLACE expects 3 arguments, got 2"},
+		{"lower(a)=x", `unknown function "lower" at position 0`},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			assertError(t, err)
+			if !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("expected error containing %q, got: %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestIntegration_Builtins(t *testing.T) {
+	record := map[string]interface{}{
+		"email": " John@Corp.com ",
+		"code":  "FR-75",
+		"roles": "admin,dev",
+		"role":  "dev",
+		"n":     3,
+	}
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"LOWER(TRIM(email))=john@corp.com", true},
+		{"LEN(code)=5", true},
+		{"LEN(code)>5", false},
+		{"SUBSTR(code, 0, 2)INFR,BE", true},
+		{"roleINSPLIT(roles, ',')", true},
+		{"!roleINSPLIT(roles, ',')", false},
+		{"REPLACE(code, '-', '')=FR75", true},
+		{"role=SUBSTR(roles, 6)", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			testParseCompileEval(t, tt.expr, record, tt.expected)
+
+			ast, err := Parse(tt.expr)
+			assertNoError(t, err)
+			explanation, err := ast.Explain(record)
+			assertNoError(t, err)
+			if explanation.Result != tt.expected {
+				t.Errorf("Explain(%q) = %v, want %v", tt.expr, explanation.Result, tt.expected)
+			}
+		})
+	}
+
+	// le type des champs n'est connu qu'à l'exécution
+	program := compileProgram(t, "LEN(n)=1")
+	vmInstance := program.NewVM()
+	assertNoError(t, vmInstance.LoadRecords(record))
+	if err := vmInstance.Execute(); err == nil || !strings.Contains(err.Error(), "LEN argument 1 expects string, got INT8") {
+		t.Errorf("expected runtime type error, got %v", err)
+	}
+}
+
+func TestAST_Explain_RightCall(t *testing.T) {
+	ast, err := Parse("roleINSPLIT(roles, ',')")
+	assertNoError(t, err)
+	explanation, err := ast.Explain(map[string]interface{}{"role": "ops", "roles": "admin,dev"})
+	assertNoError(t, err)
+
+	expected := "└── role IN SPLIT(roles, ',') → false (role: \"ops\")\n"
+	if explanation.String() != expected {
+		t.Errorf("got %q, want %q", explanation.String(), expected)
+	}
+	if operand, ok := explanation.Operand.([]interface{}); !ok || len(operand) != 2 || operand[0] != "admin" {
+		t.Errorf("expected evaluated operand [admin dev], got %#v", explanation.Operand)
+	}
+}
@@ -210,8 +210,9 @@ func TestAST_Compile_BinaryOperatorStructure(t *testing.T) {
 	ast, err := Parse(expr)
 	assertNoError(t, err)
 
-	bytecode, err := ast.Compile()
+	program, err := ast.Compile()
 	assertNoError(t, err)
+	bytecode := program.Bytecode()
 
 	// Should contain opcodes for:
 	// 1. LOAD_GLOBAL a
@@ -239,8 +240,9 @@ func TestAST_Compile_UnaryNOTStructure(t *testing.T) {
 	ast, err := Parse(expr)
 	assertNoError(t, err)
 
-	bytecode, err := ast.Compile()
+	program, err := ast.Compile()
 	assertNoError(t, err)
+	bytecode := program.Bytecode()
 
 	// Should contain opcodes for:
 	// 1. LOAD_GLOBAL a
@@ -345,3 +347,43 @@ func TestAST_Compile_AllLogicalOperators(t *testing.T) {
 		})
 	}
 }
+
+func TestAST_Compile_FieldTable(t *testing.T) {
+	program := compileProgram(t, "status=active^priority<3^ORstatus=pending")
+
+	fields := program.Fields()
+	if len(fields) != 2 || fields[0] != "status" || fields[1] != "priority" {
+		t.Fatalf("expected field table [status priority], got %v", fields)
+	}
+
+	bytecode := program.Bytecode()
+	expected := vm.SerializeLoadGlobal(0)
+	for i, b := range expected {
+		if bytecode[i] != b {
+			t.Fatalf("bytecode should start with LOAD_GLOBAL 0, got %v", bytecode[:len(expected)])
+		}
+	}
+}
+
+func TestAST_Compile_MaxStack(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected int
+	}{
+		{"a=1", 2},
+		{"!(a=1)", 2},
+		{"a=1^b=2^c=3", 2}, // short-circuit: the left result is popped before the right side
+		{"a=1^ORb=2", 2},
+		{"a=1^XORb=2", 3},
+		{"a=1^XOR(b=2^XORc=3)", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			program := compileProgram(t, tt.expr)
+			if program.MaxStack() != tt.expected {
+				t.Errorf("MaxStack() = %d, want %d", program.MaxStack(), tt.expected)
+			}
+		})
+	}
+}
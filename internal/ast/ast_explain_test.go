@@ -0,0 +1,101 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAST_Explain_MatchesEval(t *testing.T) {
+	record := map[string]interface{}{
+		"status":   "active",
+		"age":      30,
+		"score":    12.5,
+		"role":     "user",
+		"name":     "John Doe",
+		"priority": int64(3),
+	}
+
+	exprs := []string{
+		"status=active",
+		"status!=active",
+		"age>18^score<10",
+		"age<18^ORscore>=12.5",
+		"roleINadmin,root^ORnameSTARTSWITHJohn",
+		"role!INadmin,root",
+		"status=active^XORage>40",
+		"!(status=active^age>18)",
+		"!!(nameCONTAINSDoe)",
+		"(age<18^ORrole=user)^(priority<=3^XORnameENDSWITHSmith)",
+	}
+
+	for _, expr := range exprs {
+		t.Run(expr, func(t *testing.T) {
+			ast, err := Parse(expr)
+			assertNoError(t, err)
+
+			explanation, err := ast.Explain(record)
+			assertNoError(t, err)
+
+			want := executeInVM(t, compileProgram(t, expr), record)
+			if explanation.Result != want {
+				t.Errorf("Explain result %v, Eval result %v\n%s", explanation.Result, want, explanation)
+			}
+		})
+	}
+}
+
+func TestAST_Explain_Tree(t *testing.T) {
+	ast, err := Parse("!(status=active)^XOR(age>18^ORname='')")
+	assertNoError(t, err)
+
+	explanation, err := ast.Explain(map[string]interface{}{"status": "active", "age": 30})
+	assertNoError(t, err)
+
+	expected := `└── ^XOR → true
+    ├── NOT → false
+    │   └── status = active → true (status: "active")
+    └── ^OR → true
+        ├── age > 18 → true (age: 30)
+        └── name =  → skipped
+`
+	if explanation.String() != expected {
+		t.Errorf("unexpected explanation:\n%s\nwant:\n%s", explanation, expected)
+	}
+
+	comparison := explanation.Children[1].Children[0]
+	if comparison.Field != "age" || comparison.Value != 30 || comparison.Operand != 18 {
+		t.Errorf("unexpected comparison details: %+v", comparison)
+	}
+	if skipped := explanation.Children[1].Children[1]; !skipped.Skipped || skipped.Value != nil {
+		t.Errorf("short-circuited comparison should be skipped without a value: %+v", skipped)
+	}
+}
+
+func TestAST_Explain_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		expr   string
+		data   map[string]interface{}
+		errMsg string
+	}{
+		{"missing field", "status=active", map[string]interface{}{}, "undefined global variable: status"},
+		{"unsupported type", "status=active", map[string]interface{}{"status": struct{}{}}, "unsupported type for field status"},
+		{"type mismatch", "nameSTARTSWITHa", map[string]interface{}{"name": 3}, "STARTSWITH requires string operands"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, err := Parse(tt.expr)
+			assertNoError(t, err)
+
+			_, err = ast.Explain(tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+
+	if _, err := (&AST{}).Explain(nil); err == nil {
+		t.Error("expected error explaining nil root")
+	}
+}
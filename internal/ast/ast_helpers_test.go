@@ -38,26 +38,37 @@ func assertBytecodeNotEmpty(t *testing.T, bytecode []byte) {
 	}
 }
 
-// compileAndCheck parse et compile une expression
-func compileAndCheck(t *testing.T, expr string) []byte {
+// compileProgram parse et compile une expression en programme
+func compileProgram(t *testing.T, expr string) *vm.Program {
 	t.Helper()
 
 	ast, err := Parse(expr)
 	assertNoError(t, err)
 	assertASTNotNil(t, ast)
 
-	bytecode, err := ast.Compile()
+	program, err := ast.Compile()
 	assertNoError(t, err)
-	assertBytecodeNotEmpty(t, bytecode)
+	assertBytecodeNotEmpty(t, program.Bytecode())
+
+	return program
+}
+
+// compileAndCheck parse et compile une expression, retourne le bytecode
+func compileAndCheck(t *testing.T, expr string) []byte {
+	t.Helper()
+	return compileProgram(t, expr).Bytecode()
+}
 
-	return bytecode
+// compileNode compile un noeud avec une table de champs vide
+func compileNode(node Node) ([]byte, error) {
+	return node.compile(newCompiler())
 }
 
-// executeInVM exécute du bytecode dans la VM avec les données fournies
-func executeInVM(t *testing.T, bytecode []byte, data map[string]interface{}) bool {
+// executeInVM exécute un programme dans la VM avec les données fournies
+func executeInVM(t *testing.T, program *vm.Program, data map[string]interface{}) bool {
 	t.Helper()
 
-	vmInstance := vm.NewVM(bytecode, nil)
+	vmInstance := program.NewVM()
 
 	// Convert map[string]interface{} to map[vm.Field]interface{}
 	vmData := make(map[string]interface{})
@@ -80,15 +91,15 @@ func executeInVM(t *testing.T, bytecode []byte, data map[string]interface{}) boo
 		t.Fatalf("expected bool on stack, got type %v", stack[0].Type)
 	}
 
-	return stack[0].Bool
+	return stack[0].Bool()
 }
 
 // testParseCompileEval teste le cycle complet pour une expression
 func testParseCompileEval(t *testing.T, expr string, data map[string]interface{}, expected bool) {
 	t.Helper()
 
-	bytecode := compileAndCheck(t, expr)
-	result := executeInVM(t, bytecode, data)
+	program := compileProgram(t, expr)
+	result := executeInVM(t, program, data)
 
 	if result != expected {
 		t.Errorf("Eval(%q, %v) = %v, want %v", expr, data, result, expected)
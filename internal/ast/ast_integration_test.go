@@ -1,6 +1,13 @@
 package ast
 
-import "testing"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Daemon0x00000000/sel/internal/vm"
+)
 
 func TestIntegration_SimpleEquals(t *testing.T) {
 	tests := []struct {
@@ -31,32 +38,32 @@ func TestIntegration_ComparisonOperators(t *testing.T) {
 		want bool
 	}{
 		// EQUALS
-		{"eq: match", "age=25", map[string]interface{}{"age": "25"}, true},
-		{"eq: no match", "age=25", map[string]interface{}{"age": "30"}, false},
+		{"eq: match", "age=25", map[string]interface{}{"age": 25}, true},
+		{"eq: no match", "age=25", map[string]interface{}{"age": 30}, false},
 
 		// NOT_EQUALS
-		{"neq: match", "age!=25", map[string]interface{}{"age": "30"}, true},
-		{"neq: no match", "age!=25", map[string]interface{}{"age": "25"}, false},
+		{"neq: match", "age!=25", map[string]interface{}{"age": 30}, true},
+		{"neq: no match", "age!=25", map[string]interface{}{"age": 25}, false},
 
 		// GREATER_THAN
-		{"gt: true", "age>25", map[string]interface{}{"age": "30"}, true},
-		{"gt: false", "age>25", map[string]interface{}{"age": "20"}, false},
-		{"gt: equal", "age>25", map[string]interface{}{"age": "25"}, false},
+		{"gt: true", "age>25", map[string]interface{}{"age": 30}, true},
+		{"gt: false", "age>25", map[string]interface{}{"age": 20}, false},
+		{"gt: equal", "age>25", map[string]interface{}{"age": 25}, false},
 
 		// LESS_THAN
-		{"lt: true", "age<25", map[string]interface{}{"age": "20"}, true},
-		{"lt: false", "age<25", map[string]interface{}{"age": "30"}, false},
-		{"lt: equal", "age<25", map[string]interface{}{"age": "25"}, false},
+		{"lt: true", "age<25", map[string]interface{}{"age": 20}, true},
+		{"lt: false", "age<25", map[string]interface{}{"age": 30}, false},
+		{"lt: equal", "age<25", map[string]interface{}{"age": 25}, false},
 
 		// GREATER_THAN_OR_EQUAL
-		{"gte: greater", "age>=25", map[string]interface{}{"age": "30"}, true},
-		{"gte: equal", "age>=25", map[string]interface{}{"age": "25"}, true},
-		{"gte: less", "age>=25", map[string]interface{}{"age": "20"}, false},
+		{"gte: greater", "age>=25", map[string]interface{}{"age": 30}, true},
+		{"gte: equal", "age>=25", map[string]interface{}{"age": 25}, true},
+		{"gte: less", "age>=25", map[string]interface{}{"age": 20}, false},
 
 		// LESS_THAN_OR_EQUAL
-		{"lte: less", "age<=25", map[string]interface{}{"age": "20"}, true},
-		{"lte: equal", "age<=25", map[string]interface{}{"age": "25"}, true},
-		{"lte: greater", "age<=25", map[string]interface{}{"age": "30"}, false},
+		{"lte: less", "age<=25", map[string]interface{}{"age": 20}, true},
+		{"lte: equal", "age<=25", map[string]interface{}{"age": 25}, true},
+		{"lte: greater", "age<=25", map[string]interface{}{"age": 30}, false},
 
 		// TODO: MATCHES operator not fully implemented yet - skipped for now
 	}
@@ -431,6 +438,133 @@ func TestIntegration_EdgeCases(t *testing.T) {
 			map[string]interface{}{"a": "1"},
 			true,
 		},
+		{
+			"uppercase field ending with IN",
+			"LINKSIN1,2",
+			map[string]interface{}{"LINKS": 2},
+			true,
+		},
+		{
+			"uppercase field containing IN",
+			"CONTAINER_IDIN1,2",
+			map[string]interface{}{"CONTAINER_ID": 1},
+			true,
+		},
+		{
+			"uppercase field before CONTAINS",
+			"TITLECONTAINSfoo",
+			map[string]interface{}{"TITLE": "a foo"},
+			true,
+		},
+		{
+			"uppercase field containing IN before CONTAINS",
+			"MAINCONTAINSx",
+			map[string]interface{}{"MAIN": "xyz"},
+			true,
+		},
+		{
+			"uppercase field starting with OR",
+			"a=1^ ORDER_ID=5",
+			map[string]interface{}{"a": 2, "ORDER_ID": 5},
+			false,
+		},
+		{
+			"OR before an uppercase field",
+			"a=1^OR STATUS=x",
+			map[string]interface{}{"a": 2, "STATUS": "x"},
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testParseCompileEval(t, tt.expr, tt.data, tt.want)
+		})
+	}
+}
+
+func TestIntegration_NumericLiterals(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		data map[string]interface{}
+		want bool
+	}{
+		// int records
+		{"int gt: true", "age>18", map[string]interface{}{"age": 25}, true},
+		{"int gt: false", "age>18", map[string]interface{}{"age": 12}, false},
+		{"int eq", "age=25", map[string]interface{}{"age": 25}, true},
+		{"int neq", "age!=25", map[string]interface{}{"age": 30}, true},
+		{"int negative", "delta<-5", map[string]interface{}{"delta": -10}, true},
+		{"int in", "priorityIN1,2,3", map[string]interface{}{"priority": 2}, true},
+		{"int not in", "priority!IN1,2,3", map[string]interface{}{"priority": 4}, true},
+		{"readme quick start", "status=active^age>18", map[string]interface{}{"status": "active", "age": 25}, true},
+
+		// numeric strings compare numerically
+		{"string gt: numeric", "age>9", map[string]interface{}{"age": "10"}, true},
+		{"string lt: numeric", "age<100", map[string]interface{}{"age": "20"}, true},
+		{"string eq: numeric", "code=7", map[string]interface{}{"code": "007"}, true},
+
+		// a leading zero keeps the literal a string
+		{"leading zeros: text", "zip=01234", map[string]interface{}{"zip": "1234"}, false},
+		{"leading zeros: same text", "zip=01234", map[string]interface{}{"zip": "01234"}, true},
+
+		// non numeric strings are never equal to a number
+		{"string eq: text", "code=123", map[string]interface{}{"code": "abc"}, false},
+		{"string neq: text", "code!=123", map[string]interface{}{"code": "abc"}, true},
+		{"string in: mixed list", "statusINa,1", map[string]interface{}{"status": "a"}, true},
+		{"string neq: nan is text", "price!=0", map[string]interface{}{"price": "NaN"}, true},
+
+		// quoted literals stay strings
+		{"quoted: lexicographic", "age>'9'", map[string]interface{}{"age": "10"}, false},
+
+		// string operators keep text literals
+		{"startswith digits", "codeSTARTSWITH12", map[string]interface{}{"code": "1234"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testParseCompileEval(t, tt.expr, tt.data, tt.want)
+		})
+	}
+}
+
+func TestIntegration_NumericLiteralErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		data map[string]interface{}
+	}{
+		{"age>18", map[string]interface{}{"age": "abc"}},
+		{"age<=18", map[string]interface{}{"age": ""}},
+		{"price>=1.5", map[string]interface{}{"price": "n/a"}},
+		{"price<1e9", map[string]interface{}{"price": "Inf"}},
+		{"price>0", map[string]interface{}{"price": "NaN"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			program := compileProgram(t, tt.expr)
+			machine := program.NewVM()
+			assertNoError(t, machine.LoadRecords(tt.data))
+			if err := machine.Execute(); err == nil || !strings.Contains(err.Error(), "with a number") {
+				t.Errorf("Execute: expected a comparison error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestIntegration_MixedIntegerWidths(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		data map[string]interface{}
+		want bool
+	}{
+		{"int8 record vs int16 literal", "count<300", map[string]interface{}{"count": 100}, true},
+		{"int16 record vs int8 literal", "count>100", map[string]interface{}{"count": 300}, true},
+		{"int32 record vs int16 literal", "count>=1000", map[string]interface{}{"count": 100000}, true},
+		{"int8 record in mixed list", "countIN1,300,100000", map[string]interface{}{"count": 1}, true},
+		{"int32 record in mixed list", "countIN1,300,100000", map[string]interface{}{"count": 100000}, true},
 	}
 
 	for _, tt := range tests {
@@ -439,3 +573,116 @@ func TestIntegration_EdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestIntegration_WideIntegers(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		data map[string]interface{}
+		want bool
+	}{
+		{"int above 2^31", "ts>1700000000000", map[string]interface{}{"ts": 1700000000001}, true},
+		{"int above 2^31 not truncated", "ts=1700000000000", map[string]interface{}{"ts": 1700000000000 + 1<<32}, false},
+		{"int64 record", "id=9007199254740993", map[string]interface{}{"id": int64(9007199254740993)}, true},
+		{"uint64 record", "id>9223372036854775807", map[string]interface{}{"id": uint64(1 << 63)}, true},
+		{"uint8 record", "level>=3", map[string]interface{}{"level": uint8(3)}, true},
+		{"uint vs negative literal", "count>-1", map[string]interface{}{"count": uint(0)}, true},
+		{"int32 record", "code<0", map[string]interface{}{"code": int32(-7)}, true},
+		{"numeric string above 2^31", "ts<1700000000000", map[string]interface{}{"ts": "1699999999999"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testParseCompileEval(t, tt.expr, tt.data, tt.want)
+		})
+	}
+}
+
+func TestIntegration_FloatingPoint(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		data map[string]interface{}
+		want bool
+	}{
+		{"float record vs decimal", "price<19.99", map[string]interface{}{"price": 9.5}, true},
+		{"float record vs decimal equal", "price=19.99", map[string]interface{}{"price": 19.99}, true},
+		{"float32 record", "ratio>0.25", map[string]interface{}{"ratio": float32(0.5)}, true},
+		{"float record vs int literal", "age>18", map[string]interface{}{"age": 25.0}, true},
+		{"int record vs decimal", "age>18.5", map[string]interface{}{"age": 18}, false},
+		{"int record vs decimal equal integral", "age=18.0", map[string]interface{}{"age": 18}, true},
+		{"float in int list", "countIN1,2,3", map[string]interface{}{"count": 2.0}, true},
+		{"numeric string vs decimal", "price<19.99", map[string]interface{}{"price": "5.25"}, true},
+		{"large int vs float", "id>9007199254740992.0", map[string]interface{}{"id": int64(9007199254740993)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testParseCompileEval(t, tt.expr, tt.data, tt.want)
+		})
+	}
+}
+
+func TestIntegration_JSONRecords(t *testing.T) {
+	var data map[string]interface{}
+	err := json.Unmarshal([]byte(`{"status":"active","age":25,"price":19.5,"tags":["a","b"]}`), &data)
+	assertNoError(t, err)
+
+	testParseCompileEval(t, "status=active^age>18^price<20^tagsINb", data, true)
+	testParseCompileEval(t, "age>=25.5", data, false)
+}
+
+func TestIntegration_ShortCircuit(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		data map[string]interface{}
+		want bool
+	}{
+		// the right side reads a missing field, it must not run
+		{"and: left false", "a=1^missing=2", map[string]interface{}{"a": "x"}, false},
+		{"or: left true", "a=1^ORmissing=2", map[string]interface{}{"a": "1"}, true},
+		{"nested: and inside or", "a=1^OR(b=2^missing=3)", map[string]interface{}{"a": "x", "b": "x"}, false},
+		{"nested: or inside and", "(a=1^ORmissing=2)^b=2", map[string]interface{}{"a": "1", "b": "2"}, true},
+		{"not: left decides", "!(a=1^missing=2)", map[string]interface{}{"a": "x"}, true},
+		{"chain: stops at first false", "a=1^b=2^missing=3", map[string]interface{}{"a": "1", "b": "x"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testParseCompileEval(t, tt.expr, tt.data, tt.want)
+		})
+	}
+}
+
+func TestIntegration_UnreferencedFields(t *testing.T) {
+	// only the fields used by the expression are converted
+	data := map[string]interface{}{
+		"status":  "active",
+		"payload": struct{ Raw []byte }{},
+		"meta":    map[string]interface{}{"k": "v"},
+	}
+	testParseCompileEval(t, "status=active", data, true)
+}
+
+func TestIntegration_LargeOperands(t *testing.T) {
+	ids := make([]string, 5000)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("INC%07d", i)
+	}
+	long := strings.Repeat("lorem ipsum ", 40)
+
+	testParseCompileEval(t, "numberIN"+strings.Join(ids, ","), map[string]interface{}{"number": "INC0004999"}, true)
+	testParseCompileEval(t, "numberIN"+strings.Join(ids, ","), map[string]interface{}{"number": "INC0005000"}, false)
+	testParseCompileEval(t, "description='"+long+"'", map[string]interface{}{"description": long}, true)
+	testParseCompileEval(t, "descriptionCONTAINS'"+long[12:]+"'", map[string]interface{}{"description": long + "!"}, true)
+}
+
+func TestIntegration_OperandTooLarge(t *testing.T) {
+	ast, err := Parse("description='" + strings.Repeat("x", vm.MaxOperandLength+1) + "'")
+	assertNoError(t, err)
+
+	if _, err := ast.Compile(); err == nil {
+		t.Fatal("expected compile error for a value above the length limit")
+	}
+}
@@ -0,0 +1,225 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+// lexLiterals tokenise une liste de valeurs et retourne les littéraux
+func lexLiterals(input string) ([]string, error) {
+	tokens, err := tokenize("fieldIN"+input, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	for _, tok := range tokens {
+		if tok.Type == TOKEN_LITERAL {
+			values = append(values, tok.Value)
+		}
+	}
+	return values, nil
+}
+
+func TestTokenize_TokenStream(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		expected []Token
+	}{
+		{
+			"simple comparison",
+			"status=active",
+			[]Token{
+				{Type: TOKEN_FIELD, Value: "status", Pos: 0},
+				{Type: TOKEN_OPERATOR, Value: "=", Pos: 6},
+				{Type: TOKEN_LITERAL, Value: "active", Pos: 7},
+				{Type: TOKEN_EOF, Pos: 13},
+			},
+		},
+		{
+			"negated keyword operator",
+			"status!INa,b",
+			[]Token{
+				{Type: TOKEN_FIELD, Value: "status", Pos: 0},
+				{Type: TOKEN_OPERATOR, Value: "!IN", Pos: 6},
+				{Type: TOKEN_LITERAL, Value: "a", Pos: 9},
+				{Type: TOKEN_COMMA, Value: ",", Pos: 10},
+				{Type: TOKEN_LITERAL, Value: "b", Pos: 11},
+				{Type: TOKEN_EOF, Pos: 12},
+			},
+		},
+		{
+			"logical and group",
+			"!(a=1)^ORb=2",
+			[]Token{
+				{Type: TOKEN_NOT, Value: "!", Pos: 0},
+				{Type: TOKEN_LPAREN, Value: "(", Pos: 1},
+				{Type: TOKEN_FIELD, Value: "a", Pos: 2},
+				{Type: TOKEN_OPERATOR, Value: "=", Pos: 3},
+				{Type: TOKEN_LITERAL, Value: "1", Pos: 4},
+				{Type: TOKEN_RPAREN, Value: ")", Pos: 5},
+				{Type: TOKEN_LOGICAL, Value: "^OR", Pos: 6},
+				{Type: TOKEN_FIELD, Value: "b", Pos: 9},
+				{Type: TOKEN_OPERATOR, Value: "=", Pos: 10},
+				{Type: TOKEN_LITERAL, Value: "2", Pos: 11},
+				{Type: TOKEN_EOF, Pos: 12},
+			},
+		},
+		{
+			"quoted literal",
+			"name='a^ORb'",
+			[]Token{
+				{Type: TOKEN_FIELD, Value: "name", Pos: 0},
+				{Type: TOKEN_OPERATOR, Value: "=", Pos: 4},
+				{Type: TOKEN_LITERAL, Value: "a^ORb", Pos: 5, Quoted: true},
+				{Type: TOKEN_EOF, Pos: 12},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := tokenize(tt.expr, nil)
+			assertNoError(t, err)
+
+			if len(tokens) != len(tt.expected) {
+				t.Fatalf("got %d tokens %+v, want %d", len(tokens), tokens, len(tt.expected))
+			}
+			for i, tok := range tokens {
+				if tok != tt.expected[i] {
+					t.Errorf("token[%d]: got %+v, want %+v", i, tok, tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTokenize_KeywordCollisions(t *testing.T) {
+	tests := []struct {
+		name  string
+		expr  string
+		field string
+		op    string
+		value string
+	}{
+		{"field starting with IN", "INDEX=5", "INDEX", "=", "5"},
+		{"field containing IN", "MAIN_INDEX>=5", "MAIN_INDEX", ">=", "5"},
+		{"lowercase keyword in field", "incident_state=2", "incident_state", "=", "2"},
+		{"glued keyword", "assignment_groupINa", "assignment_group", "IN", "a"},
+		{"glued keyword after uppercase field", "INDEXINa", "INDEX", "IN", "a"},
+		{"field containing keyword letters", "domainSTARTSWITHx", "domain", "STARTSWITH", "x"},
+		{"uppercase field ending with keyword letters", "LINKSINa", "LINKS", "IN", "a"},
+		{"uppercase field containing keyword letters", "CONTAINER_IDINa", "CONTAINER_ID", "IN", "a"},
+		{"keyword letters in value", "nameCONTAINSINDIA", "name", "CONTAINS", "INDIA"},
+		{"IN nested in CONTAINS", "TITLECONTAINSfoo", "TITLE", "CONTAINS", "foo"},
+		{"IN before CONTAINS", "MAINCONTAINSx", "MAIN", "CONTAINS", "x"},
+		{"IN nested in a keyword of the value", "NAMEINCONTAINS", "NAME", "IN", "CONTAINS"},
+		{"quoted value after uppercase field", "LINKSIN'a'", "LINKS", "IN", "a"},
+		{"spaced keyword", "name CONTAINS john", "name", "CONTAINS", "john"},
+		{"keyword in value", "status=INACTIVE", "status", "=", "INACTIVE"},
+		{"operator in quoted value", "field='xINy'", "field", "=", "xINy"},
+		{"value with symbols", "emailENDSWITH@example.com", "email", "ENDSWITH", "@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := tokenize(tt.expr, nil)
+			assertNoError(t, err)
+
+			if len(tokens) != 4 {
+				t.Fatalf("expected 4 tokens, got %+v", tokens)
+			}
+			if tokens[0].Type != TOKEN_FIELD || tokens[0].Value != tt.field {
+				t.Errorf("field: got %+v, want %q", tokens[0], tt.field)
+			}
+			if tokens[1].Type != TOKEN_OPERATOR || tokens[1].Value != tt.op {
+				t.Errorf("operator: got %+v, want %q", tokens[1], tt.op)
+			}
+			if tokens[2].Type != TOKEN_LITERAL || tokens[2].Value != tt.value {
+				t.Errorf("value: got %+v, want %q", tokens[2], tt.value)
+			}
+		})
+	}
+}
+
+func TestTokenize_LogicalBoundary(t *testing.T) {
+	tests := []struct {
+		expr    string
+		logical string
+		field   string
+	}{
+		{"a=1^ ORDER_ID=5", "^", "ORDER_ID"},
+		{"a=1^OR STATUS=x", "^OR", "STATUS"},
+		{"a=1^XOR _FLAG=5", "^XOR", "_FLAG"},
+		{"a=1^ORb=2", "^OR", "b"},
+		{"a=1^XOR(b=2)", "^XOR", "b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			tokens, err := tokenize(tt.expr, nil)
+			assertNoError(t, err)
+			var logical, field *Token
+			for i := range tokens {
+				switch {
+				case tokens[i].Type == TOKEN_LOGICAL:
+					logical = &tokens[i]
+				case tokens[i].Type == TOKEN_FIELD && logical != nil && field == nil:
+					field = &tokens[i]
+				}
+			}
+			if logical == nil || logical.Value != tt.logical {
+				t.Errorf("logical: got %+v, want %q", logical, tt.logical)
+			}
+			if field == nil || field.Value != tt.field {
+				t.Errorf("field: got %+v, want %q", field, tt.field)
+			}
+		})
+	}
+}
+
+func TestTokenize_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		expr        string
+		errContains string
+	}{
+		{"no operator", "field", "no comparison operator"},
+		{"no field", "=value", "missing field"},
+		{"double operator", "field==value", "double operator"},
+		{"unclosed quote", "field='value", "unclosed quote"},
+		{"incomplete escape", "field='value\\", "incomplete escape"},
+		{"garbage after group", "(a=1)b=2", "unexpected character"},
+		{"OR glued to an uppercase field", "a=1^ORSTATUS=x", "ambiguous ^OR at position 3"},
+		{"OR glued to a field starting with OR", "a=1^ORDER_ID=5", "ambiguous ^OR at position 3"},
+		{"XOR glued to a digit", "a=1^XOR2=5", "ambiguous ^XOR at position 3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tokenize(tt.expr, nil)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("error should contain %q, got: %v", tt.errContains, err)
+			}
+			if !strings.Contains(err.Error(), "position") {
+				t.Errorf("error should mention position, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestTokenize_ParensInValue(t *testing.T) {
+	tokens, err := tokenize("(name=foo(bar))", nil)
+	assertNoError(t, err)
+
+	if tokens[3].Type != TOKEN_LITERAL || tokens[3].Value != "foo(bar)" {
+		t.Errorf("expected literal foo(bar), got %+v", tokens[3])
+	}
+	if tokens[4].Type != TOKEN_RPAREN {
+		t.Errorf("expected closing paren, got %+v", tokens[4])
+	}
+}
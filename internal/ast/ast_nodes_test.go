@@ -9,7 +9,7 @@ import (
 
 func TestComparisonNode_String(t *testing.T) {
 	node := &ComparisonNode{
-		left:        "field",
+		left:        Field("field"),
 		right:       "value",
 		operator:    vm.OP_EQ,
 		operatorStr: EQUALS,
@@ -36,7 +36,7 @@ func TestComparisonNode_Compile(t *testing.T) {
 		{
 			"simple equals",
 			&ComparisonNode{
-				left:        "field",
+				left:        Field("field"),
 				right:       "value",
 				operator:    vm.OP_EQ,
 				operatorStr: EQUALS,
@@ -46,7 +46,7 @@ func TestComparisonNode_Compile(t *testing.T) {
 		{
 			"with array (IN)",
 			&ComparisonNode{
-				left:        "status",
+				left:        Field("status"),
 				right:       []interface{}{"a", "b", "c"},
 				operator:    vm.OP_IN,
 				operatorStr: IN,
@@ -57,7 +57,7 @@ func TestComparisonNode_Compile(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			bytecode, err := tt.node.compile()
+			bytecode, err := compileNode(tt.node)
 			assertNoError(t, err)
 
 			if len(bytecode) < tt.minBytes {
@@ -70,26 +70,30 @@ func TestComparisonNode_Compile(t *testing.T) {
 func TestLogicalNode_Compile(t *testing.T) {
 	// Create simple comparison nodes for testing
 	leftNode := &ComparisonNode{
-		left:        "a",
+		left:        Field("a"),
 		right:       "1",
 		operator:    vm.OP_EQ,
 		operatorStr: EQUALS,
 	}
 	rightNode := &ComparisonNode{
-		left:        "b",
+		left:        Field("b"),
 		right:       "2",
 		operator:    vm.OP_EQ,
 		operatorStr: EQUALS,
 	}
 
+	leftBytes, err := compileNode(leftNode)
+	assertNoError(t, err)
+
 	tests := []struct {
 		name     string
 		operator vm.OpCode
 		opStr    LogicalOperator
+		jump     vm.OpCode // short-circuit jump, 0 when both sides always run
 	}{
-		{"AND", vm.OP_AND, AND},
-		{"OR", vm.OP_OR, OR},
-		{"XOR", vm.OP_XOR, XOR},
+		{"AND", vm.OP_AND, AND, vm.JUMP_IF_FALSE_OR_POP},
+		{"OR", vm.OP_OR, OR, vm.JUMP_IF_TRUE_OR_POP},
+		{"XOR", vm.OP_XOR, XOR, 0},
 	}
 
 	for _, tt := range tests {
@@ -101,7 +105,7 @@ func TestLogicalNode_Compile(t *testing.T) {
 				operatorStr: tt.opStr,
 			}
 
-			bytecode, err := node.compile()
+			bytecode, err := compileNode(node)
 			assertNoError(t, err)
 
 			// Should have bytecode from both children plus the logical operator
@@ -109,6 +113,15 @@ func TestLogicalNode_Compile(t *testing.T) {
 				t.Errorf("bytecode too short: %d bytes", len(bytecode))
 			}
 
+			if tt.jump != 0 {
+				// The jump follows the left operand
+				jumpOpcode := vm.OpCode(bytecode[len(leftBytes)])
+				if jumpOpcode != tt.jump {
+					t.Errorf("expected jump %v after left operand, got %v", tt.jump, jumpOpcode)
+				}
+				return
+			}
+
 			// Last byte should be the logical operator
 			lastOpcode := vm.OpCode(bytecode[len(bytecode)-1])
 			if lastOpcode != tt.operator {
@@ -120,7 +133,7 @@ func TestLogicalNode_Compile(t *testing.T) {
 
 func TestNotNode_Compile(t *testing.T) {
 	operandNode := &ComparisonNode{
-		left:        "field",
+		left:        Field("field"),
 		right:       "value",
 		operator:    vm.OP_EQ,
 		operatorStr: EQUALS,
@@ -130,7 +143,7 @@ func TestNotNode_Compile(t *testing.T) {
 		operand: operandNode,
 	}
 
-	bytecode, err := node.compile()
+	bytecode, err := compileNode(node)
 	assertNoError(t, err)
 
 	// Should have operand bytecode plus OP_NOT
@@ -187,21 +200,21 @@ func TestLogicalNode_String(t *testing.T) {
 func TestNestedNodes(t *testing.T) {
 	// Test deeply nested logical operations
 	left := &ComparisonNode{
-		left:        "a",
+		left:        Field("a"),
 		right:       "1",
 		operator:    vm.OP_EQ,
 		operatorStr: EQUALS,
 	}
 
 	middle := &ComparisonNode{
-		left:        "b",
+		left:        Field("b"),
 		right:       "2",
 		operator:    vm.OP_EQ,
 		operatorStr: EQUALS,
 	}
 
 	right := &ComparisonNode{
-		left:        "c",
+		left:        Field("c"),
 		right:       "3",
 		operator:    vm.OP_EQ,
 		operatorStr: EQUALS,
@@ -222,7 +235,7 @@ func TestNestedNodes(t *testing.T) {
 		operatorStr: OR,
 	}
 
-	bytecode, err := outerNode.compile()
+	bytecode, err := compileNode(outerNode)
 	assertNoError(t, err)
 
 	// Should compile successfully with reasonable size
@@ -234,7 +247,7 @@ func TestNestedNodes(t *testing.T) {
 func TestNotNode_DoubleNegation(t *testing.T) {
 	// Test !(!(a=1))
 	innerComparison := &ComparisonNode{
-		left:        "a",
+		left:        Field("a"),
 		right:       "1",
 		operator:    vm.OP_EQ,
 		operatorStr: EQUALS,
@@ -248,7 +261,7 @@ func TestNotNode_DoubleNegation(t *testing.T) {
 		operand: innerNot,
 	}
 
-	bytecode, err := outerNot.compile()
+	bytecode, err := compileNode(outerNot)
 	assertNoError(t, err)
 
 	// Should have comparison + 2x OP_NOT
@@ -280,13 +293,13 @@ func TestComparisonNode_WithDifferentTypes(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			node := &ComparisonNode{
-				left:        "field",
+				left:        Field("field"),
 				right:       tt.right,
 				operator:    vm.OP_EQ,
 				operatorStr: EQUALS,
 			}
 
-			bytecode, err := node.compile()
+			bytecode, err := compileNode(node)
 			assertNoError(t, err)
 			assertBytecodeNotEmpty(t, bytecode)
 		})
@@ -296,21 +309,21 @@ func TestComparisonNode_WithDifferentTypes(t *testing.T) {
 func TestLogicalNode_AsymmetricChildren(t *testing.T) {
 	// Left is simple comparison, right is nested logical
 	leftNode := &ComparisonNode{
-		left:        "a",
+		left:        Field("a"),
 		right:       "1",
 		operator:    vm.OP_EQ,
 		operatorStr: EQUALS,
 	}
 
 	rightLeft := &ComparisonNode{
-		left:        "b",
+		left:        Field("b"),
 		right:       "2",
 		operator:    vm.OP_EQ,
 		operatorStr: EQUALS,
 	}
 
 	rightRight := &ComparisonNode{
-		left:        "c",
+		left:        Field("c"),
 		right:       "3",
 		operator:    vm.OP_EQ,
 		operatorStr: EQUALS,
@@ -331,7 +344,7 @@ func TestLogicalNode_AsymmetricChildren(t *testing.T) {
 		operatorStr: OR,
 	}
 
-	bytecode, err := node.compile()
+	bytecode, err := compileNode(node)
 	assertNoError(t, err)
 
 	// Should compile successfully
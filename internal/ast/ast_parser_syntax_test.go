@@ -250,3 +250,41 @@ func TestParse_ASTString(t *testing.T) {
 		})
 	}
 }
+
+func TestParse_LiteralTyping(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		expected interface{}
+	}{
+		{"integer", "age>18", 18},
+		{"negative integer", "delta>-5", -5},
+		{"int64", "created>1700000000000", int64(1700000000000)},
+		{"uint64", "id=18446744073709551615", uint64(18446744073709551615)},
+		{"beyond uint64", "id=18446744073709551616", 1.8446744073709552e19},
+		{"quoted integer", "age>'18'", "18"},
+		{"text", "status=active", "active"},
+		{"decimal", "price<19.99", 19.99},
+		{"negative decimal", "delta>-0.5", -0.5},
+		{"exponent", "size<1e3", 1e3},
+		{"version stays text", "version=1.2.3", "1.2.3"},
+		{"nan stays text", "value=NaN", "NaN"},
+		{"inf stays text", "value=Inf", "Inf"},
+		{"string operator", "codeSTARTSWITH12", "12"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, err := Parse(tt.expr)
+			assertNoError(t, err)
+
+			node, ok := ast.root.(*ComparisonNode)
+			if !ok {
+				t.Fatalf("expected ComparisonNode, got %T", ast.root)
+			}
+			if node.right != tt.expected {
+				t.Errorf("right = %#v, want %#v", node.right, tt.expected)
+			}
+		})
+	}
+}
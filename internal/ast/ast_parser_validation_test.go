@@ -18,6 +18,7 @@ func TestValidateParentheses_Balanced(t *testing.T) {
 		{"nested right", "(a=1^(b=2))"},
 		{"complex nested", "((a=1^b=2)^(c=3^d=4))"},
 		{"empty expr", ""},
+		{"parens in quotes", "field='(value'"},
 	}
 
 	for _, tt := range tests {
@@ -91,85 +92,6 @@ func TestValidateParentheses_ErrorMessages(t *testing.T) {
 	}
 }
 
-func TestStripOuterParens(t *testing.T) {
-	tests := []struct {
-		name     string
-		expr     string
-		expected string
-		stripped bool
-	}{
-		{"simple parens", "(a=1)", "a=1", true},
-		{"double parens", "((a=1))", "(a=1)", true},
-		{"no outer parens", "a=1", "a=1", false},
-		{"non-matching", "(a=1)^(b=2)", "(a=1)^(b=2)", false},
-		{"closing before end", "(a=1)^b=2", "(a=1)^b=2", false},
-		{"empty parens", "()", "", true},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result, stripped := stripOuterParens(tt.expr)
-			if result != tt.expected {
-				t.Errorf("stripOuterParens(%q) = %q, want %q", tt.expr, result, tt.expected)
-			}
-			if stripped != tt.stripped {
-				t.Errorf("stripOuterParens(%q) stripped = %v, want %v", tt.expr, stripped, tt.stripped)
-			}
-		})
-	}
-}
-
-func TestFindOperatorOutsideParens(t *testing.T) {
-	tests := []struct {
-		name     string
-		expr     string
-		operator string
-		expected int
-	}{
-		{"simple", "a=1^ORb=2", "^OR", 3},
-		{"not in parens", "(a=1^OR(b=2))^ORc=3", "^OR", 13},
-		{"at start", "^ORa=1", "^OR", 0},
-		{"not found", "a=1", "^OR", -1},
-		{"in parens only", "(a=1^ORb=2)", "^OR", -1},
-		{"multiple occurrences", "a=1^ORb=2^ORc=3", "^OR", 3},
-		{"nested parens", "((a=1)^ORb=2)^ORc=3", "^OR", 13},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := findOperatorOutsideParens(tt.expr, tt.operator)
-			if result != tt.expected {
-				t.Errorf("findOperatorOutsideParens(%q, %q) = %d, want %d",
-					tt.expr, tt.operator, result, tt.expected)
-			}
-		})
-	}
-}
-
-func TestFindOperatorOutsideParens_WithQuotes(t *testing.T) {
-	tests := []struct {
-		name     string
-		expr     string
-		operator string
-		expected int
-	}{
-		{"in quotes", "field='value^OR'", "^OR", -1},
-		{"after quotes", "field='value'^ORb=2", "^OR", 13},
-		{"before quotes", "a=1^ORfield='value'", "^OR", 3},
-		{"escaped quote", "field='it\\'s'^ORb=2", "^OR", 13},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := findOperatorOutsideParens(tt.expr, tt.operator)
-			if result != tt.expected {
-				t.Errorf("findOperatorOutsideParens(%q, %q) = %d, want %d",
-					tt.expr, tt.operator, result, tt.expected)
-			}
-		})
-	}
-}
-
 func TestParseValues(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -188,7 +110,7 @@ func TestParseValues(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := parseValues(tt.input)
+			result, err := lexLiterals(tt.input)
 			assertNoError(t, err)
 
 			if len(result) != len(tt.expected) {
@@ -220,7 +142,7 @@ func TestParseValues_EscapeSequences(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := parseValues(tt.input)
+			result, err := lexLiterals(tt.input)
 			assertNoError(t, err)
 
 			if len(result) != len(tt.expected) {
@@ -246,7 +168,7 @@ func TestParseValues_Errors(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := parseValues(tt.input)
+			_, err := lexLiterals(tt.input)
 			if err == nil {
 				t.Fatal("expected error")
 			}
@@ -271,7 +193,7 @@ func TestParseValues_PreservesSpaces(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := parseValues(tt.input)
+			result, err := lexLiterals(tt.input)
 			assertNoError(t, err)
 
 			if result[0] != tt.expected {
@@ -291,11 +213,13 @@ func TestParseComparison(t *testing.T) {
 		{"with IN", "fieldINa,b,c", false},
 		{"no operator", "field", true},
 		{"only operator", "=value", true},
+		{"operator keyword in quotes", "field='xINy'", false},
+		{"field containing keyword", "INDEX=5", false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := parseComparison(tt.expr)
+			_, err := Parse(tt.expr)
 			if tt.wantErr {
 				assertError(t, err)
 			} else {
@@ -319,8 +243,9 @@ func TestParseExpr_RecursiveStripping(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			node, err := parseExpr(tt.expr)
+			ast, err := Parse(tt.expr)
 			assertNoError(t, err)
+			node := ast.root
 
 			// Should parse to a comparison node, not wrapped in logical nodes
 			_, isComparison := node.(*ComparisonNode)
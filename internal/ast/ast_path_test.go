@@ -0,0 +1,245 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse_FieldPaths(t *testing.T) {
+	ast, err := Parse("caller.department.name=IT^LOWER(caller.email)ENDSWITH@corp.com")
+	assertNoError(t, err)
+	if !strings.Contains(ast.String(), "caller.department.name") || !strings.Contains(ast.String(), "LOWER(caller.email)") {
+		t.Errorf("unexpected AST %q", ast.String())
+	}
+
+	for _, expr := range []string{"caller..name=IT", ".name=IT", "caller.=IT", "LEN(a..b)>1"} {
+		t.Run(expr, func(t *testing.T) {
+			_, err := Parse(expr)
+			assertError(t, err)
+			if !strings.Contains(err.Error(), "empty segment") {
+				t.Errorf("expected empty segment error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestIntegration_FieldPaths(t *testing.T) {
+	record := map[string]interface{}{
+		"caller": map[string]interface{}{
+			"department": map[string]interface{}{"name": "IT", "size": 12},
+			"tags":       []interface{}{"vip", "emea"},
+			"manager":    nil,
+		},
+		"priority": 1,
+		"deleted":  nil,
+	}
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"caller.department.name=IT", true},
+		{"caller.department.size*2>20", true},
+		{"COUNT(caller.tags)=2", true},
+		{"caller.department.nameINHR,Sales", false},
+		{"priority=1^ORcaller.manager.name=bob", true},
+		{"priority=1^ORcaller.manager=bob", true},
+		{"priority=2^deleted=1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			testParseCompileEval(t, tt.expr, record, tt.expected)
+
+			ast, err := Parse(tt.expr)
+			assertNoError(t, err)
+			explanation, err := ast.Explain(record)
+			assertNoError(t, err)
+			if explanation.Result != tt.expected {
+				t.Errorf("Explain(%q) = %v, want %v", tt.expr, explanation.Result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIntegration_FieldPathErrors(t *testing.T) {
+	record := map[string]interface{}{
+		"caller":  map[string]interface{}{"department": "IT", "manager": nil},
+		"deleted": nil,
+	}
+	tests := []struct {
+		expr        string
+		errContains string
+	}{
+		{"caller.manager.name=bob", "undefined global variable: caller.manager.name"},
+		{"caller.manager=bob", "undefined global variable: caller.manager"},
+		{"deleted=1", "undefined global variable: deleted"},
+		{"caller.site.name=PAR", "undefined global variable: caller.site.name"},
+		{"caller.department.name=IT", "field caller.department.name: caller.department is string, not an object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			program := compileProgram(t, tt.expr)
+			machine := program.NewVM()
+			err := machine.LoadRecords(record)
+			if err == nil {
+				err = machine.Execute()
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Eval: expected error containing %q, got %v", tt.errContains, err)
+			}
+
+			ast, err := Parse(tt.expr)
+			assertNoError(t, err)
+			if _, err := ast.Explain(record); err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Explain: expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestAST_Explain_FieldPath(t *testing.T) {
+	ast, err := Parse("caller.department.name=IT")
+	assertNoError(t, err)
+	explanation, err := ast.Explain(map[string]interface{}{
+		"caller": map[string]interface{}{"department": map[string]interface{}{"name": "IT"}},
+	})
+	assertNoError(t, err)
+	if explanation.Value != "IT" || !explanation.Result {
+		t.Errorf("got value %v, result %v", explanation.Value, explanation.Result)
+	}
+}
+
+func TestTokenize_Indexes(t *testing.T) {
+	tokens, err := tokenize("items[*].sku=ABC^items[-1].statusINdone,closed", nil)
+	assertNoError(t, err)
+
+	var got []string
+	for _, tok := range tokens {
+		got = append(got, tok.Value)
+	}
+	expected := []string{"items[*].sku", "=", "ABC", "^", "items[-1].status", "IN", "done", ",", "closed", ""}
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %q\nwant %q", got, expected)
+	}
+
+	if _, err := tokenize("items[0.sku=ABC", nil); err == nil || !strings.Contains(err.Error(), "unclosed '[' at position 5") {
+		t.Errorf("expected unclosed bracket error, got %v", err)
+	}
+}
+
+func TestParse_IndexErrors(t *testing.T) {
+	tests := []struct {
+		expr        string
+		errContains string
+	}{
+		{"items[x].sku=ABC", `invalid field path "items[x].sku" at position 0: invalid index [x]`},
+		{"a=1^items[].sku=ABC", `invalid field path "items[].sku" at position 4: invalid index []`},
+		{"items[0]sku=ABC", "expected '.' or '[' after items[0]"},
+		{"items[*].price*2>10", "* at position 14 requires numbers, items[*].price is array"},
+		{"LEN(items[*].sku)>1", "LEN argument 1 expects string, got array"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			assertError(t, err)
+			if !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("expected error containing %q, got: %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestAST_Compile_Wildcard(t *testing.T) {
+	program := compileProgram(t, "items[*].skuINABC,DEF")
+
+	listing, err := program.Disassemble()
+	assertNoError(t, err)
+	expected := `0000  LOAD_GLOBAL "items[*].sku"
+0003  PUSH ARRAY [STRING "ABC", STRING "DEF"]
+0016  MATCH_ANY OP_IN
+`
+	if listing != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", listing, expected)
+	}
+	assertNoError(t, program.Verify())
+}
+
+func TestIntegration_Indexes(t *testing.T) {
+	record := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"sku": "ABC", "price": 10, "status": "open"},
+			map[string]interface{}{"sku": "DEF", "price": 25.5, "status": "done"},
+		},
+		"empty": []interface{}{},
+		"a":     1,
+		"name":  "john",
+	}
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"items[0].sku=ABC", true},
+		{"items[-1].status=done", true},
+		{"items[1].price>25", true},
+		{"items[*].sku=DEF", true},
+		{"items[*].sku=XYZ", false},
+		{"!items[*].sku=XYZ", true},
+		{"items[*].price>20", true},
+		{"items[*].skuINXYZ,ABC", true},
+		{"items[*].statusSTARTSWITHcl", false},
+		{"SUM(items[*].price)=35.5", true},
+		{"COUNT(items[*].sku)=2", true},
+		{"empty[*].sku=ABC", false},
+		// le terme court-circuité n'est pas résolu
+		{"a=2^items[9].sku=A", false},
+		{"a=1^ORitems[9].sku=A", true},
+		{"a=2^name[*]=j", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			testParseCompileEval(t, tt.expr, record, tt.expected)
+
+			ast, err := Parse(tt.expr)
+			assertNoError(t, err)
+			explanation, err := ast.Explain(record)
+			assertNoError(t, err)
+			if explanation.Result != tt.expected {
+				t.Errorf("Explain(%q) = %v, want %v", tt.expr, explanation.Result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIntegration_IndexErrors(t *testing.T) {
+	record := map[string]interface{}{
+		"items": []interface{}{map[string]interface{}{"sku": "ABC"}},
+		"name":  "john",
+	}
+	tests := []struct {
+		expr        string
+		errContains string
+	}{
+		{"items[3].sku=ABC", "field items[3].sku: items[3]: index 3 out of range, 1 elements"},
+		{"name[*]=j", "field name[*]: name is string, not an array"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			program := compileProgram(t, tt.expr)
+			machine := program.NewVM()
+			assertNoError(t, machine.LoadRecords(record))
+			if err := machine.Execute(); err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Eval: expected error containing %q, got %v", tt.errContains, err)
+			}
+
+			ast, err := Parse(tt.expr)
+			assertNoError(t, err)
+			if _, err := ast.Explain(record); err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Explain: expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
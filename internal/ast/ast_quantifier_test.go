@@ -0,0 +1,223 @@
+package ast
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Daemon0x00000000/sel/internal/vm"
+)
+
+func TestTokenize_Quantifier(t *testing.T) {
+	tokens, err := tokenize("ANY(items, sku=X^qty>2)^ok=1", nil)
+	assertNoError(t, err)
+	expected := []TokenType{
+		TOKEN_QUANTIFIER, TOKEN_LPAREN, TOKEN_FIELD, TOKEN_COMMA,
+		TOKEN_FIELD, TOKEN_OPERATOR, TOKEN_LITERAL, TOKEN_LOGICAL,
+		TOKEN_FIELD, TOKEN_OPERATOR, TOKEN_LITERAL, TOKEN_RPAREN,
+		TOKEN_LOGICAL, TOKEN_FIELD, TOKEN_OPERATOR, TOKEN_LITERAL, TOKEN_EOF,
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens, got %d: %v", len(expected), len(tokens), tokens)
+	}
+	for i, typ := range expected {
+		if tokens[i].Type != typ {
+			t.Errorf("token %d: expected %v, got %v (%q)", i, typ, tokens[i].Type, tokens[i].Value)
+		}
+	}
+}
+
+func TestParse_Quantifier(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{"ANY(items, sku=X^qty>2)", "ANY items"},
+		{"ALL(order.lines, price>0)", "ALL order.lines"},
+		{"NONE( items , status=cancelled)", "NONE items"},
+		{"!ANY(items, sku=X)", "NOT"},
+		{"ANY(items, ANY(tags, name=vip))", "ANY tags"},
+		{"(ANY(items, (a=1^ORb=2)))^c=3", "^OR"},
+		{"ANYTHING=1", "ANYTHING"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			ast, err := Parse(tt.expr)
+			assertNoError(t, err)
+			if !strings.Contains(ast.String(), tt.expected) {
+				t.Errorf("AST %q does not contain %q", ast.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestParse_QuantifierErrors(t *testing.T) {
+	tests := []struct {
+		expr        string
+		errContains string
+	}{
+		{"ANY(, a=1)", "ANY expects an array field at position 4"},
+		{"ALL(items a=1)", "ALL expects ',' after items at position 10"},
+		{"NONE(items, )", "NONE at position 0 expects a condition"},
+		{"ANY(items, a)", `no comparison operator found after field "a" at position 11`},
+		{"ANY(items..x, a=1)", "empty segment"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			assertError(t, err)
+			if !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("expected error containing %q, got: %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestAST_Compile_Quantifier(t *testing.T) {
+	program := compileProgram(t, "ANY(items, LOWER(sku)=x)^LEN(name)>1")
+
+	listing, err := program.Disassemble()
+	assertNoError(t, err)
+	expected := `0000  LOAD_GLOBAL "items"
+0003  ANY_OF 0
+0005  JUMP_IF_FALSE_OR_POP +11  ; -> 0021
+0010  LOAD_GLOBAL "name"
+0013  CALL_NATIVE 1 1  ; LEN
+0016  PUSH INT8 1
+0020  OP_GT
+; program 0
+0000  LOAD_GLOBAL "sku"
+0003  CALL_NATIVE 0 1  ; LOWER
+0006  PUSH STRING "x"
+0010  OP_EQ
+`
+	if listing != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", listing, expected)
+	}
+	if fields := program.Fields(); len(fields) != 2 || fields[0] != "items" || fields[1] != "name" {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	if nested := program.Programs()[0]; len(nested.Natives()) != 2 {
+		t.Errorf("expected the nested program to share 2 natives, got %d", len(nested.Natives()))
+	}
+
+	// le listing se réassemble avec son programme imbriqué
+	assembled, err := vm.Assemble(listing, []vm.NativeFunc{nil, nil})
+	assertNoError(t, err)
+	if len(assembled.Programs()) != 1 || !bytes.Equal(assembled.Programs()[0].Bytecode(), program.Programs()[0].Bytecode()) {
+		t.Errorf("nested program lost in the round trip: %v", assembled.Programs())
+	}
+	if !bytes.Equal(assembled.Bytecode(), program.Bytecode()) {
+		t.Error("round trip changed the root bytecode")
+	}
+}
+
+func TestIntegration_Quantifier(t *testing.T) {
+	record := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"sku": "X", "qty": 3, "tags": []interface{}{"new"}},
+			map[string]interface{}{"sku": "Y", "qty": 1, "tags": []interface{}{}},
+		},
+		"orders": []interface{}{
+			map[string]interface{}{"lines": []interface{}{map[string]interface{}{"price": 10}}},
+			map[string]interface{}{"lines": []interface{}{map[string]interface{}{"price": 0}}},
+		},
+		"empty": []interface{}{},
+		"sku":   "Y",
+	}
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"ANY(items, sku=X^qty>2)", true},
+		{"ANY(items, sku=Y^qty>2)", false},
+		{"ALL(items, qty>0)", true},
+		{"ALL(items, qty>1)", false},
+		{"NONE(items, sku=Z)", true},
+		{"NONE(items, sku=X)", false},
+		{"ANY(empty, a=1)", false},
+		{"ALL(empty, a=1)", true},
+		{"NONE(empty, a=1)", true},
+		{"sku=Y^ANY(items, sku=X)", true},
+		{"!ALL(items, sku=X)", true},
+		{"ANY(items, tags[*]=new)", true},
+		{"ANY(items, COUNT(tags)=0^sku=Y)", true},
+		{"ANY(orders, ALL(lines, price>0))", true},
+		{"ALL(orders, ANY(lines, price>0))", false},
+		{"ANY(orders[*].lines[*], price=0)", true},
+		{"ANY(items, sku=X)^ORmissing=1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			testParseCompileEval(t, tt.expr, record, tt.expected)
+
+			ast, err := Parse(tt.expr)
+			assertNoError(t, err)
+			explanation, err := ast.Explain(record)
+			assertNoError(t, err)
+			if explanation.Result != tt.expected {
+				t.Errorf("Explain(%q) = %v, want %v", tt.expr, explanation.Result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIntegration_QuantifierErrors(t *testing.T) {
+	record := map[string]interface{}{
+		"items": []interface{}{map[string]interface{}{"sku": "X"}, map[string]interface{}{"qty": 1}},
+		"tags":  []interface{}{"vip"},
+		"name":  "john",
+	}
+	tests := []struct {
+		expr        string
+		errContains string
+	}{
+		{"ANY(name, a=1)", "ANY requires an array, got STRING"},
+		{"ALL(tags, a=1)", "ALL requires objects, element 0 is STRING"},
+		{"ALL(items, sku=X)", "ALL element 1: undefined global variable: sku"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			program := compileProgram(t, tt.expr)
+			machine := program.NewVM()
+			assertNoError(t, machine.LoadRecords(record))
+			if err := machine.Execute(); err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Execute: expected error containing %q, got %v", tt.errContains, err)
+			}
+
+			ast, err := Parse(tt.expr)
+			assertNoError(t, err)
+			if _, err := ast.Explain(record); err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Explain: expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestAST_Explain_Quantifier(t *testing.T) {
+	ast, err := Parse("ANY(items, sku=X)")
+	assertNoError(t, err)
+	record := map[string]interface{}{"items": []interface{}{
+		map[string]interface{}{"sku": "Y"},
+		map[string]interface{}{"sku": "X"},
+		map[string]interface{}{"sku": "Z"},
+	}}
+	explanation, err := ast.Explain(record)
+	assertNoError(t, err)
+
+	expected := `└── ANY items → true
+    ├── items[0] → false
+    │   └── sku = X → false (sku: "Y")
+    ├── items[1] → true
+    │   └── sku = X → true (sku: "X")
+    └── items[2] → skipped
+        └── sku = X → skipped
+`
+	if got := explanation.String(); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+}
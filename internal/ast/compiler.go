@@ -0,0 +1,81 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/Daemon0x00000000/sel/internal/vm"
+)
+
+// compiler holds the state shared by the nodes during compilation: the field
+// table that maps each referenced field to its global slot, the native
+// functions called, and the stack depth so the VM can size its stack once
+type compiler struct {
+	fields   []string
+	slots    map[string]int
+	natives  []vm.Function
+	indexes  map[string]int // CALL_NATIVE index of each function name
+	depth    int            // values on the stack after the last emitted instruction
+	maxDepth int
+	programs []*vm.Program // nested programs of the quantifiers
+	root     *compiler     // nil for the root, nested compilers add natives to it
+}
+
+func newCompiler() *compiler {
+	return &compiler{slots: make(map[string]int), indexes: make(map[string]int)}
+}
+
+// nested returns a compiler for the condition of a quantifier: it has its
+// own fields, the fields of an element, and shares the natives of the root
+func (c *compiler) nested() *compiler {
+	root := c
+	if c.root != nil {
+		root = c.root
+	}
+	return &compiler{slots: make(map[string]int), root: root}
+}
+
+// program wraps compiled bytecode with the field table, the natives and the
+// nested programs
+func (c *compiler) program(bytecode []byte) *vm.Program {
+	program := vm.NewProgram(bytecode, c.fields, c.maxDepth, c.natives)
+	for _, nested := range c.programs {
+		program.AddProgram(nested)
+	}
+	return program
+}
+
+// slot returns the global slot of a field, allocating one on first use
+func (c *compiler) slot(field Field) (uint16, error) {
+	name := string(field)
+	if slot, ok := c.slots[name]; ok {
+		return uint16(slot), nil
+	}
+	if len(c.fields) >= vm.MaxGlobals {
+		return 0, fmt.Errorf("too many fields: at most %d distinct fields per expression", vm.MaxGlobals)
+	}
+	slot := len(c.fields)
+	c.fields = append(c.fields, name)
+	c.slots[name] = slot
+	return uint16(slot), nil
+}
+
+// native returns the CALL_NATIVE index of a function, adding it to the
+// program natives on first use
+func (c *compiler) native(fn vm.Function) int {
+	if c.root != nil {
+		return c.root.native(fn)
+	}
+	if index, ok := c.indexes[fn.Name]; ok {
+		return index
+	}
+	index := len(c.natives)
+	c.natives = append(c.natives, fn)
+	c.indexes[fn.Name] = index
+	return index
+}
+
+// stack records the effect of an emitted instruction on the stack depth
+func (c *compiler) stack(delta int) {
+	c.depth += delta
+	c.maxDepth = max(c.maxDepth, c.depth)
+}
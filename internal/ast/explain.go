@@ -0,0 +1,196 @@
+package ast
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Daemon0x00000000/sel/internal/vm"
+)
+
+// Explanation is the outcome of one node of the expression for a record.
+// Comparisons carry their left operand (a field or a call), its value and
+// the operand it was compared with.
+type Explanation struct {
+	Expr     string // "status = active", "^OR", "NOT"
+	Field    string
+	Value    interface{}
+	Operand  interface{}
+	Result   bool
+	Skipped  bool // not evaluated: the other side of AND / OR decided
+	Children []*Explanation
+}
+
+func (e *Explanation) String() string {
+	return explanationString(e, "", true)
+}
+
+// Explain evaluates the expression against data node by node, with the
+// semantics of the compiled program, and returns the annotated tree
+func (ast *AST) Explain(data map[string]interface{}) (*Explanation, error) {
+	if ast.root == nil {
+		return nil, fmt.Errorf("cannot explain AST with nil root")
+	}
+	return explain(ast.root, data, false)
+}
+
+// explain evaluates node, or only describes it when skip is set
+func explain(node Node, data map[string]interface{}, skip bool) (*Explanation, error) {
+	switch n := node.(type) {
+	case *LogicalNode:
+		e := &Explanation{Expr: string(n.operatorStr), Skipped: skip}
+		left, err := explain(n.left, data, skip)
+		if err != nil {
+			return nil, err
+		}
+		// short-circuit, comme JUMP_IF_FALSE_OR_POP / JUMP_IF_TRUE_OR_POP
+		decided := (n.operator == vm.OP_AND && !left.Result) || (n.operator == vm.OP_OR && left.Result)
+		right, err := explain(n.right, data, skip || decided)
+		if err != nil {
+			return nil, err
+		}
+		e.Children = []*Explanation{left, right}
+		if skip || decided {
+			e.Result = left.Result
+			return e, nil
+		}
+		e.Result, err = apply(n.operator, vm.BoolValue(left.Result), vm.BoolValue(right.Result))
+		return e, err
+
+	case *NotNode:
+		operand, err := explain(n.operand, data, skip)
+		if err != nil {
+			return nil, err
+		}
+		e := &Explanation{Expr: "NOT", Skipped: skip, Children: []*Explanation{operand}}
+		if !skip {
+			e.Result, err = apply(vm.OP_NOT, vm.BoolValue(operand.Result))
+		}
+		return e, err
+
+	case *ComparisonNode:
+		e := &Explanation{
+			Expr:    fmt.Sprintf("%s %v %v", n.left, n.operatorStr, n.right),
+			Field:   n.left.String(),
+			Operand: n.right,
+			Skipped: skip,
+		}
+		if skip {
+			return e, nil
+		}
+		left, err := n.left.evaluate(data)
+		if err != nil {
+			return nil, err
+		}
+		if field, ok := n.left.(Field); ok {
+			e.Value, _, _ = vm.Lookup(data, string(field)) // la valeur telle que fournie
+		} else {
+			e.Value = left.Interface()
+		}
+		var right vm.Value
+		if call, ok := n.right.(Operand); ok {
+			right, err = call.evaluate(data)
+			e.Operand = right.Interface()
+		} else {
+			right, err = vm.ToValue(n.right)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n.any {
+			var result vm.Value
+			result, err = vm.ApplyAny(n.operator, left, right)
+			e.Result = result.Bool()
+		} else {
+			e.Result, err = apply(n.operator, left, right)
+		}
+		return e, err
+
+	case *QuantifierNode:
+		return explainQuantifier(n, data, skip)
+	}
+	return nil, fmt.Errorf("cannot explain node %T", node)
+}
+
+// explainQuantifier explains the condition on each element, like ANY_OF,
+// ALL_OF and NONE_OF the elements after the one that decides are skipped
+func explainQuantifier(n *QuantifierNode, data map[string]interface{}, skip bool) (*Explanation, error) {
+	e := &Explanation{Expr: fmt.Sprintf("%s %s", n.name, n.array), Skipped: skip}
+	if skip {
+		return e, nil
+	}
+	array, err := n.array.evaluate(data)
+	if err != nil {
+		return nil, err
+	}
+	if array.Type != vm.TYPE_ARRAY {
+		return nil, fmt.Errorf("%s requires an array, got %v", n.name, array.Type)
+	}
+
+	all := n.operator == vm.ALL_OF
+	e.Result = n.operator != vm.ANY_OF
+	decided := false
+	for i, elem := range array.Array() {
+		if elem.Type != vm.TYPE_OBJECT {
+			return nil, fmt.Errorf("%s requires objects, element %d is %v", n.name, i, elem.Type)
+		}
+		body, err := explain(n.body, elem.Object(), decided)
+		if err != nil {
+			return nil, fmt.Errorf("%s element %d: %w", n.name, i, err)
+		}
+		e.Children = append(e.Children, &Explanation{
+			Expr:     fmt.Sprintf("%s[%d]", n.array, i),
+			Result:   body.Result,
+			Skipped:  decided,
+			Children: []*Explanation{body},
+		})
+		if !decided && body.Result != all {
+			e.Result = n.operator == vm.ANY_OF
+			decided = true
+		}
+	}
+	return e, nil
+}
+
+func apply(op vm.OpCode, operands ...vm.Value) (bool, error) {
+	result, err := vm.Apply(op, operands...)
+	if err != nil {
+		return false, err
+	}
+	return result.Bool(), nil
+}
+
+func explanationString(e *Explanation, prefix string, isLast bool) string {
+	var sb strings.Builder
+
+	connector := "├── "
+	if isLast {
+		connector = "└── "
+	}
+	ext := "│   "
+	if isLast {
+		ext = "    "
+	}
+
+	outcome := strconv.FormatBool(e.Result)
+	switch {
+	case e.Skipped:
+		outcome = "skipped"
+	case e.Field != "":
+		outcome += fmt.Sprintf(" (%s: %s)", e.Field, formatRecordValue(e.Value))
+	}
+	fmt.Fprintf(&sb, "%s%s%s → %s\n", prefix, connector, e.Expr, outcome)
+
+	for i, child := range e.Children {
+		sb.WriteString(explanationString(child, prefix+ext, i == len(e.Children)-1))
+	}
+	return sb.String()
+}
+
+// formatRecordValue quotes strings so that "" and " x" stay visible
+func formatRecordValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,612 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Daemon0x00000000/sel/internal/vm"
+)
+
+type TokenType byte
+
+const (
+	TOKEN_EOF      TokenType = iota
+	TOKEN_FIELD              // left operand of a comparison
+	TOKEN_OPERATOR           // comparison operator, optionally prefixed with '!'
+	TOKEN_LOGICAL            // ^, ^OR, ^XOR
+	TOKEN_NOT                // '!' in front of a group or a comparison
+	TOKEN_LITERAL            // right operand value, already unescaped
+	TOKEN_COMMA              // separator between literals
+	TOKEN_LPAREN
+	TOKEN_RPAREN
+	TOKEN_FUNC       // name of a registered function, followed by '('
+	TOKEN_ARITH      // + - * / % in a comparison operand
+	TOKEN_QUANTIFIER // ANY, ALL or NONE, followed by '(' the array field and ','
+)
+
+var tokenTypeNames = map[TokenType]string{
+	TOKEN_EOF:        "end of expression",
+	TOKEN_FIELD:      "field",
+	TOKEN_OPERATOR:   "operator",
+	TOKEN_LOGICAL:    "logical operator",
+	TOKEN_NOT:        "'!'",
+	TOKEN_LITERAL:    "value",
+	TOKEN_COMMA:      "','",
+	TOKEN_LPAREN:     "'('",
+	TOKEN_RPAREN:     "')'",
+	TOKEN_FUNC:       "function",
+	TOKEN_ARITH:      "arithmetic operator",
+	TOKEN_QUANTIFIER: "quantifier",
+}
+
+func (t TokenType) String() string {
+	if name, ok := tokenTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("token(%d)", byte(t))
+}
+
+// Token is a lexical unit with its byte offset in the source expression
+type Token struct {
+	Type   TokenType
+	Value  string
+	Pos    int
+	Quoted bool // literal was written between single quotes
+}
+
+// lexer states: what the grammar allows at the current position
+type lexState byte
+
+const (
+	stateTerm    lexState = iota // '(', '!' or the start of a comparison
+	stateValue                   // literal list on the right of an operator
+	stateAfter                   // ')', a logical operator or the end
+	stateOperand                 // left operand up to the comparison operator, or a call
+)
+
+type lexer struct {
+	input  string
+	pos    int
+	state  lexState
+	tokens []Token
+	funcs  *vm.Functions
+	depth  int  // open parentheses and calls in stateOperand
+	right  bool // the operand is a call on the right of the operator
+}
+
+// tokenize splits an expression into typed tokens. An identifier directly
+// followed by '(' is a call when funcs has a function of that name.
+func tokenize(input string, funcs *vm.Functions) ([]Token, error) {
+	lx := &lexer{input: input, state: stateTerm, funcs: funcs}
+
+	for {
+		lx.skipSpaces()
+		if lx.pos >= len(lx.input) {
+			if lx.state == stateValue {
+				// "field=" : the value is an empty literal
+				lx.emit(TOKEN_LITERAL, "", lx.pos)
+			}
+			lx.emit(TOKEN_EOF, "", lx.pos)
+			return lx.tokens, nil
+		}
+
+		var err error
+		switch lx.state {
+		case stateTerm:
+			err = lx.lexTerm()
+		case stateValue:
+			err = lx.lexValues()
+		case stateAfter:
+			err = lx.lexAfter()
+		case stateOperand:
+			err = lx.lexOperand()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (lx *lexer) emit(typ TokenType, value string, pos int) {
+	lx.tokens = append(lx.tokens, Token{Type: typ, Value: value, Pos: pos})
+}
+
+func (lx *lexer) skipSpaces() {
+	for lx.pos < len(lx.input) && isSpace(lx.input[lx.pos]) {
+		lx.pos++
+	}
+}
+
+func (lx *lexer) lexTerm() error {
+	switch char := lx.input[lx.pos]; char {
+	case '(':
+		if lx.isOperandGroup() {
+			lx.state = stateOperand // (a+b)*c>10
+			return nil
+		}
+		lx.emit(TOKEN_LPAREN, "(", lx.pos)
+		lx.pos++
+	case ')':
+		lx.emit(TOKEN_RPAREN, ")", lx.pos)
+		lx.pos++
+		lx.state = stateAfter
+	case '!':
+		lx.emit(TOKEN_NOT, "!", lx.pos)
+		lx.pos++
+	case '^':
+		return lx.lexLogical()
+	default:
+		if lx.isQuantifier() {
+			return lx.lexQuantifier()
+		}
+		lx.state = stateOperand
+	}
+	return nil
+}
+
+// isQuantifier reports whether ANY, ALL or NONE followed by '(' starts at pos
+func (lx *lexer) isQuantifier() bool {
+	for name := range quantifierOperators {
+		if strings.HasPrefix(lx.input[lx.pos:], name+"(") {
+			return true
+		}
+	}
+	return false
+}
+
+// lexQuantifier reads the head of a quantifier, ANY(items, up to the
+// condition applied to each element. The condition is lexed as terms, its
+// closing ')' like the one of a group.
+func (lx *lexer) lexQuantifier() error {
+	start := lx.pos
+	open := strings.IndexByte(lx.input[start:], '(') + start
+	name := lx.input[start:open]
+	lx.emit(TOKEN_QUANTIFIER, name, start)
+	lx.emit(TOKEN_LPAREN, "(", open)
+	lx.pos = open + 1
+
+	lx.skipSpaces()
+	fieldStart := lx.pos
+	end, err := lx.scanField(fieldStart)
+	if err != nil {
+		return err
+	}
+	if end == fieldStart {
+		return fmt.Errorf("%s expects an array field at position %d", name, fieldStart)
+	}
+	lx.emit(TOKEN_FIELD, lx.input[fieldStart:end], fieldStart)
+	lx.pos = end
+
+	lx.skipSpaces()
+	if lx.pos >= len(lx.input) || lx.input[lx.pos] != ',' {
+		return fmt.Errorf("%s expects ',' after %s at position %d", name, lx.input[fieldStart:end], lx.pos)
+	}
+	lx.emit(TOKEN_COMMA, ",", lx.pos)
+	lx.pos++
+	return nil
+}
+
+// isOperandGroup reports whether the '(' at pos groups an arithmetic operand
+// rather than conditions: an operand is followed by an arithmetic or a
+// comparison operator
+func (lx *lexer) isOperandGroup() bool {
+	depth := 0
+	for i := lx.pos; i < len(lx.input); i++ {
+		switch lx.input[i] {
+		case '\'':
+			for i++; i < len(lx.input) && lx.input[i] != '\''; i++ {
+				if lx.input[i] == '\\' {
+					i++
+				}
+			}
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				next := i + 1
+				for next < len(lx.input) && isSpace(lx.input[next]) {
+					next++
+				}
+				if next < len(lx.input) && strings.IndexByte("+-*/%", lx.input[next]) >= 0 {
+					return true
+				}
+				_, _, ok := matchComparisonOperator(lx.input[next:])
+				return ok
+			}
+		}
+	}
+	return false
+}
+
+// isCall reports whether a registered function or an aggregation name
+// followed by '(' starts at pos
+func (lx *lexer) isCall() bool {
+	end := lx.pos
+	for end < len(lx.input) && isFieldChar(lx.input[end]) && lx.input[end] != '.' {
+		end++
+	}
+	if end == lx.pos || end >= len(lx.input) || lx.input[end] != '(' {
+		return false
+	}
+	name := lx.input[lx.pos:end]
+	if _, ok := aggregateOperators[name]; ok {
+		return true
+	}
+	_, ok := lx.funcs.Lookup(name)
+	return ok
+}
+
+// lexOperand reads one token of an operand: a field, a number, a quoted
+// string, a call, an arithmetic operator, a parenthesis or a ',' between
+// arguments. Outside parentheses, the comparison operator ends the operand.
+func (lx *lexer) lexOperand() error {
+	start := lx.pos
+	switch char := lx.input[start]; {
+	case char == '(':
+		lx.emit(TOKEN_LPAREN, "(", start)
+		lx.pos++
+		lx.depth++
+	case char == ')' && lx.depth > 0:
+		lx.emit(TOKEN_RPAREN, ")", start)
+		lx.pos++
+		lx.depth--
+		if lx.depth == 0 && lx.right {
+			lx.right = false
+			lx.state = stateAfter
+		}
+	case char == ',' && lx.depth > 0:
+		lx.emit(TOKEN_COMMA, ",", start)
+		lx.pos++
+	case char == '\'':
+		return lx.lexQuoted()
+	case strings.IndexByte("+-*/%", char) >= 0:
+		lx.emit(TOKEN_ARITH, string(char), start)
+		lx.pos++
+	case lx.depth == 0 && lx.afterOperand():
+		op, n, ok := matchComparisonOperator(lx.input[start:])
+		if !ok {
+			return fmt.Errorf("expected comparison operator at position %d", start)
+		}
+		lx.emit(TOKEN_OPERATOR, op, start)
+		lx.pos += n
+		lx.state = stateValue
+	case isFieldChar(char):
+		return lx.lexIdentifier()
+	default:
+		if _, _, ok := matchComparisonOperator(lx.input[start:]); ok {
+			return fmt.Errorf("missing field before operator at position %d", start)
+		}
+		return fmt.Errorf("unexpected character %q at position %d", char, start)
+	}
+	return nil
+}
+
+// afterOperand reports whether the last token ends an operand, so that an
+// operator is expected
+func (lx *lexer) afterOperand() bool {
+	if len(lx.tokens) == 0 {
+		return false
+	}
+	switch lx.tokens[len(lx.tokens)-1].Type {
+	case TOKEN_FIELD, TOKEN_LITERAL, TOKEN_RPAREN:
+		return true
+	}
+	return false
+}
+
+// lexIdentifier reads a call, or a field or unquoted number. Keyword
+// operators may be glued to the last field of the operand (statusINa,b), so
+// outside parentheses the field is the whole identifier when an operator
+// follows it (INDEX=5, a+b), otherwise it is split on a keyword found after
+// its first character, see splitKeyword.
+func (lx *lexer) lexIdentifier() error {
+	start := lx.pos
+	end, err := lx.scanField(start)
+	if err != nil {
+		return err
+	}
+
+	if lx.isCall() {
+		open := strings.IndexByte(lx.input[start:], '(') + start
+		lx.emit(TOKEN_FUNC, lx.input[start:open], start)
+		lx.emit(TOKEN_LPAREN, "(", open)
+		lx.pos = open + 1
+		lx.depth++
+		return nil
+	}
+
+	next := end
+	for next < len(lx.input) && isSpace(lx.input[next]) {
+		next++
+	}
+	if lx.depth > 0 && end < len(lx.input) && lx.input[end] == '(' {
+		return lx.unknownFunction(start, end)
+	}
+	if lx.depth > 0 || next < len(lx.input) && strings.IndexByte("+-*/%", lx.input[next]) >= 0 {
+		lx.emit(TOKEN_FIELD, lx.input[start:end], start)
+		lx.pos = end
+		return nil
+	}
+	if op, n, ok := matchComparisonOperator(lx.input[next:]); ok {
+		lx.emitComparison(start, end, op, next, n)
+		return nil
+	}
+
+	if i, op, n, ok := lx.splitKeyword(start, end); ok {
+		lx.emitComparison(start, i, op, i, n)
+		return nil
+	}
+
+	if end < len(lx.input) && lx.input[end] == '(' {
+		return lx.unknownFunction(start, end)
+	}
+	return fmt.Errorf("no comparison operator found after field %q at position %d", lx.input[start:end], start)
+}
+
+// unknownFunction reports an identifier followed by '(' that is not a call
+func (lx *lexer) unknownFunction(start, end int) error {
+	name := lx.input[start:end]
+	if strings.ContainsAny(name, ".[") {
+		return fmt.Errorf("unexpected '(' after field %q at position %d", name, end)
+	}
+	return fmt.Errorf("unknown function %q at position %d", name, start)
+}
+
+// splitKeyword finds the keyword operator glued inside the identifier
+// input[start:end]. A keyword nested in a longer one, the IN of CONTAINS, is
+// never taken. A keyword after a lowercase letter or ']' starts at a real
+// boundary (statusINa), the first one wins. In an uppercase field name
+// (LINKSIN1,2) the last keyword still followed by a value is taken, so the
+// field is the longest name possible.
+func (lx *lexer) splitKeyword(start, end int) (int, string, int, bool) {
+	found := -1
+	var op string
+	var n int
+	covered := start // end of the last keyword matched
+	for i := start; i < end; i++ {
+		keyword, length, ok := matchKeywordOperator(lx.input[i:end])
+		if !ok || i < covered {
+			continue
+		}
+		covered = i + length
+		if i == start {
+			continue
+		}
+		if before := lx.input[i-1]; before >= 'a' && before <= 'z' || before == ']' {
+			return i, keyword, length, true
+		}
+		if i+length < end || end < len(lx.input) && lx.input[end] == '\'' {
+			found, op, n = i, keyword, length
+		}
+	}
+	return found, op, n, found >= 0
+}
+
+// scanField returns the end of the field starting at start: field characters
+// and indexes, items[0].sku, items[-1], items[*]
+func (lx *lexer) scanField(start int) (int, error) {
+	end := start
+	for end < len(lx.input) {
+		switch char := lx.input[end]; {
+		case isFieldChar(char):
+			end++
+		case char == '[':
+			closing := strings.IndexByte(lx.input[end:], ']')
+			if closing < 0 {
+				return 0, fmt.Errorf("unclosed '[' at position %d", end)
+			}
+			end += closing + 1
+		default:
+			return end, nil
+		}
+	}
+	return end, nil
+}
+
+func (lx *lexer) emitComparison(fieldStart, fieldEnd int, op string, opPos, opLen int) {
+	lx.emit(TOKEN_FIELD, lx.input[fieldStart:fieldEnd], fieldStart)
+	lx.emit(TOKEN_OPERATOR, op, opPos)
+	lx.pos = opPos + opLen
+	lx.state = stateValue
+}
+
+// lexQuoted reads a quoted string argument
+func (lx *lexer) lexQuoted() error {
+	start := lx.pos
+	var sb strings.Builder
+	if err := lx.readQuoted(&sb); err != nil {
+		return err
+	}
+	lx.tokens = append(lx.tokens, Token{Type: TOKEN_LITERAL, Value: sb.String(), Pos: start, Quoted: true})
+	return nil
+}
+
+func (lx *lexer) lexAfter() error {
+	switch char := lx.input[lx.pos]; char {
+	case ')':
+		lx.emit(TOKEN_RPAREN, ")", lx.pos)
+		lx.pos++
+	case '^':
+		return lx.lexLogical()
+	default:
+		return fmt.Errorf("unexpected character %q at position %d", char, lx.pos)
+	}
+	return nil
+}
+
+// lexLogical reads ^, ^OR or ^XOR. ^OR and ^XOR may be glued to a
+// lowercase field (^ORb=2). Followed by an uppercase letter, a digit or '_'
+// they could also start a field (^ORDER_ID=5), which is an error: a space
+// tells them apart, ^OR STATUS=x or ^ ORDER_ID=5.
+func (lx *lexer) lexLogical() error {
+	for _, op := range logicalOperatorsOrdered {
+		if strings.HasPrefix(lx.input[lx.pos:], string(op)) {
+			if next := lx.pos + len(op); op != AND && next < len(lx.input) && isUpperFieldChar(lx.input[next]) {
+				return fmt.Errorf("ambiguous %s at position %d: add a space after it, or after '^' when the field starts with %s", op, lx.pos, op[1:])
+			}
+			lx.emit(TOKEN_LOGICAL, string(op), lx.pos)
+			lx.pos += len(op)
+			lx.state = stateTerm
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid logical operator at position %d", lx.pos)
+}
+
+// lexValues reads a comma separated list of literals up to '^', an
+// unmatched ')' or the end of the expression, or a single call
+func (lx *lexer) lexValues() error {
+	if strings.ContainsRune("=<>", rune(lx.input[lx.pos])) {
+		return fmt.Errorf("double operator at position %d", lx.pos)
+	}
+	if lx.isCall() {
+		lx.right = true
+		lx.state = stateOperand
+		return nil
+	}
+
+	for {
+		if err := lx.lexLiteral(); err != nil {
+			return err
+		}
+		if lx.pos >= len(lx.input) || lx.input[lx.pos] != ',' {
+			break
+		}
+		lx.emit(TOKEN_COMMA, ",", lx.pos)
+		lx.pos++
+	}
+
+	lx.state = stateAfter
+	return nil
+}
+
+// lexLiteral reads one list item. Quoted parts keep their spaces, unquoted
+// leading and trailing spaces are trimmed.
+func (lx *lexer) lexLiteral() error {
+	lx.skipSpaces()
+
+	var sb strings.Builder
+	start := lx.pos
+	keep := 0 // length of sb without trailing unquoted spaces
+	quoted := false
+	depth := 0
+
+	for lx.pos < len(lx.input) {
+		char := lx.input[lx.pos]
+
+		if char == '\'' {
+			if err := lx.readQuoted(&sb); err != nil {
+				return err
+			}
+			quoted = true
+			keep = sb.Len()
+			continue
+		}
+
+		if char == ',' || char == '^' {
+			break
+		}
+		if char == '(' {
+			depth++
+		} else if char == ')' {
+			if depth == 0 {
+				break
+			}
+			depth--
+		}
+
+		sb.WriteByte(char)
+		lx.pos++
+		if !isSpace(char) {
+			keep = sb.Len()
+		}
+	}
+
+	lx.tokens = append(lx.tokens, Token{
+		Type:   TOKEN_LITERAL,
+		Value:  sb.String()[:keep],
+		Pos:    start,
+		Quoted: quoted,
+	})
+	return nil
+}
+
+// readQuoted appends the unescaped content of the quoted string at pos to sb
+func (lx *lexer) readQuoted(sb *strings.Builder) error {
+	quoteStart := lx.pos
+	lx.pos++
+	for lx.pos < len(lx.input) {
+		c := lx.input[lx.pos]
+		if c == '\\' {
+			if lx.pos+1 >= len(lx.input) {
+				return fmt.Errorf("incomplete escape sequence at position %d", lx.pos)
+			}
+			sb.WriteByte(unescape(lx.input[lx.pos+1]))
+			lx.pos += 2
+			continue
+		}
+		lx.pos++
+		if c == '\'' {
+			return nil
+		}
+		sb.WriteByte(c)
+	}
+	return fmt.Errorf("unclosed quote starting at position %d", quoteStart)
+}
+
+func unescape(char byte) byte {
+	switch char {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	}
+	// \\, \' and unknown escapes pass through
+	return char
+}
+
+// matchComparisonOperator matches an operator at the start of s, longest first
+func matchComparisonOperator(s string) (string, int, bool) {
+	if strings.HasPrefix(s, "!") {
+		for _, op := range comparisonOpsOrdered {
+			if strings.HasPrefix(s[1:], string(op)) {
+				return "!" + string(op), len(op) + 1, true
+			}
+		}
+		return "", 0, false
+	}
+	for _, op := range comparisonOpsOrdered {
+		if strings.HasPrefix(s, string(op)) {
+			return string(op), len(op), true
+		}
+	}
+	return "", 0, false
+}
+
+func matchKeywordOperator(s string) (string, int, bool) {
+	for _, op := range comparisonOpsOrdered {
+		if isKeyword(op) && strings.HasPrefix(s, string(op)) {
+			return string(op), len(op), true
+		}
+	}
+	return "", 0, false
+}
+
+func isKeyword(op ComparisonOperator) bool {
+	return op[0] >= 'A' && op[0] <= 'Z'
+}
+
+func isFieldChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.'
+}
+
+// isUpperFieldChar reports whether c continues an uppercase identifier
+func isUpperFieldChar(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -2,13 +2,14 @@ package ast
 
 import (
 	"fmt"
+	"math"
 	"strings"
 
 	"github.com/Daemon0x00000000/sel/internal/vm"
 )
 
 type Node interface {
-	compile() ([]byte, error)
+	compile(c *compiler) ([]byte, error)
 }
 
 type LogicalNode struct {
@@ -51,23 +52,50 @@ func treeString(node Node, prefix string, isLast bool) string {
 		if err != nil {
 			return ""
 		}
+
+	case *QuantifierNode:
+		_, err := fmt.Fprintf(&sb, "%s%s%s %v\n", prefix, connector, n.name, n.array)
+		if err != nil {
+			return ""
+		}
+		sb.WriteString(treeString(n.body, prefix+ext, true))
 	}
 
 	return sb.String()
 }
 
-func (n *LogicalNode) compile() ([]byte, error) {
-	leftBytes, err := n.left.compile()
+func (n *LogicalNode) compile(c *compiler) ([]byte, error) {
+	leftBytes, err := n.left.compile(c)
 	if err != nil {
 		return nil, err
 	}
-	rightBytes, err := n.right.compile()
+	if n.operator == vm.OP_AND || n.operator == vm.OP_OR {
+		c.stack(-1) // the jump pops the left result when it falls through
+	}
+	rightBytes, err := n.right.compile(c)
 	if err != nil {
 		return nil, err
 	}
 
-	bytes := append(leftBytes, rightBytes...)
-	return append(bytes, vm.SerializeOperator(n.operator)...), nil
+	// AND / OR short-circuit: when the left side decides, jump over the right
+	// side and keep the left result as the result
+	var jump vm.OpCode
+	switch n.operator {
+	case vm.OP_AND:
+		jump = vm.JUMP_IF_FALSE_OR_POP
+	case vm.OP_OR:
+		jump = vm.JUMP_IF_TRUE_OR_POP
+	default:
+		c.stack(-1) // binary operator: 2 operands, 1 result
+		bytes := append(leftBytes, rightBytes...)
+		return append(bytes, vm.SerializeOperator(n.operator)...), nil
+	}
+
+	if len(rightBytes) > math.MaxInt32 {
+		return nil, fmt.Errorf("right operand of %s too large to jump over: %d bytes", n.operatorStr, len(rightBytes))
+	}
+	bytes := append(leftBytes, vm.SerializeJump(jump, int32(len(rightBytes)))...)
+	return append(bytes, rightBytes...), nil
 }
 
 type NotNode struct {
@@ -75,8 +103,8 @@ type NotNode struct {
 }
 
 // NOT
-func (n *NotNode) compile() ([]byte, error) {
-	childBytes, err := n.operand.compile()
+func (n *NotNode) compile(c *compiler) ([]byte, error) {
+	childBytes, err := n.operand.compile(c)
 	if err != nil {
 		return nil, err
 	}
@@ -84,27 +112,71 @@ func (n *NotNode) compile() ([]byte, error) {
 }
 
 type ComparisonNode struct {
-	left        Field
-	right       interface{}
+	left        Operand
+	right       interface{} // literal value, []interface{} for IN, or a call Operand
 	operator    vm.OpCode
 	operatorStr ComparisonOperator
+	any         bool // left is a wildcard path, one element must match
 }
 
 func (n *ComparisonNode) String() string {
 	return fmt.Sprintf("%v %v %v", n.left, n.operator, n.right)
 }
 
-// LOAD_GLOBAL <length> <field>
-// PUSH <type> <length> <data (right)>
-// OPERATOR
-func (n *ComparisonNode) compile() ([]byte, error) {
-	bytes := vm.SerializeLoadGlobal(string(n.left))
-
-	pushBytes, err := vm.SerializePush(n.right)
+// <left operand>
+// PUSH <type> <length> <data (right)> | <right call>
+// OPERATOR | MATCH_ANY OPERATOR
+func (n *ComparisonNode) compile(c *compiler) ([]byte, error) {
+	bytes, err := n.left.compileOperand(c)
 	if err != nil {
 		return nil, err
 	}
-	bytes = append(bytes, pushBytes...)
 
+	if call, ok := n.right.(Operand); ok {
+		callBytes, err := call.compileOperand(c)
+		if err != nil {
+			return nil, err
+		}
+		bytes = append(bytes, callBytes...)
+	} else {
+		pushBytes, err := vm.SerializePush(n.right)
+		if err != nil {
+			return nil, err
+		}
+		bytes = append(bytes, pushBytes...)
+		c.stack(1)
+	}
+
+	c.stack(-1) // operator: 2 operands, 1 result
+	if n.any {
+		return append(bytes, vm.SerializeMatchAny(n.operator)...), nil
+	}
 	return append(bytes, vm.SerializeOperator(n.operator)...), nil
 }
+
+type QuantifierNode struct {
+	operator vm.OpCode // ANY_OF, ALL_OF or NONE_OF
+	name     string
+	array    Field
+	body     Node // condition on the fields of each element
+}
+
+// <array field>
+// ANY_OF | ALL_OF | NONE_OF <index of the program of the condition>
+func (n *QuantifierNode) compile(c *compiler) ([]byte, error) {
+	bytes, err := n.array.compileOperand(c)
+	if err != nil {
+		return nil, err
+	}
+
+	nested := c.nested()
+	body, err := n.body.compile(nested)
+	if err != nil {
+		return nil, fmt.Errorf("condition of %s: %w", n.name, err)
+	}
+	index := len(c.programs)
+	c.programs = append(c.programs, nested.program(body))
+
+	// the quantifier replaces the array with its result
+	return append(bytes, vm.SerializeQuantifier(n.operator, index)...), nil
+}
@@ -0,0 +1,260 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Daemon0x00000000/sel/internal/vm"
+)
+
+// Operand is a value compared by a comparison: a field, a function call or,
+// as a call argument, a literal. Compiled, it pushes one value.
+type Operand interface {
+	compileOperand(c *compiler) ([]byte, error)
+	evaluate(data map[string]interface{}) (vm.Value, error)
+	kind() vm.Kind // known at compile time, or vm.KindAny
+	String() string
+}
+
+func (f Field) String() string {
+	return string(f)
+}
+
+// LOAD_GLOBAL <slot>
+func (f Field) compileOperand(c *compiler) ([]byte, error) {
+	slot, err := c.slot(f)
+	if err != nil {
+		return nil, err
+	}
+	c.stack(1)
+	return vm.SerializeLoadGlobal(slot), nil
+}
+
+// a field has the type of the record value, a wildcard path is an array
+func (f Field) kind() vm.Kind {
+	if f.isWildcard() {
+		return vm.KindArray
+	}
+	return vm.KindAny
+}
+
+// isWildcard reports whether the path iterates an array: items[*].sku
+func (f Field) isWildcard() bool {
+	return strings.Contains(string(f), "[*]")
+}
+
+// evaluate reads the field like LoadRecords and LOAD_GLOBAL
+func (f Field) evaluate(data map[string]interface{}) (vm.Value, error) {
+	raw, exists, err := vm.Lookup(data, string(f))
+	if err != nil {
+		return vm.Value{}, fmt.Errorf("field %s: %v", f, err)
+	}
+	if !exists {
+		return vm.Value{}, fmt.Errorf("undefined global variable: %s", f)
+	}
+	value, err := vm.ToValue(raw)
+	if err != nil {
+		return vm.Value{}, fmt.Errorf("unsupported type for field %s: %v", f, err)
+	}
+	return value, nil
+}
+
+// Literal is a constant argument of a call: a quoted string or a number
+type Literal struct {
+	value interface{}
+}
+
+func (l Literal) String() string {
+	if s, ok := l.value.(string); ok {
+		return "'" + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), "'", `\'`) + "'"
+	}
+	return fmt.Sprintf("%v", l.value)
+}
+
+// PUSH <type> <length> <data>
+func (l Literal) compileOperand(c *compiler) ([]byte, error) {
+	bytes, err := vm.SerializePush(l.value)
+	if err != nil {
+		return nil, err
+	}
+	c.stack(1)
+	return bytes, nil
+}
+
+func (l Literal) kind() vm.Kind {
+	value, err := vm.ToValue(l.value)
+	if err != nil {
+		return vm.KindAny
+	}
+	return vm.KindOf(value.Type)
+}
+
+func (l Literal) evaluate(map[string]interface{}) (vm.Value, error) {
+	return vm.ToValue(l.value)
+}
+
+// ArithmeticNode is "left op right" between two operands
+type ArithmeticNode struct {
+	operator    vm.OpCode
+	operatorStr ArithmeticOperator
+	left, right Operand
+}
+
+// newArithmetic checks the kinds known at compile time: booleans, arrays
+// and quoted strings cannot be computed
+func newArithmetic(op ArithmeticOperator, left, right Operand, pos int) (*ArithmeticNode, error) {
+	for _, operand := range []Operand{left, right} {
+		kind := operand.kind()
+		if lit, ok := operand.(Literal); ok && kind == vm.KindString {
+			return nil, fmt.Errorf("%s at position %d requires numbers, got %s", op, pos, lit)
+		}
+		if kind == vm.KindBool || kind == vm.KindArray {
+			return nil, fmt.Errorf("%s at position %d requires numbers, %s is %v", op, pos, operand, kind)
+		}
+	}
+	return &ArithmeticNode{operator: arithmeticOperators[op], operatorStr: op, left: left, right: right}, nil
+}
+
+// String adds the parentheses the precedence needs: (a + b) * c, a - (b - c)
+func (n *ArithmeticNode) String() string {
+	precedence := arithmeticPrecedence[n.operatorStr]
+	left, right := n.left.String(), n.right.String()
+	if child, ok := n.left.(*ArithmeticNode); ok && arithmeticPrecedence[child.operatorStr] < precedence {
+		left = "(" + left + ")"
+	}
+	if child, ok := n.right.(*ArithmeticNode); ok && arithmeticPrecedence[child.operatorStr] <= precedence {
+		right = "(" + right + ")"
+	}
+	return left + " " + string(n.operatorStr) + " " + right
+}
+
+// <left>
+// <right>
+// OP_ADD | OP_SUB | OP_MUL | OP_DIV | OP_MOD
+func (n *ArithmeticNode) compileOperand(c *compiler) ([]byte, error) {
+	bytes, err := n.left.compileOperand(c)
+	if err != nil {
+		return nil, err
+	}
+	rightBytes, err := n.right.compileOperand(c)
+	if err != nil {
+		return nil, err
+	}
+	c.stack(-1) // 2 operands, 1 result
+	bytes = append(bytes, rightBytes...)
+	return append(bytes, vm.SerializeOperator(n.operator)...), nil
+}
+
+// the result is a float when an operand is, strings holding numbers are
+// only known at runtime
+func (n *ArithmeticNode) kind() vm.Kind {
+	left, right := n.left.kind(), n.right.kind()
+	switch {
+	case left == vm.KindFloat || right == vm.KindFloat:
+		return vm.KindFloat
+	case left == vm.KindInteger && right == vm.KindInteger:
+		return vm.KindInteger
+	}
+	return vm.KindAny
+}
+
+func (n *ArithmeticNode) evaluate(data map[string]interface{}) (vm.Value, error) {
+	left, err := n.left.evaluate(data)
+	if err != nil {
+		return vm.Value{}, err
+	}
+	right, err := n.right.evaluate(data)
+	if err != nil {
+		return vm.Value{}, err
+	}
+	return vm.Apply(n.operator, left, right)
+}
+
+// AggregateNode is COUNT, SUM, AVG, MIN or MAX over an array operand
+type AggregateNode struct {
+	operator vm.OpCode
+	name     string
+	arg      Operand
+}
+
+func (n *AggregateNode) String() string {
+	return n.name + "(" + n.arg.String() + ")"
+}
+
+// <arg>
+// OP_COUNT | OP_SUM | OP_AVG | OP_MIN | OP_MAX
+func (n *AggregateNode) compileOperand(c *compiler) ([]byte, error) {
+	bytes, err := n.arg.compileOperand(c)
+	if err != nil {
+		return nil, err
+	}
+	return append(bytes, vm.SerializeOperator(n.operator)...), nil // 1 operand, 1 result
+}
+
+// SUM, MIN and MAX depend on the elements
+func (n *AggregateNode) kind() vm.Kind {
+	switch n.operator {
+	case vm.OP_COUNT:
+		return vm.KindInteger
+	case vm.OP_AVG:
+		return vm.KindFloat
+	}
+	return vm.KindAny
+}
+
+func (n *AggregateNode) evaluate(data map[string]interface{}) (vm.Value, error) {
+	arg, err := n.arg.evaluate(data)
+	if err != nil {
+		return vm.Value{}, err
+	}
+	return vm.Apply(n.operator, arg)
+}
+
+// CallNode is a call to a registered native function
+type CallNode struct {
+	fn   vm.Function
+	args []Operand
+}
+
+func (n *CallNode) String() string {
+	args := make([]string, len(n.args))
+	for i, arg := range n.args {
+		args[i] = arg.String()
+	}
+	return n.fn.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// <args...>
+// CALL_NATIVE <index> <argc>
+func (n *CallNode) compileOperand(c *compiler) ([]byte, error) {
+	var bytes []byte
+	for _, arg := range n.args {
+		argBytes, err := arg.compileOperand(c)
+		if err != nil {
+			return nil, err
+		}
+		bytes = append(bytes, argBytes...)
+	}
+	c.stack(1 - len(n.args)) // the arguments are replaced by the result
+	return append(bytes, vm.SerializeCallNative(c.native(n.fn), len(n.args))...), nil
+}
+
+func (n *CallNode) kind() vm.Kind {
+	return n.fn.Result
+}
+
+func (n *CallNode) evaluate(data map[string]interface{}) (vm.Value, error) {
+	args := make([]vm.Value, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.evaluate(data)
+		if err != nil {
+			return vm.Value{}, err
+		}
+		args[i] = value
+	}
+	result, err := n.fn.Func(args)
+	if err != nil {
+		return vm.Value{}, fmt.Errorf("native function failed: %v", err)
+	}
+	return result, nil
+}
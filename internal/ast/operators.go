@@ -25,6 +25,14 @@ const (
 
 // !=, !IN, !CONTAINS, !MATCHES
 
+const (
+	ADD      ArithmeticOperator = "+"
+	SUBTRACT ArithmeticOperator = "-"
+	MULTIPLY ArithmeticOperator = "*"
+	DIVIDE   ArithmeticOperator = "/"
+	MODULO   ArithmeticOperator = "%"
+)
+
 var logicalOperatorsOrdered = []LogicalOperator{
 	XOR,
 	OR,
@@ -44,6 +52,46 @@ var comparisonOpsOrdered = []ComparisonOperator{
 	EQUALS,                // "=" = 1 char
 }
 
+// operators working on text, their literals are never typed as numbers
+var stringComparisonOperators = map[ComparisonOperator]bool{
+	STARTS_WITH: true,
+	ENDS_WITH:   true,
+	CONTAINS:    true,
+}
+
+// arithmetic precedence: * / % bind tighter than + -
+var arithmeticPrecedence = map[ArithmeticOperator]int{
+	ADD:      1,
+	SUBTRACT: 1,
+	MULTIPLY: 2,
+	DIVIDE:   2,
+	MODULO:   2,
+}
+
+var arithmeticOperators = ArithmeticOperatorMapping{
+	ADD:      vm.OP_ADD,
+	SUBTRACT: vm.OP_SUB,
+	MULTIPLY: vm.OP_MUL,
+	DIVIDE:   vm.OP_DIV,
+	MODULO:   vm.OP_MOD,
+}
+
+// aggregations over an array, compiled to their own opcode: they are not
+// native functions and cannot be registered
+var aggregateOperators = map[string]vm.OpCode{
+	"COUNT": vm.OP_COUNT,
+	"SUM":   vm.OP_SUM,
+	"AVG":   vm.OP_AVG,
+	"MIN":   vm.OP_MIN,
+	"MAX":   vm.OP_MAX,
+}
+
+var quantifierOperators = map[string]vm.OpCode{
+	"ANY":  vm.ANY_OF,
+	"ALL":  vm.ALL_OF,
+	"NONE": vm.NONE_OF,
+}
+
 var logicalOperators = LogicalOperatorMapping{
 	AND: vm.OP_AND,
 	OR:  vm.OP_OR,
@@ -2,20 +2,48 @@ package ast
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
+
+	"github.com/Daemon0x00000000/sel/internal/vm"
 )
 
+// builtins is the standard library available to Parse, it is never modified
+var builtins = vm.Builtins()
+
+// Parse parses an expression that may call the standard library functions
 func Parse(expression string) (*AST, error) {
+	return ParseWith(expression, builtins)
+}
+
+// ParseWith parses an expression that may call the functions registered in
+// funcs, as NAME(arg, ...) on either side of a comparison. Unknown names,
+// wrong argument counts and arguments of the wrong kind are parse errors.
+func ParseWith(expression string, funcs *vm.Functions) (*AST, error) {
 	ast := newAST()
 
 	if err := validateParentheses(expression); err != nil {
 		return nil, err
 	}
 
-	rootNode, err := parseExpr(expression)
+	tokens, err := tokenize(expression, funcs)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, funcs: funcs}
+	if p.peek().Type == TOKEN_EOF {
+		return nil, fmt.Errorf("empty expression")
+	}
+
+	rootNode, err := p.parseLogical(0)
 	if err != nil {
 		return nil, err
 	}
+	if tok := p.peek(); tok.Type != TOKEN_EOF {
+		return nil, fmt.Errorf("unexpected %v at position %d", tok.Type, tok.Pos)
+	}
 
 	ast.root = rootNode
 	return ast, nil
@@ -24,9 +52,23 @@ func Parse(expression string) (*AST, error) {
 func validateParentheses(expr string) error {
 	depth := 0
 	openPositions := make([]int, 0)
+	inQuotes := false
 
-	for i, char := range expr {
-		if char == '(' {
+	for i := 0; i < len(expr); i++ {
+		char := expr[i]
+
+		if inQuotes {
+			if char == '\\' {
+				i++ // skip escaped char
+			} else if char == '\'' {
+				inQuotes = false
+			}
+			continue
+		}
+
+		if char == '\'' {
+			inQuotes = true
+		} else if char == '(' {
 			depth++
 			openPositions = append(openPositions, i)
 		} else if char == ')' {
@@ -59,263 +101,442 @@ func validateParentheses(expr string) error {
 	return nil
 }
 
-func parseExpr(expr string) (Node, error) {
-	expr = strings.TrimSpace(expr)
+type parser struct {
+	tokens []Token
+	pos    int
+	funcs  *vm.Functions
+}
+
+func (p *parser) peek() Token {
+	return p.tokens[p.pos]
+}
 
-	// strip outer parentheses
-	if inner, ok := stripOuterParens(expr); ok {
-		return parseExpr(inner)
+func (p *parser) next() Token {
+	tok := p.tokens[p.pos]
+	if tok.Type != TOKEN_EOF {
+		p.pos++
 	}
+	return tok
+}
 
-	// Logical operators first (lower priority than NOT in parsing order)
-	for _, logicalOp := range logicalOperatorsOrdered {
-		idx := findOperatorOutsideParens(expr, string(logicalOp))
-		if idx == -1 {
-			continue
-		}
+func (p *parser) expect(typ TokenType) (Token, error) {
+	tok := p.next()
+	if tok.Type != typ {
+		return tok, fmt.Errorf("expected %v, got %v at position %d", typ, tok.Type, tok.Pos)
+	}
+	return tok, nil
+}
 
-		left := strings.TrimSpace(expr[:idx])
-		right := strings.TrimSpace(expr[idx+len(logicalOp):])
+// parseLogical parses the logical operators by precedence level:
+// logicalOperatorsOrdered[0] (XOR) binds loosest, AND binds tightest
+func (p *parser) parseLogical(level int) (Node, error) {
+	if level == len(logicalOperatorsOrdered) {
+		return p.parseUnary()
+	}
 
-		leftNode, err := parseExpr(left)
-		if err != nil {
-			return nil, fmt.Errorf("left of %s: %w", logicalOp, err)
-		}
-		rightNode, err := parseExpr(right)
-		if err != nil {
-			return nil, fmt.Errorf("right of %s: %w", logicalOp, err)
-		}
+	left, err := p.parseLogical(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	op := logicalOperatorsOrdered[level]
+	tok := p.peek()
+	if tok.Type != TOKEN_LOGICAL || LogicalOperator(tok.Value) != op {
+		return left, nil
+	}
+	p.next()
 
-		return &LogicalNode{
-			operator:    logicalOperators[logicalOp],
-			operatorStr: logicalOp,
-			left:        leftNode,
-			right:       rightNode,
-		}, nil
+	right, err := p.parseLogical(level)
+	if err != nil {
+		return nil, fmt.Errorf("right of %s: %w", op, err)
 	}
 
-	// NOT - handle ! prefix (including !! for double NOT or !field=value)
-	if strings.HasPrefix(expr, "!") {
-		rest := strings.TrimSpace(expr[1:])
-		node, err := parseExpr(rest)
+	return &LogicalNode{
+		operator:    logicalOperators[op],
+		operatorStr: op,
+		left:        left,
+		right:       right,
+	}, nil
+}
+
+// NOT - handle ! prefix (including !! for double NOT or !field=value)
+func (p *parser) parseUnary() (Node, error) {
+	if p.peek().Type == TOKEN_NOT {
+		p.next()
+		node, err := p.parseUnary()
 		if err != nil {
 			return nil, err
 		}
 		return &NotNode{operand: node}, nil
 	}
-
-	return parseComparison(expr)
+	return p.parsePrimary()
 }
 
-func stripOuterParens(expr string) (string, bool) {
-	if !strings.HasPrefix(expr, "(") || !strings.HasSuffix(expr, ")") {
-		return expr, false
-	}
-	depth := 0
-	for i, c := range expr {
-		if c == '(' {
-			depth++
-		} else if c == ')' {
-			depth--
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.peek()
+	switch tok.Type {
+	case TOKEN_LPAREN:
+		if !p.isGroup() {
+			return p.parseComparison() // (a+b)*c>10
 		}
-		if depth == 0 && i < len(expr)-1 {
-			return expr, false
+		p.next()
+		node, err := p.parseLogical(0)
+		if err != nil {
+			return nil, err
 		}
-	}
-	return expr[1 : len(expr)-1], true
-}
-
-func parseComparison(expr string) (Node, error) {
-	expr = strings.TrimSpace(expr)
+		if _, err := p.expect(TOKEN_RPAREN); err != nil {
+			return nil, err
+		}
+		return node, nil
 
-	var opFound ComparisonOperator
-	var opPos int = -1
-	var isNegated bool
+	case TOKEN_QUANTIFIER:
+		return p.parseQuantifier()
 
-	for _, op := range comparisonOpsOrdered {
-		if idx := strings.Index(expr, "!"+string(op)); idx != -1 {
-			opPos = idx
-			opFound = op
-			isNegated = true
-			break
-		}
-		if idx := strings.Index(expr, string(op)); idx != -1 {
-			opPos = idx
-			opFound = op
-			break
-		}
+	case TOKEN_FIELD, TOKEN_FUNC, TOKEN_ARITH, TOKEN_LITERAL:
+		return p.parseComparison()
 	}
 
-	if opPos == -1 {
-		return nil, fmt.Errorf("no comparison operator found in: %s", expr)
+	return nil, fmt.Errorf("expected comparison or '(', got %v at position %d", tok.Type, tok.Pos)
+}
+
+// parseQuantifier parses ANY(items, condition), the condition is parsed
+// like an expression and its fields are those of each element
+func (p *parser) parseQuantifier() (Node, error) {
+	name := p.next()
+	if _, err := p.expect(TOKEN_LPAREN); err != nil {
+		return nil, err
+	}
+	tok, err := p.expect(TOKEN_FIELD)
+	if err != nil {
+		return nil, err
+	}
+	array, err := newField(tok)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(TOKEN_COMMA); err != nil {
+		return nil, err
 	}
 
-	if opPos == 0 {
-		return nil, fmt.Errorf("missing field before operator in: %s", expr)
+	if p.peek().Type == TOKEN_RPAREN {
+		return nil, fmt.Errorf("%s at position %d expects a condition", name.Value, name.Pos)
+	}
+	body, err := p.parseLogical(0)
+	if err != nil {
+		return nil, fmt.Errorf("condition of %s: %w", name.Value, err)
+	}
+	if _, err := p.expect(TOKEN_RPAREN); err != nil {
+		return nil, err
 	}
 
-	opLen := len(opFound)
-	if isNegated {
-		opLen++
-	}
-	// double operator
-	afterOp := opPos + opLen
-	if afterOp < len(expr) {
-		remaining := expr[afterOp:]
-		for _, op := range comparisonOpsOrdered {
-			if strings.HasPrefix(remaining, string(op)) {
-				return nil, fmt.Errorf("double operator found in: %s", expr)
+	return &QuantifierNode{
+		operator: quantifierOperators[name.Value],
+		name:     name.Value,
+		array:    array,
+		body:     body,
+	}, nil
+}
+
+// isGroup reports whether the '(' at pos groups conditions: its ')' is
+// followed by a logical operator, ')' or the end, not by an operator
+func (p *parser) isGroup() bool {
+	depth := 0
+	for i := p.pos; i < len(p.tokens); i++ {
+		switch p.tokens[i].Type {
+		case TOKEN_LPAREN:
+			depth++
+		case TOKEN_RPAREN:
+			if depth--; depth == 0 {
+				next := p.tokens[i+1].Type // TOKEN_EOF ends the tokens
+				return next == TOKEN_LOGICAL || next == TOKEN_RPAREN || next == TOKEN_EOF
 			}
 		}
 	}
+	return true
+}
 
-	left := Field(strings.TrimSpace(expr[:opPos]))
-	rawRight := strings.TrimSpace(expr[opPos+opLen:])
-
-	values, err := parseValues(rawRight)
+func (p *parser) parseComparison() (Node, error) {
+	left, err := p.parseLeft()
+	if err != nil {
+		return nil, err
+	}
+	opTok, err := p.expect(TOKEN_OPERATOR)
 	if err != nil {
 		return nil, err
 	}
 
+	isNegated := strings.HasPrefix(opTok.Value, "!")
+	opFound := ComparisonOperator(strings.TrimPrefix(opTok.Value, "!"))
+
+	if p.peek().Type == TOKEN_FUNC {
+		right, err := p.parseRightCall(opFound)
+		if err != nil {
+			return nil, err
+		}
+		return newComparison(left, opFound, right, isNegated), nil
+	}
+
+	var values []Token
+	for {
+		value, err := p.expect(TOKEN_LITERAL)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if p.peek().Type != TOKEN_COMMA {
+			break
+		}
+		p.next()
+	}
+
 	var right interface{}
 	if opFound == IN {
 		arr := make([]interface{}, len(values))
 		for i, v := range values {
-			arr[i] = v
+			arr[i] = literalValue(v, opFound)
 		}
 		right = arr
 	} else {
 		if len(values) != 1 {
-			return nil, fmt.Errorf("operator %s expects single value, got %d", opFound, len(values))
+			return nil, fmt.Errorf("operator %s at position %d expects single value, got %d", opFound, opTok.Pos, len(values))
 		}
-		right = values[0]
+		right = literalValue(values[0], opFound)
 	}
 
+	return newComparison(left, opFound, right, isNegated), nil
+}
+
+// newComparison builds the node of "left op right", under a NOT for !op
+func newComparison(left Operand, op ComparisonOperator, right interface{}, isNegated bool) Node {
 	node := &ComparisonNode{
 		left:        left,
-		operator:    comparisonOperators[opFound],
-		operatorStr: opFound,
+		operator:    comparisonOperators[op],
+		operatorStr: op,
 		right:       right,
 	}
+	if field, ok := left.(Field); ok && field.isWildcard() {
+		node.any = true
+	}
 
 	if isNegated {
-		return &NotNode{operand: node}, nil
+		return &NotNode{operand: node}
 	}
-	return node, nil
+	return node
 }
 
-func findOperatorOutsideParens(expr string, operator string) int {
-	depth := 0
-	inQuotes := false
-	escaped := false
+// parseRightCall parses a call used as the right operand: IN needs it to
+// return an array
+func (p *parser) parseRightCall(op ComparisonOperator) (Operand, error) {
+	tok := p.next()
+	call, err := p.parseCall(tok)
+	if err != nil {
+		return nil, err
+	}
+	if op == IN && !vm.KindArray.Accepts(call.kind()) {
+		return nil, fmt.Errorf("IN at position %d expects an array, %s returns %v", tok.Pos, tok.Value, call.kind())
+	}
+	return call, nil
+}
 
-	for i := 0; i < len(expr); i++ {
-		char := expr[i]
+// parseLeft parses the left operand of a comparison. A lone identifier is
+// a field even when it looks like a number.
+func (p *parser) parseLeft() (Operand, error) {
+	tok := p.peek()
+	if tok.Type == TOKEN_FIELD && p.tokens[p.pos+1].Type == TOKEN_OPERATOR {
+		p.next()
+		return newField(tok)
+	}
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := left.(Literal); ok {
+		return nil, fmt.Errorf("expected field or function at position %d", tok.Pos)
+	}
+	return left, nil
+}
 
-		if escaped {
-			escaped = false
-			continue
-		}
+// newField checks the segments of a path: caller.department.name,
+// items[0].sku, items[*].sku
+func newField(tok Token) (Field, error) {
+	if err := vm.CheckPath(tok.Value); err != nil {
+		return "", fmt.Errorf("invalid field path %q at position %d: %v", tok.Value, tok.Pos, err)
+	}
+	return Field(tok.Value), nil
+}
 
-		if char == '\\' && inQuotes {
-			escaped = true
-			continue
-		}
+// parseOperand parses an arithmetic expression: + and - bind looser than
+// *, / and %, all are left-associative
+func (p *parser) parseOperand() (Operand, error) {
+	return p.parseArithmetic(1)
+}
 
-		if char == '\'' {
-			inQuotes = !inQuotes
-		}
+func (p *parser) parseArithmetic(precedence int) (Operand, error) {
+	if precedence > arithmeticPrecedence[MULTIPLY] {
+		return p.parseFactor()
+	}
 
-		if inQuotes {
-			continue
+	left, err := p.parseArithmetic(precedence + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		op := ArithmeticOperator(tok.Value)
+		if tok.Type != TOKEN_ARITH || arithmeticPrecedence[op] != precedence {
+			return left, nil
 		}
-
-		if char == '(' {
-			depth++
-		} else if char == ')' {
-			depth--
+		p.next()
+		right, err := p.parseArithmetic(precedence + 1)
+		if err != nil {
+			return nil, fmt.Errorf("right of %s: %w", op, err)
 		}
-
-		if depth == 0 && strings.HasPrefix(expr[i:], operator) {
-			return i
+		if left, err = newArithmetic(op, left, right, tok.Pos); err != nil {
+			return nil, err
 		}
 	}
-
-	return -1
 }
 
-func parseValues(input string) ([]string, error) {
-	input = strings.TrimSpace(input)
-
-	if !strings.Contains(input, "'") {
-		values := strings.Split(input, ",")
-		for i := range values {
-			values[i] = strings.TrimSpace(values[i])
+// parseFactor parses a field, a number, a quoted string, a call, an
+// operand in parentheses or a negated factor
+func (p *parser) parseFactor() (Operand, error) {
+	tok := p.next()
+	switch tok.Type {
+	case TOKEN_FIELD:
+		if isNumber(tok.Value) {
+			return Literal{value: literalValue(tok, "")}, nil
+		}
+		return newField(tok)
+	case TOKEN_LITERAL:
+		return Literal{value: tok.Value}, nil
+	case TOKEN_FUNC:
+		return p.parseCall(tok)
+	case TOKEN_LPAREN:
+		operand, err := p.parseOperand()
+		if err != nil {
+			return nil, err
 		}
-		return values, nil
+		if _, err := p.expect(TOKEN_RPAREN); err != nil {
+			return nil, err
+		}
+		return operand, nil
+	case TOKEN_ARITH:
+		if ArithmeticOperator(tok.Value) != SUBTRACT {
+			break
+		}
+		if next := p.peek(); next.Type == TOKEN_FIELD && isNumber(next.Value) {
+			p.next()
+			return Literal{value: literalValue(Token{Value: "-" + next.Value}, "")}, nil
+		}
+		operand, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return newArithmetic(SUBTRACT, Literal{value: 0}, operand, tok.Pos)
 	}
+	return nil, fmt.Errorf("expected field, number or function, got %v at position %d", tok.Type, tok.Pos)
+}
 
-	var values []string
-	var current strings.Builder
-	inQuotes := false
-	escaped := false
-	wasQuoted := false
-
-	for i := 0; i < len(input); i++ {
-		char := input[i]
-
-		switch {
-		case escaped:
-			switch char {
-			case 'n':
-				current.WriteByte('\n')
-			case 't':
-				current.WriteByte('\t')
-			case 'r':
-				current.WriteByte('\r')
-			case '\\':
-				current.WriteByte('\\')
-			case '\'':
-				current.WriteByte('\'')
-			default:
-				current.WriteByte(char)
-			}
-			escaped = false
-
-		case char == '\\' && inQuotes:
-			escaped = true
-
-		case char == '\'':
-			inQuotes = !inQuotes
-			wasQuoted = true
+func (p *parser) parseCall(name Token) (Operand, error) {
+	if op, ok := aggregateOperators[name.Value]; ok {
+		return p.parseAggregate(name, op)
+	}
+	fn, _ := p.funcs.Lookup(name.Value) // the lexer only emits registered names
+	if _, err := p.expect(TOKEN_LPAREN); err != nil {
+		return nil, err
+	}
 
-		case char == ',' && !inQuotes:
-			val := current.String()
-			if !wasQuoted {
-				val = strings.TrimSpace(val)
+	var args []Operand
+	if p.peek().Type != TOKEN_RPAREN {
+		for {
+			arg, err := p.parseOperand()
+			if err != nil {
+				return nil, fmt.Errorf("argument of %s: %w", fn.Name, err)
 			}
-			values = append(values, val)
-			current.Reset()
-			wasQuoted = false
-
-		default:
-			current.WriteByte(char)
+			args = append(args, arg)
+			if p.peek().Type != TOKEN_COMMA {
+				break
+			}
+			p.next()
 		}
 	}
+	if _, err := p.expect(TOKEN_RPAREN); err != nil {
+		return nil, err
+	}
 
-	val := current.String()
-	if !wasQuoted {
-		val = strings.TrimSpace(val)
+	if err := fn.CheckArgs(len(args)); err != nil {
+		return nil, fmt.Errorf("%v at position %d", err, name.Pos)
 	}
-	values = append(values, val)
+	kinds := make([]vm.Kind, len(args))
+	for i, arg := range args {
+		kinds[i] = arg.kind()
+	}
+	if err := fn.CheckKinds(kinds); err != nil {
+		return nil, fmt.Errorf("%v at position %d", err, name.Pos)
+	}
+	return &CallNode{fn: fn, args: args}, nil
+}
 
-	if escaped {
-		return nil, fmt.Errorf("incomplete escape sequence at end of: %s", input)
+// parseAggregate parses COUNT(array) and the other aggregations: one
+// operand, an array when its kind is known
+func (p *parser) parseAggregate(name Token, op vm.OpCode) (*AggregateNode, error) {
+	if _, err := p.expect(TOKEN_LPAREN); err != nil {
+		return nil, err
+	}
+	if p.peek().Type == TOKEN_RPAREN {
+		return nil, fmt.Errorf("%s expects 1 argument, got 0 at position %d", name.Value, name.Pos)
+	}
+	arg, err := p.parseOperand()
+	if err != nil {
+		return nil, fmt.Errorf("argument of %s: %w", name.Value, err)
+	}
+	if p.peek().Type == TOKEN_COMMA {
+		return nil, fmt.Errorf("%s expects 1 argument at position %d", name.Value, name.Pos)
 	}
+	if _, err := p.expect(TOKEN_RPAREN); err != nil {
+		return nil, err
+	}
+	if !vm.KindArray.Accepts(arg.kind()) {
+		return nil, fmt.Errorf("%s at position %d expects an array, %s is %v", name.Value, name.Pos, arg, arg.kind())
+	}
+	return &AggregateNode{operator: op, name: name.Value, arg: arg}, nil
+}
 
-	if inQuotes {
-		return nil, fmt.Errorf("unclosed quote in: %s", input)
+// literalValue types unquoted numeric literals, except for the string
+// operators: integers become int (int64 beyond 32 bits, uint64 beyond
+// MaxInt64) and decimals become float64. Quoting a literal ('18') keeps it a
+// string, and so does a leading zero (zip=01234), which marks a code rather
+// than a number.
+func literalValue(tok Token, op ComparisonOperator) interface{} {
+	if tok.Quoted || stringComparisonOperators[op] || hasLeadingZero(tok.Value) {
+		return tok.Value
+	}
+	if n, err := strconv.ParseInt(tok.Value, 10, 64); err == nil {
+		if n >= math.MinInt32 && n <= math.MaxInt32 {
+			return int(n)
+		}
+		return n
+	}
+	if n, err := strconv.ParseUint(tok.Value, 10, 64); err == nil {
+		return n
 	}
+	if vm.IsDecimal(tok.Value) {
+		if f, err := strconv.ParseFloat(tok.Value, 64); err == nil {
+			return f
+		}
+	}
+	return tok.Value
+}
+
+// hasLeadingZero reports whether an integer part starts with a 0 followed by
+// another digit: 007, -01.5
+func hasLeadingZero(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	return len(s) > 1 && s[0] == '0' && s[1] >= '0' && s[1] <= '9'
+}
 
-	return values, nil
+// isNumber reports whether an unquoted literal is typed as a number
+func isNumber(s string) bool {
+	_, isString := literalValue(Token{Value: s}, "").(string)
+	return !isString
 }
@@ -5,6 +5,7 @@ import "github.com/Daemon0x00000000/sel/internal/vm"
 type Field string
 type LogicalOperator string
 type ComparisonOperator string
+type ArithmeticOperator string
 
 // []bool est un tableau de 2 valeurs
 type LogicalOperatorFunc func(results []bool) bool
@@ -14,3 +15,4 @@ type OperationCompute func(value interface{})
 
 type LogicalOperatorMapping map[LogicalOperator]vm.OpCode
 type ComparisonOperatorMapping map[ComparisonOperator]vm.OpCode
+type ArithmeticOperatorMapping map[ArithmeticOperator]vm.OpCode
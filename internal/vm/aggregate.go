@@ -0,0 +1,143 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+)
+
+var aggregateNames = map[OpCode]string{
+	OP_COUNT: "COUNT",
+	OP_SUM:   "SUM",
+	OP_AVG:   "AVG",
+	OP_MIN:   "MIN",
+	OP_MAX:   "MAX",
+}
+
+// result types known by the verifier, SUM, MIN and MAX depend on the elements
+var aggregateTypes = map[OpCode]Type{
+	OP_COUNT: TYPE_INT64,
+	OP_SUM:   typeUnknown,
+	OP_AVG:   TYPE_FLOAT64,
+	OP_MIN:   typeUnknown,
+	OP_MAX:   typeUnknown,
+}
+
+// OP_COUNT
+func (vm *VM) countHandler() error {
+	return vm.aggregateHandler(OP_COUNT)
+}
+
+// OP_SUM
+func (vm *VM) sumHandler() error {
+	return vm.aggregateHandler(OP_SUM)
+}
+
+// OP_AVG
+func (vm *VM) avgHandler() error {
+	return vm.aggregateHandler(OP_AVG)
+}
+
+// OP_MIN
+func (vm *VM) minHandler() error {
+	return vm.aggregateHandler(OP_MIN)
+}
+
+// OP_MAX
+func (vm *VM) maxHandler() error {
+	return vm.aggregateHandler(OP_MAX)
+}
+
+func (vm *VM) aggregateHandler(op OpCode) error {
+	array, err := vm.pop()
+	if err != nil {
+		return err
+	}
+
+	result, err := aggregate(op, array)
+	if err != nil {
+		return err
+	}
+	vm.push(result)
+	return nil
+}
+
+// aggregate reduces an array:
+//   - COUNT is the number of elements, 0 for an empty array
+//   - SUM adds the elements like +: integers exactly, a FLOAT64 as soon as
+//     one element is a float, strings holding a number count as that
+//     number. The sum of an empty array is INT64 0.
+//   - AVG is the FLOAT64 mean of the elements, read like SUM
+//   - MIN and MAX return the lowest and highest element, ordered like the
+//     comparison operators. The elements keep their type.
+//
+// AVG, MIN and MAX of an empty array are errors, as are elements SUM and AVG
+// cannot read as numbers and elements MIN and MAX cannot compare.
+func aggregate(op OpCode, array Value) (Value, error) {
+	name := aggregateNames[op]
+	if array.Type != TYPE_ARRAY {
+		return Value{}, fmt.Errorf("%s requires an array, got %v", name, array.Type)
+	}
+	elems := array.Array()
+	if op == OP_COUNT {
+		return Int64Value(int64(len(elems))), nil
+	}
+	if len(elems) == 0 && op != OP_SUM {
+		return Value{}, fmt.Errorf("%s of an empty array", name)
+	}
+
+	switch op {
+	case OP_SUM:
+		sum := Int64Value(0)
+		for i, elem := range elems {
+			n, err := aggregateOperand(name, i, elem)
+			if err != nil {
+				return Value{}, err
+			}
+			if sum, err = arithmetic(OP_ADD, sum, n); err != nil {
+				return Value{}, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		return sum, nil
+
+	case OP_AVG:
+		var sum float64
+		for i, elem := range elems {
+			n, err := aggregateOperand(name, i, elem)
+			if err != nil {
+				return Value{}, err
+			}
+			sum += toFloat(n)
+		}
+		if math.IsInf(sum, 0) {
+			return Value{}, fmt.Errorf("%s: float overflow", name)
+		}
+		return Float64Value(sum / float64(len(elems))), nil
+	}
+
+	// OP_MIN, OP_MAX
+	best := elems[0]
+	for i, elem := range elems[1:] {
+		res, err := compareValues(elem, best)
+		if err != nil {
+			return Value{}, fmt.Errorf("%s element %d: %w", name, i+1, err)
+		}
+		if op == OP_MIN && res < 0 || op == OP_MAX && res > 0 {
+			best = elem
+		}
+	}
+	return best, nil
+}
+
+// aggregateOperand reads the i-th element as a number
+func aggregateOperand(name string, i int, elem Value) (Value, error) {
+	if elem.Type == TYPE_STRING {
+		if n, ok := parseNumber(elem.String()); ok {
+			return n, nil
+		}
+		return Value{}, fmt.Errorf("%s requires numbers, element %d is string %q", name, i, elem.String())
+	}
+	if !elem.isNumber() {
+		return Value{}, fmt.Errorf("%s requires numbers, element %d is %v", name, i, elem.Type)
+	}
+	return elem, nil
+}
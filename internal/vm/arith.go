@@ -0,0 +1,198 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+var arithmeticSymbols = map[OpCode]string{
+	OP_ADD: "+",
+	OP_SUB: "-",
+	OP_MUL: "*",
+	OP_DIV: "/",
+	OP_MOD: "%",
+}
+
+// OP_ADD
+func (vm *VM) addHandler() error {
+	return vm.arithmeticHandler(OP_ADD)
+}
+
+// OP_SUB
+func (vm *VM) subHandler() error {
+	return vm.arithmeticHandler(OP_SUB)
+}
+
+// OP_MUL
+func (vm *VM) mulHandler() error {
+	return vm.arithmeticHandler(OP_MUL)
+}
+
+// OP_DIV
+func (vm *VM) divHandler() error {
+	return vm.arithmeticHandler(OP_DIV)
+}
+
+// OP_MOD
+func (vm *VM) modHandler() error {
+	return vm.arithmeticHandler(OP_MOD)
+}
+
+func (vm *VM) arithmeticHandler(op OpCode) error {
+	right, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	left, err := vm.pop()
+	if err != nil {
+		return err
+	}
+
+	result, err := arithmetic(op, left, right)
+	if err != nil {
+		return err
+	}
+	vm.push(result)
+	return nil
+}
+
+// arithmetic computes left op right. Integers of every width are computed
+// exactly: the result is an INT64, or a UINT64 above math.MaxInt64, and an
+// error when it fits in neither. With a float operand the result is a
+// FLOAT64. Strings holding a number are used as that number, like in
+// comparisons. Division and modulo by zero are errors.
+func arithmetic(op OpCode, left, right Value) (Value, error) {
+	a, err := arithmeticOperand(op, left)
+	if err != nil {
+		return Value{}, err
+	}
+	b, err := arithmeticOperand(op, right)
+	if err != nil {
+		return Value{}, err
+	}
+
+	if a.Type == TYPE_FLOAT64 || b.Type == TYPE_FLOAT64 {
+		return floatArithmetic(op, toFloat(a), toFloat(b))
+	}
+	return integerArithmetic(op, a, b)
+}
+
+func arithmeticOperand(op OpCode, v Value) (Value, error) {
+	if v.Type == TYPE_STRING {
+		if n, ok := parseNumber(v.String()); ok {
+			return n, nil
+		}
+		return Value{}, fmt.Errorf("%s requires numbers, got string %q", arithmeticSymbols[op], v.String())
+	}
+	if !v.isNumber() {
+		return Value{}, fmt.Errorf("%s requires numbers, got %v", arithmeticSymbols[op], v.Type)
+	}
+	return v, nil
+}
+
+func toFloat(v Value) float64 {
+	switch {
+	case v.isSigned():
+		return float64(v.Int64())
+	case v.isUnsigned():
+		return float64(v.Uint64())
+	}
+	return v.Float64()
+}
+
+func floatArithmetic(op OpCode, a, b float64) (Value, error) {
+	var result float64
+	switch op {
+	case OP_ADD:
+		result = a + b
+	case OP_SUB:
+		result = a - b
+	case OP_MUL:
+		result = a * b
+	case OP_DIV, OP_MOD:
+		if b == 0 {
+			return Value{}, fmt.Errorf("division by zero")
+		}
+		if op == OP_DIV {
+			result = a / b
+		} else {
+			result = math.Mod(a, b)
+		}
+	}
+	if math.IsInf(result, 0) || math.IsNaN(result) {
+		return Value{}, fmt.Errorf("float overflow: %g %s %g", a, arithmeticSymbols[op], b)
+	}
+	return Float64Value(result), nil
+}
+
+// integerArithmetic works on signs and magnitudes so that any mix of signed
+// and unsigned widths is exact
+func integerArithmetic(op OpCode, a, b Value) (Value, error) {
+	aNeg, aMag := magnitude(a)
+	bNeg, bMag := magnitude(b)
+
+	var neg bool
+	var mag uint64
+	switch op {
+	case OP_ADD, OP_SUB:
+		if op == OP_SUB {
+			bNeg = !bNeg // a - b = a + (-b)
+		}
+		if aNeg == bNeg {
+			var carry uint64
+			if mag, carry = bits.Add64(aMag, bMag, 0); carry != 0 {
+				return Value{}, overflowError(op, a, b)
+			}
+			neg = aNeg
+		} else if aMag >= bMag {
+			neg, mag = aNeg, aMag-bMag
+		} else {
+			neg, mag = bNeg, bMag-aMag
+		}
+	case OP_MUL:
+		hi, lo := bits.Mul64(aMag, bMag)
+		if hi != 0 {
+			return Value{}, overflowError(op, a, b)
+		}
+		neg, mag = aNeg != bNeg, lo
+	case OP_DIV, OP_MOD:
+		if bMag == 0 {
+			return Value{}, fmt.Errorf("division by zero")
+		}
+		// truncated towards zero, the remainder has the sign of a, as in Go
+		if op == OP_DIV {
+			neg, mag = aNeg != bNeg, aMag/bMag
+		} else {
+			neg, mag = aNeg, aMag%bMag
+		}
+	}
+
+	switch {
+	case mag == 0:
+		return Int64Value(0), nil
+	case !neg && mag <= math.MaxInt64:
+		return Int64Value(int64(mag)), nil
+	case !neg:
+		return Uint64Value(mag), nil
+	case mag <= 1<<63:
+		return Int64Value(-int64(mag-1) - 1), nil
+	}
+	return Value{}, overflowError(op, a, b)
+}
+
+// magnitude splits an integer into its sign and absolute value
+func magnitude(v Value) (neg bool, mag uint64) {
+	if v.isUnsigned() {
+		return false, v.Uint64()
+	}
+	n := v.Int64()
+	if n < 0 {
+		return true, uint64(-(n + 1)) + 1 // -MinInt64 does not fit in an int64
+	}
+	return false, uint64(n)
+}
+
+func overflowError(op OpCode, a, b Value) error {
+	return fmt.Errorf("integer overflow: %s %s %s", a.numberString(), arithmeticSymbols[op], b.numberString())
+}
@@ -0,0 +1,484 @@
+package vm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	opcodesByName = make(map[string]OpCode, len(opcodeNames))
+	typesByName   = make(map[string]Type, len(typeNames))
+)
+
+func init() {
+	for op, name := range opcodeNames {
+		opcodesByName[name] = op
+	}
+	for typ, name := range typeNames {
+		if typ != typeUnknown {
+			typesByName[name] = typ
+		}
+	}
+}
+
+// Assemble turns a mnemonic listing, in the format printed by Disassemble,
+// into a program. One instruction per line:
+//
+//	      LOAD_GLOBAL "status"
+//	      PUSH STRING "active"
+//	      OP_EQ
+//	      JUMP_IF_FALSE_OR_POP end   ; label or relative offset (+8)
+//	      STORE_GLOBAL "tags" ARRAY [STRING "a", INT8 2]
+//	      CALL_NATIVE 0 2            ; function index, argument count
+//	      MATCH_ANY OP_EQ            ; comparison applied to each element
+//	      ANY_OF 0                   ; nested program index
+//	end:
+//
+// Text after ';' is a comment, a leading offset column is ignored and
+// "name:" defines a label at the next instruction. A "; program <index>"
+// line, as written by Disassemble, starts a nested program: 0 is the first
+// program of the root, 0.1 the second one nested in program 0. Nested
+// programs come in order, each has its own labels and field table. The
+// field table is built from the global names in order of appearance. The
+// program is not verified.
+func Assemble(source string, nativeFuncs []NativeFunc) (*Program, error) {
+	var root *Program
+	programs := make(map[string]*Program)
+	a, index := newAssembler(nativeFuncs), ""
+
+	finish := func() error {
+		if err := a.resolve(); err != nil {
+			if index != "" {
+				return fmt.Errorf("program %s: %w", index, err)
+			}
+			return err
+		}
+		p := NewProgram(a.bytecode, a.fields, 0, anonymous(nativeFuncs))
+		if index == "" {
+			root = p
+		} else {
+			programs[index] = p
+		}
+		return nil
+	}
+
+	for i, line := range strings.Split(source, "\n") {
+		if next, ok := programHeader(line); ok {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			if _, exists := programs[next]; exists {
+				return nil, fmt.Errorf("line %d: program %s already defined", i+1, next)
+			}
+			a, index = newAssembler(nativeFuncs), next
+			continue
+		}
+		a.lineNo = i + 1
+		if err := a.line(line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+
+	// nested programs are attached in the order of their sections, which
+	// is the order of their indexes
+	var attach func(p *Program, prefix string)
+	attach = func(p *Program, prefix string) {
+		for i := 0; ; i++ {
+			index := prefix + strconv.Itoa(i)
+			nested, ok := programs[index]
+			if !ok {
+				return
+			}
+			delete(programs, index)
+			attach(nested, index+".")
+			p.AddProgram(nested)
+		}
+	}
+	attach(root, "")
+	for index := range programs {
+		return nil, fmt.Errorf("program %s does not follow the programs before it", index)
+	}
+	return root, nil
+}
+
+// programHeader reads the index of a "; program <index>" line
+func programHeader(line string) (string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), ";")
+	if !ok {
+		return "", false
+	}
+	index, ok := strings.CutPrefix(strings.TrimSpace(rest), "program ")
+	if !ok {
+		return "", false
+	}
+	index = strings.TrimSpace(index)
+	for _, part := range strings.Split(index, ".") {
+		if !isDigits(part) {
+			return "", false
+		}
+	}
+	return index, true
+}
+
+func newAssembler(nativeFuncs []NativeFunc) *assembler {
+	return &assembler{
+		slots:   make(map[string]int),
+		labels:  make(map[string]int),
+		natives: len(nativeFuncs),
+	}
+}
+
+type assembler struct {
+	bytecode []byte
+	fields   []string
+	slots    map[string]int
+	labels   map[string]int
+	fixups   []fixup
+	natives  int
+	lineNo   int
+}
+
+// fixup is a jump to a label, patched once every label is known
+type fixup struct {
+	pos    int // offset of the int32 operand
+	label  string
+	lineNo int
+}
+
+func (a *assembler) line(line string) error {
+	tokens, err := tokenize(line)
+	if err != nil {
+		return err
+	}
+
+	// colonne d'offset du disassembler
+	if len(tokens) > 0 && isDigits(tokens[0]) {
+		tokens = tokens[1:]
+	}
+	if len(tokens) > 0 && strings.HasSuffix(tokens[0], ":") {
+		label := strings.TrimSuffix(tokens[0], ":")
+		if !isIdentifier(label) {
+			return fmt.Errorf("invalid label %q", label)
+		}
+		if _, exists := a.labels[label]; exists {
+			return fmt.Errorf("label %q already defined", label)
+		}
+		a.labels[label] = len(a.bytecode)
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	op, ok := opcodesByName[tokens[0]]
+	if !ok {
+		return fmt.Errorf("unknown mnemonic %q", tokens[0])
+	}
+	rest, err := a.instruction(op, tokens[1:])
+	if err != nil {
+		return fmt.Errorf("%v: %w", op, err)
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("%v: unexpected %q", op, rest[0])
+	}
+	return nil
+}
+
+// instruction encodes op with its operands and returns the unused tokens
+func (a *assembler) instruction(op OpCode, tokens []string) ([]string, error) {
+	switch {
+	case op == PUSH:
+		val, rest, err := parseValue(tokens)
+		if err != nil {
+			return nil, err
+		}
+		bytes, err := SerializePush(val)
+		if err != nil {
+			return nil, err
+		}
+		a.bytecode = append(a.bytecode, bytes...)
+		return rest, nil
+
+	case op == STORE_GLOBAL:
+		slot, rest, err := a.slot(tokens)
+		if err != nil {
+			return nil, err
+		}
+		val, rest, err := parseValue(rest)
+		if err != nil {
+			return nil, err
+		}
+		bytes, err := SerializeStoreGlobal(slot, val)
+		if err != nil {
+			return nil, err
+		}
+		a.bytecode = append(a.bytecode, bytes...)
+		return rest, nil
+
+	case op == LOAD_GLOBAL:
+		slot, rest, err := a.slot(tokens)
+		if err != nil {
+			return nil, err
+		}
+		a.bytecode = append(a.bytecode, SerializeLoadGlobal(slot)...)
+		return rest, nil
+
+	case op == CALL_NATIVE:
+		if len(tokens) < 2 {
+			return nil, fmt.Errorf("expected a function index and an argument count")
+		}
+		index, err := strconv.ParseUint(tokens[0], 10, 32)
+		if err != nil || int(index) >= a.natives {
+			return nil, fmt.Errorf("invalid native function index %q", tokens[0])
+		}
+		argc, err := strconv.ParseUint(tokens[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument count %q", tokens[1])
+		}
+		a.bytecode = append(a.bytecode, SerializeCallNative(int(index), int(argc))...)
+		return tokens[2:], nil
+
+	case op == MATCH_ANY:
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("expected a comparison")
+		}
+		compare, ok := opcodesByName[tokens[0]]
+		if !ok || !compare.isComparison() {
+			return nil, fmt.Errorf("invalid comparison %q", tokens[0])
+		}
+		a.bytecode = append(a.bytecode, SerializeMatchAny(compare)...)
+		return tokens[1:], nil
+
+	case op.isQuantifier():
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("expected a nested program index")
+		}
+		index, err := strconv.ParseUint(tokens[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nested program index %q", tokens[0])
+		}
+		a.bytecode = append(a.bytecode, SerializeQuantifier(op, int(index))...)
+		return tokens[1:], nil
+
+	case op.isJump():
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("expected a label or an offset")
+		}
+		target := tokens[0]
+		if target[0] == '+' || target[0] == '-' {
+			offset, err := strconv.ParseInt(target, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jump offset %q", target)
+			}
+			a.bytecode = append(a.bytecode, SerializeJump(op, int32(offset))...)
+			return tokens[1:], nil
+		}
+		if !isIdentifier(target) {
+			return nil, fmt.Errorf("invalid label %q", target)
+		}
+		a.fixups = append(a.fixups, fixup{pos: len(a.bytecode) + 1, label: target, lineNo: a.lineNo})
+		a.bytecode = append(a.bytecode, SerializeJump(op, 0)...)
+		return tokens[1:], nil
+	}
+
+	a.bytecode = append(a.bytecode, SerializeOperator(op)...)
+	return tokens, nil
+}
+
+// slot returns the global slot of the quoted field name in tokens[0]
+func (a *assembler) slot(tokens []string) (uint16, []string, error) {
+	if len(tokens) == 0 || !isQuoted(tokens[0]) {
+		return 0, nil, fmt.Errorf("expected a quoted field name")
+	}
+	name, err := strconv.Unquote(tokens[0])
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid field name %s", tokens[0])
+	}
+	slot, ok := a.slots[name]
+	if !ok {
+		if len(a.fields) >= MaxGlobals {
+			return 0, nil, fmt.Errorf("too many fields: at most %d", MaxGlobals)
+		}
+		slot = len(a.fields)
+		a.slots[name] = slot
+		a.fields = append(a.fields, name)
+	}
+	return uint16(slot), tokens[1:], nil
+}
+
+// resolve patches the jumps to labels, offsets are relative to the next
+// instruction
+func (a *assembler) resolve() error {
+	for _, f := range a.fixups {
+		target, ok := a.labels[f.label]
+		if !ok {
+			return fmt.Errorf("line %d: undefined label %q", f.lineNo, f.label)
+		}
+		offset := target - (f.pos + 4)
+		if offset < math.MinInt32 || offset > math.MaxInt32 {
+			return fmt.Errorf("line %d: label %q too far to jump to", f.lineNo, f.label)
+		}
+		binary.BigEndian.PutUint32(a.bytecode[f.pos:], uint32(int32(offset)))
+	}
+	return nil
+}
+
+// parseValue parses a typed constant, TYPE literal, into the Go value
+// SerializePush expects and returns the unused tokens
+func parseValue(tokens []string) (interface{}, []string, error) {
+	if len(tokens) < 2 {
+		return nil, nil, fmt.Errorf("expected a type and a value")
+	}
+	typ, ok := typesByName[tokens[0]]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown type %q", tokens[0])
+	}
+	lit, rest := tokens[1], tokens[2:]
+
+	switch typ {
+	case TYPE_ARRAY:
+		if lit != "[" {
+			return nil, nil, fmt.Errorf("expected '[' after ARRAY, got %q", lit)
+		}
+		array := []interface{}{}
+		for len(rest) > 0 && rest[0] != "]" {
+			if len(array) > 0 {
+				if rest[0] != "," {
+					return nil, nil, fmt.Errorf("expected ',' or ']', got %q", rest[0])
+				}
+				rest = rest[1:]
+			}
+			item, next, err := parseValue(rest)
+			if err != nil {
+				return nil, nil, fmt.Errorf("array item %d: %w", len(array), err)
+			}
+			array = append(array, item)
+			rest = next
+		}
+		if len(rest) == 0 {
+			return nil, nil, fmt.Errorf("unterminated array")
+		}
+		return array, rest[1:], nil
+
+	case TYPE_STRING:
+		if !isQuoted(lit) {
+			return nil, nil, fmt.Errorf("expected a quoted string, got %s", lit)
+		}
+		str, err := strconv.Unquote(lit)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid string %s", lit)
+		}
+		return str, rest, nil
+
+	case TYPE_BOOL:
+		b, err := strconv.ParseBool(lit)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid BOOL %q", lit)
+		}
+		return b, rest, nil
+
+	case TYPE_FLOAT64:
+		f, err := strconv.ParseFloat(lit, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid FLOAT64 %q", lit)
+		}
+		return f, rest, nil
+	}
+
+	// entiers : la largeur vient du type
+	bits := typeSize(typ) * 8
+	if typ == TYPE_UINT8 || typ == TYPE_UINT16 || typ == TYPE_UINT32 || typ == TYPE_UINT64 {
+		u, err := strconv.ParseUint(lit, 0, bits)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid %v %q", typ, lit)
+		}
+		switch typ {
+		case TYPE_UINT8:
+			return uint8(u), rest, nil
+		case TYPE_UINT16:
+			return uint16(u), rest, nil
+		case TYPE_UINT32:
+			return uint32(u), rest, nil
+		}
+		return u, rest, nil
+	}
+	i, err := strconv.ParseInt(lit, 0, bits)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %v %q", typ, lit)
+	}
+	switch typ {
+	case TYPE_INT8:
+		return int8(i), rest, nil
+	case TYPE_INT16:
+		return int16(i), rest, nil
+	case TYPE_INT32:
+		return int32(i), rest, nil
+	}
+	return i, rest, nil
+}
+
+// tokenize splits a line into words, quoted strings and the punctuation of
+// arrays, up to the first ';' outside a string
+func tokenize(line string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(line); {
+		switch c := line[i]; {
+		case c == ';':
+			return tokens, nil
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '[' || c == ']' || c == ',':
+			tokens = append(tokens, line[i:i+1])
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, line[i:end+1])
+			i = end + 1
+		default:
+			end := i
+			for end < len(line) && !strings.ContainsRune(" \t\r;[],\"", rune(line[end])) {
+				end++
+			}
+			tokens = append(tokens, line[i:end])
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+func isQuoted(token string) bool {
+	return len(token) >= 2 && token[0] == '"'
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isIdentifier(s string) bool {
+	for i, c := range s {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return s != ""
+}
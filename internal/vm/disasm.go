@@ -0,0 +1,159 @@
+package vm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var opcodeNames = map[OpCode]string{
+	PUSH:                 "PUSH",
+	POP:                  "POP",
+	STORE_GLOBAL:         "STORE_GLOBAL",
+	LOAD_GLOBAL:          "LOAD_GLOBAL",
+	CALL_NATIVE:          "CALL_NATIVE",
+	OP_EQ:                "OP_EQ",
+	OP_GT:                "OP_GT",
+	OP_LT:                "OP_LT",
+	OP_GTE:               "OP_GTE",
+	OP_LTE:               "OP_LTE",
+	OP_STARTSWITH:        "OP_STARTSWITH",
+	OP_ENDSWITH:          "OP_ENDSWITH",
+	OP_CONTAINS:          "OP_CONTAINS",
+	OP_IN:                "OP_IN",
+	OP_AND:               "OP_AND",
+	OP_OR:                "OP_OR",
+	OP_XOR:               "OP_XOR",
+	OP_NOT:               "OP_NOT",
+	JUMP:                 "JUMP",
+	JUMP_IF_FALSE:        "JUMP_IF_FALSE",
+	JUMP_IF_TRUE:         "JUMP_IF_TRUE",
+	JUMP_IF_FALSE_OR_POP: "JUMP_IF_FALSE_OR_POP",
+	JUMP_IF_TRUE_OR_POP:  "JUMP_IF_TRUE_OR_POP",
+	OP_ADD:               "OP_ADD",
+	OP_SUB:               "OP_SUB",
+	OP_MUL:               "OP_MUL",
+	OP_DIV:               "OP_DIV",
+	OP_MOD:               "OP_MOD",
+	OP_COUNT:             "OP_COUNT",
+	OP_SUM:               "OP_SUM",
+	OP_AVG:               "OP_AVG",
+	OP_MIN:               "OP_MIN",
+	OP_MAX:               "OP_MAX",
+	MATCH_ANY:            "MATCH_ANY",
+	ANY_OF:               "ANY_OF",
+	ALL_OF:               "ALL_OF",
+	NONE_OF:              "NONE_OF",
+}
+
+func (op OpCode) String() string {
+	if name, ok := opcodeNames[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("OPCODE(0x%02x)", byte(op))
+}
+
+var typeNames = map[Type]string{
+	TYPE_BOOL:    "BOOL",
+	TYPE_INT8:    "INT8",
+	TYPE_INT16:   "INT16",
+	TYPE_INT32:   "INT32",
+	TYPE_STRING:  "STRING",
+	TYPE_ARRAY:   "ARRAY",
+	TYPE_INT64:   "INT64",
+	TYPE_UINT8:   "UINT8",
+	TYPE_UINT16:  "UINT16",
+	TYPE_UINT32:  "UINT32",
+	TYPE_UINT64:  "UINT64",
+	TYPE_FLOAT64: "FLOAT64",
+	TYPE_OBJECT:  "OBJECT",
+	typeUnknown:  "UNKNOWN",
+}
+
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("TYPE(0x%02x)", byte(t))
+}
+
+// Disassemble returns a listing of the program, one instruction per line:
+// offset, mnemonic and decoded operands.
+//
+//	0000  LOAD_GLOBAL "status"
+//	0003  PUSH STRING "active"
+//	0012  OP_EQ
+//	0013  JUMP_IF_FALSE_OR_POP +8  ; -> 0026
+//
+// Nested programs follow, each after a "; program <index>" line, the index
+// of a program nested in program 0 is 0.0.
+//
+// The program does not need to be verified. When an instruction cannot be
+// decoded the listing stops before it and the error gives its offset.
+func (p *Program) Disassemble() (string, error) {
+	var sb strings.Builder
+	err := p.disassemble(&sb, "")
+	return sb.String(), err
+}
+
+// disassemble writes the listing of p then of its nested programs, prefix is
+// the index of p
+func (p *Program) disassemble(sb *strings.Builder, prefix string) error {
+	vm := p.NewVM()
+	for vm.pc < len(vm.bytecode) {
+		ins, err := vm.decode()
+		if err != nil {
+			return fmt.Errorf("cannot decode instruction at offset %04d: %w", ins.PC, err)
+		}
+		fmt.Fprintf(sb, "%04d  %s\n", ins.PC, p.formatInstruction(ins))
+	}
+	for i, nested := range p.programs {
+		index := prefix + strconv.Itoa(i)
+		fmt.Fprintf(sb, "; program %s\n", index)
+		if err := nested.disassemble(sb, index+"."); err != nil {
+			return fmt.Errorf("program %s: %w", index, err)
+		}
+	}
+	return nil
+}
+
+func (p *Program) formatInstruction(ins Instruction) string {
+	switch {
+	case ins.Op == PUSH:
+		return "PUSH " + formatValue(ins.Value)
+	case ins.Op == STORE_GLOBAL:
+		return fmt.Sprintf("STORE_GLOBAL %s %s", strconv.Quote(p.fields[ins.Slot]), formatValue(ins.Value))
+	case ins.Op == LOAD_GLOBAL:
+		return "LOAD_GLOBAL " + strconv.Quote(p.fields[ins.Slot])
+	case ins.Op == CALL_NATIVE:
+		if name := p.natives[ins.Index].Name; name != "" {
+			return fmt.Sprintf("CALL_NATIVE %d %d  ; %s", ins.Index, ins.Argc, name)
+		}
+		return fmt.Sprintf("CALL_NATIVE %d %d", ins.Index, ins.Argc)
+	case ins.Op == MATCH_ANY:
+		return "MATCH_ANY " + ins.Compare.String()
+	case ins.Op.isQuantifier():
+		return fmt.Sprintf("%v %d", ins.Op, ins.Index)
+	case ins.Op.isJump():
+		offset := ins.Target - (ins.PC + 5)
+		return fmt.Sprintf("%v %+d  ; -> %04d", ins.Op, offset, ins.Target)
+	}
+	return ins.Op.String()
+}
+
+// formatValue writes a constant as its type followed by its value,
+// strings are quoted: STRING "a", INT8 18, ARRAY [STRING "a", INT8 2]
+func formatValue(v Value) string {
+	switch v.Type {
+	case TYPE_STRING:
+		return "STRING " + strconv.Quote(v.String())
+	case TYPE_ARRAY:
+		elems := v.Array()
+		parts := make([]string, len(elems))
+		for i, elem := range elems {
+			parts[i] = formatValue(elem)
+		}
+		return "ARRAY [" + strings.Join(parts, ", ") + "]"
+	}
+	return v.Type.String() + " " + v.String()
+}
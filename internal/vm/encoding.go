@@ -0,0 +1,214 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// Binary format of a compiled program:
+//
+//	[magic: "SEL\x00"][format version: 2 bytes][opcode version: 2 bytes]
+//	[max stack: uvarint]
+//	[field count: uvarint]([length: uvarint][name])...
+//	[native count: uvarint]([length: uvarint][name])...
+//	[bytecode length: uvarint][bytecode]
+//	[nested count: uvarint]([max stack][fields][bytecode][nested count]...)...
+//	[crc32 IEEE of everything before: 4 bytes]
+//
+// Integers are big-endian. Native functions are stored by name and resolved
+// again when the program is loaded, nested programs share them.
+var binaryMagic = []byte("SEL\x00")
+
+// FormatVersion is the version of the binary layout above
+const FormatVersion = 1
+
+// MaxNesting bounds the depth of nested programs in encoded data
+const MaxNesting = 32
+
+// MarshalBinary encodes the program with its field table and the names of
+// its native functions
+func (p *Program) MarshalBinary() ([]byte, error) {
+	for i, fn := range p.natives {
+		if fn.Name == "" {
+			return nil, fmt.Errorf("cannot encode native function %d: it has no name", i)
+		}
+	}
+
+	buf := append([]byte{}, binaryMagic...)
+	buf = binary.BigEndian.AppendUint16(buf, FormatVersion)
+	buf = binary.BigEndian.AppendUint16(buf, OpcodeVersion)
+	buf = binary.AppendUvarint(buf, uint64(p.maxStack))
+
+	buf = binary.AppendUvarint(buf, uint64(len(p.fields)))
+	for _, field := range p.fields {
+		buf = binary.AppendUvarint(buf, uint64(len(field)))
+		buf = append(buf, field...)
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(p.natives)))
+	for _, fn := range p.natives {
+		buf = binary.AppendUvarint(buf, uint64(len(fn.Name)))
+		buf = append(buf, fn.Name...)
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(p.bytecode)))
+	buf = append(buf, p.bytecode...)
+	buf = appendNested(buf, p.programs)
+
+	return binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf)), nil
+}
+
+// appendNested encodes nested programs, without the native table they
+// share with the root
+func appendNested(buf []byte, programs []*Program) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(programs)))
+	for _, p := range programs {
+		buf = binary.AppendUvarint(buf, uint64(p.maxStack))
+		buf = binary.AppendUvarint(buf, uint64(len(p.fields)))
+		for _, field := range p.fields {
+			buf = binary.AppendUvarint(buf, uint64(len(field)))
+			buf = append(buf, field...)
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(p.bytecode)))
+		buf = append(buf, p.bytecode...)
+		buf = appendNested(buf, p.programs)
+	}
+	return buf
+}
+
+// UnmarshalProgram decodes a program encoded by MarshalBinary and verifies
+// it. Native functions are looked up by name in funcs, which may be nil when
+// the program calls none. data is copied.
+func UnmarshalProgram(data []byte, funcs *Functions) (*Program, error) {
+	if len(data) < len(binaryMagic)+4+4 || !bytes.Equal(data[:len(binaryMagic)], binaryMagic) {
+		return nil, fmt.Errorf("not a compiled SEL expression")
+	}
+
+	body, sum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, fmt.Errorf("checksum mismatch: data is corrupted")
+	}
+
+	r := &binaryReader{data: body, pos: len(binaryMagic)}
+	format := r.uint16()
+	if format != FormatVersion {
+		return nil, fmt.Errorf("unsupported format version %d, want %d", format, FormatVersion)
+	}
+	if version := r.uint16(); version != OpcodeVersion {
+		return nil, fmt.Errorf("compiled with opcode version %d, this VM runs version %d", version, OpcodeVersion)
+	}
+	maxStack := r.uvarint()
+
+	fields := make([]string, r.count(MaxGlobals))
+	for i := range fields {
+		fields[i] = string(r.bytes())
+	}
+	natives := make([]Function, r.count(len(body)))
+	for i := range natives {
+		name := string(r.bytes())
+		fn, ok := funcs.Lookup(name)
+		if !ok && r.err == nil {
+			return nil, fmt.Errorf("unknown native function %q", name)
+		}
+		natives[i] = fn
+	}
+	bytecode := bytes.Clone(r.bytes())
+	p := NewProgram(bytecode, fields, maxStack, natives)
+	r.nested(p, 1)
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.pos != len(body) {
+		return nil, fmt.Errorf("unexpected %d bytes after bytecode", len(body)-r.pos)
+	}
+
+	if err := p.Verify(); err != nil {
+		return nil, fmt.Errorf("invalid bytecode: %w", err)
+	}
+	return p, nil
+}
+
+// nested decodes the nested programs of parent, depth is the nesting level
+// of parent
+func (r *binaryReader) nested(parent *Program, depth int) {
+	n := r.count(len(r.data))
+	if n > 0 && depth > MaxNesting {
+		if r.err == nil {
+			r.err = fmt.Errorf("programs nested deeper than %d levels", MaxNesting)
+		}
+		return
+	}
+	for range n {
+		maxStack := r.uvarint()
+		fields := make([]string, r.count(MaxGlobals))
+		for i := range fields {
+			fields[i] = string(r.bytes())
+		}
+		p := NewProgram(bytes.Clone(r.bytes()), fields, maxStack, nil)
+		r.nested(p, depth+1)
+		parent.AddProgram(p)
+		if r.err != nil {
+			return
+		}
+	}
+}
+
+// binaryReader decodes the binary format, the first error sticks
+type binaryReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *binaryReader) fail(what string) {
+	if r.err == nil {
+		r.err = fmt.Errorf("truncated %s at offset %d", what, r.pos)
+	}
+}
+
+func (r *binaryReader) uint16() uint16 {
+	if r.err != nil || r.pos+2 > len(r.data) {
+		r.fail("version")
+		return 0
+	}
+	v := binary.BigEndian.Uint16(r.data[r.pos:])
+	r.pos += 2
+	return v
+}
+
+func (r *binaryReader) uvarint() int {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 || v > uint64(len(r.data)) {
+		r.fail("length")
+		return 0
+	}
+	r.pos += n
+	return int(v)
+}
+
+// count reads an item count, bounded so a corrupted count cannot allocate
+func (r *binaryReader) count(limit int) int {
+	n := r.uvarint()
+	if n > limit || n > len(r.data)-r.pos {
+		r.fail("count")
+		return 0
+	}
+	return n
+}
+
+func (r *binaryReader) bytes() []byte {
+	n := r.uvarint()
+	if r.err != nil || r.pos+n > len(r.data) {
+		r.fail("data")
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
@@ -25,11 +25,43 @@ var handlers = []func(*VM) error{
 	OP_OR:         (*VM).orHandler,
 	OP_XOR:        (*VM).xorHandler,
 	OP_NOT:        (*VM).notHandler,
+
+	JUMP:                 (*VM).jumpHandler,
+	JUMP_IF_FALSE:        (*VM).jumpIfFalseHandler,
+	JUMP_IF_TRUE:         (*VM).jumpIfTrueHandler,
+	JUMP_IF_FALSE_OR_POP: (*VM).jumpIfFalseOrPopHandler,
+	JUMP_IF_TRUE_OR_POP:  (*VM).jumpIfTrueOrPopHandler,
+
+	OP_ADD: (*VM).addHandler,
+	OP_SUB: (*VM).subHandler,
+	OP_MUL: (*VM).mulHandler,
+	OP_DIV: (*VM).divHandler,
+	OP_MOD: (*VM).modHandler,
+
+	OP_COUNT: (*VM).countHandler,
+	OP_SUM:   (*VM).sumHandler,
+	OP_AVG:   (*VM).avgHandler,
+	OP_MIN:   (*VM).minHandler,
+	OP_MAX:   (*VM).maxHandler,
+
+	MATCH_ANY: nil, // set by init, the handler dispatches to the comparisons
+
+	// set by init, the handlers execute nested programs
+	ANY_OF:  nil,
+	ALL_OF:  nil,
+	NONE_OF: nil,
+}
+
+func init() {
+	handlers[MATCH_ANY] = (*VM).matchAnyHandler
+	handlers[ANY_OF] = (*VM).anyOfHandler
+	handlers[ALL_OF] = (*VM).allOfHandler
+	handlers[NONE_OF] = (*VM).noneOfHandler
 }
 
 // PUSH
 func (vm *VM) pushHandler() error {
-	//[OP_CODE: 1 byte][type: 1 byte][length: 1 byte][data: length bytes]
+	//[OP_CODE: 1 byte][type: 1 byte][length: uvarint][data: length bytes]
 	val, err := vm.inferRuntimeValue()
 	if err != nil {
 		return err
@@ -51,46 +83,52 @@ func (vm *VM) popHandler() error {
 
 // STORE_GLOBAL
 func (vm *VM) storeGlobalHandler() error {
-	//[OP_CODE: 1 byte][length: 1 byte][data: length bytes][type: 1 byte][length: 1 byte][data: length bytes]
-	length := int(vm.bytecode[vm.pc])
-	vm.pc++ // skip length
-	key := string(vm.bytecode[vm.pc : vm.pc+length])
-	vm.pc += length // skip key
+	//[OP_CODE: 1 byte][slot: 2 bytes][type: 1 byte][length: uvarint][data: length bytes]
+	slot, err := vm.readSlot()
+	if err != nil {
+		return err
+	}
 
 	val, err := vm.inferRuntimeValue()
 	if err != nil {
 		return err
 	}
 
-	vm.globals[key] = val
+	vm.globals[slot] = val
+	vm.defined[slot] = true
 	return nil
 }
 
 // LOAD_GLOBAL
 func (vm *VM) loadGlobalHandler() error {
-	length := int(vm.bytecode[vm.pc])
-	vm.pc++ // skip length
-	key := string(vm.bytecode[vm.pc : vm.pc+length])
-	vm.pc += length // skip key
+	//[OP_CODE: 1 byte][slot: 2 bytes]
+	slot, err := vm.readSlot()
+	if err != nil {
+		return err
+	}
 
-	val, exists := vm.globals[key]
-	if !exists {
-		return fmt.Errorf("undefined global variable: %s", key)
+	if !vm.defined[slot] {
+		if vm.errs[slot] != nil {
+			return vm.errs[slot]
+		}
+		return fmt.Errorf("undefined global variable: %s", vm.program.fields[slot])
 	}
 
-	vm.push(val)
+	vm.push(vm.globals[slot])
 	return nil
 }
 
 // CALL_NATIVE
 func (vm *VM) callNativeHandler() error {
-	// [OP_CODE: 1 byte][index: 1 byte][args_count: 1 byte] // Max 256 stack pops
-	index := int(vm.bytecode[vm.pc])
-
-	vm.pc++ // skip index
-
-	argsCount := int(vm.bytecode[vm.pc])
-	vm.pc++ // skip pop count
+	// [OP_CODE: 1 byte][index: uvarint][args_count: uvarint]
+	index, err := vm.readVarint()
+	if err != nil {
+		return err
+	}
+	argsCount, err := vm.readVarint()
+	if err != nil {
+		return err
+	}
 
 	if len(vm.nativeFuncs) <= index {
 		return fmt.Errorf("native function index out of bounds: %d", index)
@@ -125,12 +163,12 @@ func (vm *VM) eqHandler() error {
 		return err
 	}
 
-	result, err := left.Compare(right)
+	equal, err := equalValues(left, right)
 	if err != nil {
 		return err
 	}
 
-	vm.push(Value{Type: TYPE_BOOL, Bool: result == 0})
+	vm.push(BoolValue(equal))
 	return nil
 }
 
@@ -145,12 +183,12 @@ func (vm *VM) gtHandler() error {
 		return err
 	}
 
-	result, err := left.Compare(right)
+	result, err := compareValues(left, right)
 	if err != nil {
 		return err
 	}
 
-	vm.push(Value{Type: TYPE_BOOL, Bool: result > 0})
+	vm.push(BoolValue(result > 0))
 	return nil
 }
 
@@ -165,12 +203,12 @@ func (vm *VM) ltHandler() error {
 		return err
 	}
 
-	result, err := left.Compare(right)
+	result, err := compareValues(left, right)
 	if err != nil {
 		return err
 	}
 
-	vm.push(Value{Type: TYPE_BOOL, Bool: result < 0})
+	vm.push(BoolValue(result < 0))
 	return nil
 }
 
@@ -185,12 +223,12 @@ func (vm *VM) gteHandler() error {
 		return err
 	}
 
-	result, err := left.Compare(right)
+	result, err := compareValues(left, right)
 	if err != nil {
 		return err
 	}
 
-	vm.push(Value{Type: TYPE_BOOL, Bool: result >= 0})
+	vm.push(BoolValue(result >= 0))
 	return nil
 }
 
@@ -205,12 +243,12 @@ func (vm *VM) lteHandler() error {
 		return err
 	}
 
-	result, err := left.Compare(right)
+	result, err := compareValues(left, right)
 	if err != nil {
 		return err
 	}
 
-	vm.push(Value{Type: TYPE_BOOL, Bool: result <= 0})
+	vm.push(BoolValue(result <= 0))
 	return nil
 }
 
@@ -229,7 +267,7 @@ func (vm *VM) startsWithHandler() error {
 		return fmt.Errorf("STARTSWITH requires string operands")
 	}
 
-	vm.push(Value{Type: TYPE_BOOL, Bool: strings.HasPrefix(str.String, prefix.String)})
+	vm.push(BoolValue(strings.HasPrefix(str.String(), prefix.String())))
 	return nil
 }
 
@@ -248,7 +286,7 @@ func (vm *VM) endsWithHandler() error {
 		return fmt.Errorf("ENDSWITH requires string operands")
 	}
 
-	vm.push(Value{Type: TYPE_BOOL, Bool: strings.HasSuffix(str.String, suffix.String)})
+	vm.push(BoolValue(strings.HasSuffix(str.String(), suffix.String())))
 	return nil
 }
 
@@ -267,7 +305,7 @@ func (vm *VM) containsHandler() error {
 		return fmt.Errorf("CONTAINS requires string operands")
 	}
 
-	vm.push(Value{Type: TYPE_BOOL, Bool: strings.Contains(str.String, substr.String)})
+	vm.push(BoolValue(strings.Contains(str.String(), substr.String())))
 	return nil
 }
 
@@ -289,29 +327,29 @@ func (vm *VM) inHandler() error {
 	// needle is a scalar
 	if needle.Type != TYPE_ARRAY {
 		found := false
-		for _, item := range haystack.Array {
-			val, err := needle.Compare(item)
+		for _, item := range haystack.Array() {
+			equal, err := equalValues(needle, item)
 			if err != nil {
 				return err
 			}
-			if val == 0 {
+			if equal {
 				found = true
 				break
 			}
 		}
-		vm.push(Value{Type: TYPE_BOOL, Bool: found})
+		vm.push(BoolValue(found))
 		return nil
 	}
 
 	// needle is an array
 	found := false
-	for _, needleItem := range needle.Array {
-		for _, haystackItem := range haystack.Array {
-			val, err := needleItem.Compare(haystackItem)
+	for _, needleItem := range needle.Array() {
+		for _, haystackItem := range haystack.Array() {
+			equal, err := equalValues(needleItem, haystackItem)
 			if err != nil {
 				return err
 			}
-			if val == 0 {
+			if equal {
 				found = true
 				break
 			}
@@ -321,7 +359,7 @@ func (vm *VM) inHandler() error {
 		}
 	}
 
-	vm.push(Value{Type: TYPE_BOOL, Bool: found})
+	vm.push(BoolValue(found))
 	return nil
 }
 
@@ -336,7 +374,7 @@ func (vm *VM) notHandler() error {
 		return fmt.Errorf("NOT operation requires boolean type")
 	}
 
-	vm.push(Value{Type: TYPE_BOOL, Bool: !val.Bool})
+	vm.push(BoolValue(!val.Bool()))
 	return nil
 }
 
@@ -355,7 +393,7 @@ func (vm *VM) andHandler() error {
 		return fmt.Errorf("AND requires boolean operands")
 	}
 
-	vm.push(Value{Type: TYPE_BOOL, Bool: left.Bool && right.Bool})
+	vm.push(BoolValue(left.Bool() && right.Bool()))
 	return nil
 }
 
@@ -374,7 +412,7 @@ func (vm *VM) orHandler() error {
 		return fmt.Errorf("OR requires boolean operands")
 	}
 
-	vm.push(Value{Type: TYPE_BOOL, Bool: left.Bool || right.Bool})
+	vm.push(BoolValue(left.Bool() || right.Bool()))
 	return nil
 }
 
@@ -393,6 +431,97 @@ func (vm *VM) xorHandler() error {
 		return fmt.Errorf("XOR requires boolean operands")
 	}
 
-	vm.push(Value{Type: TYPE_BOOL, Bool: left.Bool != right.Bool})
+	vm.push(BoolValue(left.Bool() != right.Bool()))
+	return nil
+}
+
+// JUMP
+func (vm *VM) jumpHandler() error {
+	target, err := vm.readJumpTarget()
+	if err != nil {
+		return err
+	}
+	vm.pc = target
+	return nil
+}
+
+// JUMP_IF_FALSE
+func (vm *VM) jumpIfFalseHandler() error {
+	return vm.conditionalJump(false, false)
+}
+
+// JUMP_IF_TRUE
+func (vm *VM) jumpIfTrueHandler() error {
+	return vm.conditionalJump(true, false)
+}
+
+// JUMP_IF_FALSE_OR_POP
+func (vm *VM) jumpIfFalseOrPopHandler() error {
+	return vm.conditionalJump(false, true)
+}
+
+// JUMP_IF_TRUE_OR_POP
+func (vm *VM) jumpIfTrueOrPopHandler() error {
+	return vm.conditionalJump(true, true)
+}
+
+// conditionalJump jumps when the boolean on top of the stack equals when.
+// keep leaves the condition on the stack if the jump is taken, it is popped
+// in every other case.
+func (vm *VM) conditionalJump(when bool, keep bool) error {
+	target, err := vm.readJumpTarget()
+	if err != nil {
+		return err
+	}
+
+	cond, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	if cond.Type != TYPE_BOOL {
+		return fmt.Errorf("conditional jump requires boolean condition")
+	}
+
+	if cond.Bool() == when {
+		if keep {
+			vm.push(cond)
+		}
+		vm.pc = target
+	}
+	return nil
+}
+
+// MATCH_ANY <comparison>: the comparison is run on each element of the
+// array with the same right operand, it stops at the first match. An empty
+// array never matches.
+func (vm *VM) matchAnyHandler() error {
+	compare, err := vm.readComparison()
+	if err != nil {
+		return err
+	}
+	right, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	array, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	if array.Type != TYPE_ARRAY {
+		return fmt.Errorf("MATCH_ANY requires an array, got %v", array.Type)
+	}
+
+	for _, elem := range array.Array() {
+		vm.push(elem)
+		vm.push(right)
+		if err := handlers[compare](vm); err != nil {
+			return err
+		}
+		if result, _ := vm.pop(); result.Bool() {
+			vm.push(BoolValue(true))
+			return nil
+		}
+	}
+	vm.push(BoolValue(false))
 	return nil
 }
@@ -0,0 +1,181 @@
+package vm
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Kind is the type class of a function argument or result, checked by the
+// compiler when it is known. KindAny is never checked.
+type Kind byte
+
+const (
+	KindAny Kind = iota
+	KindBool
+	KindInteger
+	KindFloat
+	KindString
+	KindArray
+)
+
+var kindNames = map[Kind]string{
+	KindAny:     "any",
+	KindBool:    "bool",
+	KindInteger: "integer",
+	KindFloat:   "float",
+	KindString:  "string",
+	KindArray:   "array",
+}
+
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("kind(%d)", byte(k))
+}
+
+// Accepts reports whether a value of kind other can be used where k is
+// expected
+func (k Kind) Accepts(other Kind) bool {
+	return k == KindAny || other == KindAny || k == other
+}
+
+// KindOf returns the kind of a value type
+func KindOf(t Type) Kind {
+	switch t {
+	case TYPE_BOOL:
+		return KindBool
+	case TYPE_INT8, TYPE_INT16, TYPE_INT32, TYPE_INT64, TYPE_UINT8, TYPE_UINT16, TYPE_UINT32, TYPE_UINT64:
+		return KindInteger
+	case TYPE_FLOAT64:
+		return KindFloat
+	case TYPE_STRING:
+		return KindString
+	case TYPE_ARRAY:
+		return KindArray
+	}
+	return KindAny
+}
+
+// Function is a named native function. Expressions call it by name, the
+// compiler checks the argument count against MinArgs and MaxArgs, and the
+// kinds of the arguments known at compile time against Params.
+type Function struct {
+	Name    string
+	MinArgs int
+	MaxArgs int    // -1: no limit
+	Params  []Kind // kind of each argument, arguments beyond Params are not checked
+	Result  Kind
+	Func    NativeFunc
+}
+
+// accepts reports whether the function can be called with argc arguments
+func (f Function) accepts(argc int) bool {
+	return argc >= f.MinArgs && (f.MaxArgs < 0 || argc <= f.MaxArgs)
+}
+
+// Arity describes the accepted argument counts for error messages
+func (f Function) Arity() string {
+	switch {
+	case f.MaxArgs == f.MinArgs:
+		return fmt.Sprintf("%d", f.MinArgs)
+	case f.MaxArgs < 0:
+		return fmt.Sprintf("at least %d", f.MinArgs)
+	}
+	return fmt.Sprintf("%d to %d", f.MinArgs, f.MaxArgs)
+}
+
+// CheckArgs returns an error when the function cannot be called with argc
+// arguments
+func (f Function) CheckArgs(argc int) error {
+	if !f.accepts(argc) {
+		return fmt.Errorf("%s expects %s arguments, got %d", f.Name, f.Arity(), argc)
+	}
+	return nil
+}
+
+// CheckKinds returns an error when an argument of known kind does not match
+// Params
+func (f Function) CheckKinds(kinds []Kind) error {
+	for i, kind := range kinds {
+		if i < len(f.Params) && !f.Params[i].Accepts(kind) {
+			return fmt.Errorf("%s argument %d expects %v, got %v", f.Name, i+1, f.Params[i], kind)
+		}
+	}
+	return nil
+}
+
+// Functions is a registry of native functions by name. It must not be
+// modified while expressions are parsed with it.
+type Functions struct {
+	byName map[string]Function
+}
+
+func NewFunctions() *Functions {
+	return &Functions{byName: make(map[string]Function)}
+}
+
+// Register adds a function. Names are identifiers ([A-Za-z_][A-Za-z0-9_]*)
+// and are unique in the registry.
+func (f *Functions) Register(fn Function) error {
+	if !isIdentifier(fn.Name) {
+		return fmt.Errorf("invalid function name %q", fn.Name)
+	}
+	for _, name := range aggregateNames {
+		if fn.Name == name {
+			return fmt.Errorf("%s is a built-in aggregation", fn.Name)
+		}
+	}
+	for _, name := range quantifierNames {
+		if fn.Name == name {
+			return fmt.Errorf("%s is a built-in quantifier", fn.Name)
+		}
+	}
+	if _, exists := f.byName[fn.Name]; exists {
+		return fmt.Errorf("function %s already registered", fn.Name)
+	}
+	if fn.Func == nil {
+		return fmt.Errorf("function %s has no implementation", fn.Name)
+	}
+	if fn.MinArgs < 0 || (fn.MaxArgs >= 0 && fn.MaxArgs < fn.MinArgs) {
+		return fmt.Errorf("function %s: invalid arity %d..%d", fn.Name, fn.MinArgs, fn.MaxArgs)
+	}
+	f.byName[fn.Name] = fn
+	return nil
+}
+
+// Lookup returns the function registered under name, a nil registry has no
+// functions
+func (f *Functions) Lookup(name string) (Function, bool) {
+	if f == nil {
+		return Function{}, false
+	}
+	fn, ok := f.byName[name]
+	return fn, ok
+}
+
+// Names returns the registered names, sorted
+func (f *Functions) Names() []string {
+	if f == nil {
+		return nil
+	}
+	names := make([]string, 0, len(f.byName))
+	for name := range f.byName {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// anonymous wraps bare native functions, as passed to NewVM, without a name
+// or an arity check
+func anonymous(nativeFuncs []NativeFunc) []Function {
+	if nativeFuncs == nil {
+		return nil
+	}
+	natives := make([]Function, len(nativeFuncs))
+	for i, fn := range nativeFuncs {
+		natives[i] = Function{MaxArgs: -1, Func: fn}
+	}
+	return natives
+}
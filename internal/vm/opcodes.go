@@ -1,5 +1,9 @@
 package vm
 
+// OpcodeVersion identifies the opcode set and operand encodings, it is stored
+// in compiled programs. Bump it when an opcode or an operand layout changes.
+const OpcodeVersion = 1
+
 const (
 	PUSH          OpCode = 0x00
 	POP           OpCode = 0x01
@@ -19,6 +23,37 @@ const (
 	OP_OR         OpCode = 0x0F
 	OP_XOR        OpCode = 0x10
 	OP_NOT        OpCode = 0x11
+
+	// [OP_CODE][offset: 4 bytes, signed, relative to the next instruction]
+	JUMP                 OpCode = 0x12
+	JUMP_IF_FALSE        OpCode = 0x13 // pops the condition
+	JUMP_IF_TRUE         OpCode = 0x14 // pops the condition
+	JUMP_IF_FALSE_OR_POP OpCode = 0x15 // keeps the condition when jumping
+	JUMP_IF_TRUE_OR_POP  OpCode = 0x16 // keeps the condition when jumping
+
+	// pop 2 numbers, push the result
+	OP_ADD OpCode = 0x17
+	OP_SUB OpCode = 0x18
+	OP_MUL OpCode = 0x19
+	OP_DIV OpCode = 0x1A
+	OP_MOD OpCode = 0x1B
+
+	// pop an array, push its aggregate
+	OP_COUNT OpCode = 0x1C
+	OP_SUM   OpCode = 0x1D
+	OP_AVG   OpCode = 0x1E
+	OP_MIN   OpCode = 0x1F
+	OP_MAX   OpCode = 0x20
+
+	// [OP_CODE][comparison op code], pop an array and a value, push whether
+	// the comparison holds for one element
+	MATCH_ANY OpCode = 0x21
+
+	// [OP_CODE][program index: uvarint], pop an array of objects, run the
+	// nested program on each of them and push the quantified result
+	ANY_OF  OpCode = 0x22
+	ALL_OF  OpCode = 0x23
+	NONE_OF OpCode = 0x24
 )
 
 func (op OpCode) isLogical() bool {
@@ -28,3 +63,19 @@ func (op OpCode) isLogical() bool {
 func (op OpCode) isComparison() bool {
 	return op >= OP_EQ && op <= OP_IN
 }
+
+func (op OpCode) isJump() bool {
+	return op >= JUMP && op <= JUMP_IF_TRUE_OR_POP
+}
+
+func (op OpCode) isArithmetic() bool {
+	return op >= OP_ADD && op <= OP_MOD
+}
+
+func (op OpCode) isAggregate() bool {
+	return op >= OP_COUNT && op <= OP_MAX
+}
+
+func (op OpCode) isQuantifier() bool {
+	return op >= ANY_OF && op <= NONE_OF
+}
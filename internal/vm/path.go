@@ -0,0 +1,205 @@
+package vm
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Lookup returns the raw value of a field in a record. A key is first
+// looked up as is, so records flattened by hand keep working, then it is
+// resolved as a path through nested objects and arrays:
+//
+//	caller.department.name  records["caller"]["department"]["name"]
+//	items[0].sku            sku of the first item
+//	items[-1]               last item
+//	items[*].sku            array of the sku of every item
+//
+// A missing key or a nil value, on the way or at the end, leaves the field
+// undefined, like a missing top-level key. An index out of range is an
+// error naming the path up to that segment, except after a wildcard:
+// elements where the rest of the path is missing, nil or out of range are
+// left out of the result. Each wildcard flattens one level,
+// items[*].tags[*] holds the tags of all items. Going through a value that
+// is not an object or an array is an error.
+//
+// Lookup only allocates for wildcards.
+func Lookup(records map[string]interface{}, key string) (interface{}, bool, error) {
+	if val, exists := records[key]; exists {
+		return val, val != nil, nil
+	}
+	if !strings.ContainsAny(key, ".[") || CheckPath(key) != nil {
+		return nil, false, nil
+	}
+	return lookupPath(records, key, 0, false)
+}
+
+// CheckPath returns an error when path is not a valid field path: names
+// separated by '.', each followed by indexes [n], [-n] or [*]
+func CheckPath(path string) error {
+	if strings.HasPrefix(path, "[") {
+		return fmt.Errorf("empty segment")
+	}
+	for i := 0; i < len(path); {
+		_, next, err := nextStep(path, i)
+		if err != nil {
+			return err
+		}
+		i = next
+	}
+	return nil
+}
+
+// pathStep is a member name, an index or a wildcard
+type pathStep struct {
+	name     string
+	index    int
+	wildcard bool
+}
+
+// nextStep reads the step of path starting at i: [index], or a name after
+// the '.' that separates it from the previous step
+func nextStep(path string, i int) (pathStep, int, error) {
+	if path[i] == '[' {
+		closing := strings.IndexByte(path[i:], ']')
+		if closing < 0 {
+			return pathStep{}, 0, fmt.Errorf("unclosed '[' in %s", path[i:])
+		}
+		next := i + closing + 1
+		content := path[i+1 : next-1]
+		if content == "*" {
+			return pathStep{wildcard: true}, next, nil
+		}
+		n, err := strconv.Atoi(content)
+		if err != nil || content[0] == '+' {
+			return pathStep{}, 0, fmt.Errorf("invalid index %s", path[i:next])
+		}
+		return pathStep{index: n}, next, nil
+	}
+
+	if i > 0 {
+		if path[i] != '.' {
+			return pathStep{}, 0, fmt.Errorf("expected '.' or '[' after %s", path[:i])
+		}
+		i++ // skip '.'
+	}
+	end := i
+	for end < len(path) && path[end] != '.' && path[end] != '[' {
+		end++
+	}
+	if end == i {
+		return pathStep{}, 0, fmt.Errorf("empty segment")
+	}
+	return pathStep{name: path[i:end]}, end, nil
+}
+
+// lookupPath resolves the steps of path from i in val. Under a wildcard,
+// lenient leaves out the elements whose index is out of range.
+func lookupPath(val interface{}, path string, i int, lenient bool) (interface{}, bool, error) {
+	for i < len(path) {
+		if val == nil {
+			return nil, false, nil
+		}
+		step, next, err := nextStep(path, i)
+		if err != nil {
+			return nil, false, err
+		}
+
+		switch {
+		case step.name != "":
+			obj, ok := asObject(val)
+			if !ok {
+				return nil, false, fmt.Errorf("%s is %T, not an object", path[:i], val)
+			}
+			// the rest of the path may be a flattened key of this object
+			if rest := path[next-len(step.name):]; i > 0 && rest != step.name {
+				if member, exists := obj[rest]; exists {
+					return member, member != nil, nil
+				}
+			}
+			member, exists := obj[step.name]
+			if !exists {
+				return nil, false, nil
+			}
+			val = member
+
+		case step.wildcard:
+			arr, ok := asArray(val)
+			if !ok {
+				return nil, false, fmt.Errorf("%s is %T, not an array", path[:i], val)
+			}
+			flatten := strings.Contains(path[next:], "[*]")
+			items := make([]interface{}, 0, len(arr))
+			for _, elem := range arr {
+				found, exists, err := lookupPath(elem, path, next, true)
+				if err != nil {
+					return nil, false, err
+				}
+				if !exists || found == nil {
+					continue
+				}
+				if nested, ok := asArray(found); ok && flatten {
+					items = append(items, nested...)
+				} else {
+					items = append(items, found)
+				}
+			}
+			return items, true, nil
+
+		default:
+			arr, ok := asArray(val)
+			if !ok {
+				return nil, false, fmt.Errorf("%s is %T, not an array", path[:i], val)
+			}
+			n := step.index
+			if n < 0 {
+				n += len(arr)
+			}
+			if n < 0 || n >= len(arr) {
+				if lenient {
+					return nil, false, nil
+				}
+				return nil, false, fmt.Errorf("%s: index %d out of range, %d elements", path[:next], step.index, len(arr))
+			}
+			val = arr[n]
+		}
+		i = next
+	}
+	return val, val != nil, nil
+}
+
+// asObject returns a map with string keys as a record, other maps are
+// copied
+func asObject(val interface{}) (map[string]interface{}, bool) {
+	if obj, ok := val.(map[string]interface{}); ok {
+		return obj, true
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	obj := make(map[string]interface{}, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		obj[iter.Key().String()] = iter.Value().Interface()
+	}
+	return obj, true
+}
+
+// asArray returns a slice or an array as []interface{}, typed ones are
+// copied
+func asArray(val interface{}) ([]interface{}, bool) {
+	if arr, ok := val.([]interface{}); ok {
+		return arr, true
+	}
+	rv := reflect.ValueOf(val)
+	if kind := rv.Kind(); kind != reflect.Slice && kind != reflect.Array {
+		return nil, false
+	}
+	arr := make([]interface{}, rv.Len())
+	for i := range arr {
+		arr[i] = rv.Index(i).Interface()
+	}
+	return arr, true
+}
@@ -0,0 +1,92 @@
+package vm
+
+import "math"
+
+// MaxGlobals is the number of distinct fields a program can reference, slot
+// indexes are encoded on 2 bytes
+const MaxGlobals = math.MaxUint16 + 1
+
+// Program is compiled bytecode with its field table and the native functions
+// it calls. It is not modified once verified, so one Program can be shared
+// between goroutines as long as each of them executes it on its own VM.
+type Program struct {
+	bytecode    []byte
+	fields      []string     // field name of each global slot
+	maxStack    int          // stack depth reached by the bytecode, 0 if unknown
+	natives     []Function   // CALL_NATIVE <index> calls natives[index]
+	nativeFuncs []NativeFunc // O(1) native funcs access with index
+	programs    []*Program   // ANY_OF <index> runs programs[index]
+	verified    bool         // set by Verify
+}
+
+// NewProgram wraps compiled bytecode. maxStack sizes the data stack of every
+// VM up front, the stack still grows past it if needed.
+func NewProgram(bytecode []byte, fields []string, maxStack int, natives []Function) *Program {
+	p := &Program{bytecode: bytecode, fields: fields, maxStack: maxStack, natives: natives}
+	if len(natives) > 0 {
+		p.nativeFuncs = make([]NativeFunc, len(natives))
+		for i, fn := range natives {
+			p.nativeFuncs[i] = fn.Func
+		}
+	}
+	return p
+}
+
+func (p *Program) Bytecode() []byte {
+	return p.bytecode
+}
+
+// Fields returns the field table, LOAD_GLOBAL <slot> reads Fields()[slot]
+func (p *Program) Fields() []string {
+	return p.fields
+}
+
+// Natives returns the native function table, CALL_NATIVE <index> calls
+// Natives()[index]
+func (p *Program) Natives() []Function {
+	return p.natives
+}
+
+// AddProgram adds a nested program run on array elements by ANY_OF, ALL_OF
+// and NONE_OF and returns its index. Nested programs call the natives of p.
+// Programs are added before p is verified.
+func (p *Program) AddProgram(nested *Program) int {
+	nested.share(p)
+	p.programs = append(p.programs, nested)
+	return len(p.programs) - 1
+}
+
+// share gives the natives of parent to p and its own nested programs
+func (p *Program) share(parent *Program) {
+	p.natives, p.nativeFuncs = parent.natives, parent.nativeFuncs
+	for _, nested := range p.programs {
+		nested.share(p)
+	}
+}
+
+// Programs returns the nested programs, ANY_OF <index> runs
+// Programs()[index]
+func (p *Program) Programs() []*Program {
+	return p.programs
+}
+
+func (p *Program) Verified() bool {
+	return p.verified
+}
+
+func (p *Program) MaxStack() int {
+	return p.maxStack
+}
+
+// NewVM returns a fresh execution state for the program
+func (p *Program) NewVM() *VM {
+	return &VM{
+		program:     p,
+		bytecode:    p.bytecode,
+		globals:     make([]Value, len(p.fields)),
+		defined:     make([]bool, len(p.fields)),
+		errs:        make([]error, len(p.fields)),
+		dataStack:   make([]Value, 0, p.maxStack),
+		nativeFuncs: p.nativeFuncs,
+	}
+}
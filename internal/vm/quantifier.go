@@ -0,0 +1,100 @@
+package vm
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// quantifierNames are the names of the quantifiers in expressions and
+// errors
+var quantifierNames = map[OpCode]string{
+	ANY_OF:  "ANY",
+	ALL_OF:  "ALL",
+	NONE_OF: "NONE",
+}
+
+// SerializeQuantifier encodes a quantifier running the nested program at
+// index on each element of an array
+func SerializeQuantifier(op OpCode, index int) []byte {
+	return binary.AppendUvarint([]byte{byte(op)}, uint64(index))
+}
+
+// ANY_OF <index>
+func (vm *VM) anyOfHandler() error {
+	return vm.quantify(ANY_OF)
+}
+
+// ALL_OF <index>
+func (vm *VM) allOfHandler() error {
+	return vm.quantify(ALL_OF)
+}
+
+// NONE_OF <index>
+func (vm *VM) noneOfHandler() error {
+	return vm.quantify(NONE_OF)
+}
+
+// quantify runs the nested program on each object of the array, the fields
+// of the element are its record. It stops as soon as the result is known:
+// ANY at the first match, ALL at the first mismatch, NONE at the first
+// match. On an empty array ANY is false, ALL and NONE are true.
+func (vm *VM) quantify(op OpCode) error {
+	index, err := vm.readVarint()
+	if err != nil {
+		return err
+	}
+	if index >= len(vm.program.programs) {
+		return fmt.Errorf("nested program index out of bounds: %d", index)
+	}
+	array, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	name := quantifierNames[op]
+	if array.Type != TYPE_ARRAY {
+		return fmt.Errorf("%s requires an array, got %v", name, array.Type)
+	}
+
+	nested := vm.nestedVM(index)
+	for i, elem := range array.Array() {
+		if elem.Type != TYPE_OBJECT {
+			return fmt.Errorf("%s requires objects, element %d is %v", name, i, elem.Type)
+		}
+		matched, err := nested.evalElement(elem.Object())
+		if err != nil {
+			return fmt.Errorf("%s element %d: %w", name, i, err)
+		}
+		if matched != (op == ALL_OF) {
+			vm.push(BoolValue(op == ANY_OF))
+			return nil
+		}
+	}
+	vm.push(BoolValue(op != ANY_OF))
+	return nil
+}
+
+// nestedVM returns the VM of the nested program at index, created on first
+// use and kept so that evaluating again does not allocate
+func (vm *VM) nestedVM(index int) *VM {
+	if vm.nested == nil {
+		vm.nested = make([]*VM, len(vm.program.programs))
+	}
+	if vm.nested[index] == nil {
+		vm.nested[index] = vm.program.programs[index].NewVM()
+	}
+	nested := vm.nested[index]
+	nested.tracer = vm.tracer
+	return nested
+}
+
+// evalElement runs the program with an element of the array as its record
+func (vm *VM) evalElement(record map[string]interface{}) (bool, error) {
+	vm.Reset()
+	if err := vm.LoadRecords(record); err != nil {
+		return false, err
+	}
+	if err := vm.Execute(); err != nil {
+		return false, err
+	}
+	return vm.dataStack[0].Bool(), nil
+}
@@ -0,0 +1,162 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// builtinFunctions is the standard library of string functions. Positions
+// and lengths count characters, not bytes.
+var builtinFunctions = []Function{
+	{Name: "UPPER", MinArgs: 1, MaxArgs: 1, Params: []Kind{KindString}, Result: KindString, Func: upperFunc},
+	{Name: "LOWER", MinArgs: 1, MaxArgs: 1, Params: []Kind{KindString}, Result: KindString, Func: lowerFunc},
+	{Name: "TRIM", MinArgs: 1, MaxArgs: 1, Params: []Kind{KindString}, Result: KindString, Func: trimFunc},
+	{Name: "LEN", MinArgs: 1, MaxArgs: 1, Params: []Kind{KindString}, Result: KindInteger, Func: lenFunc},
+	{Name: "SUBSTR", MinArgs: 2, MaxArgs: 3, Params: []Kind{KindString, KindInteger, KindInteger}, Result: KindString, Func: substrFunc},
+	{Name: "REPLACE", MinArgs: 3, MaxArgs: 3, Params: []Kind{KindString, KindString, KindString}, Result: KindString, Func: replaceFunc},
+	{Name: "SPLIT", MinArgs: 2, MaxArgs: 2, Params: []Kind{KindString, KindString}, Result: KindArray, Func: splitFunc},
+}
+
+// Builtins returns a new registry holding the standard library functions,
+// more functions can be registered in it
+func Builtins() *Functions {
+	funcs := NewFunctions()
+	for _, fn := range builtinFunctions {
+		if err := funcs.Register(fn); err != nil {
+			panic(err)
+		}
+	}
+	return funcs
+}
+
+// UPPER(s)
+func upperFunc(args []Value) (Value, error) {
+	s, err := stringArg("UPPER", args, 0)
+	if err != nil {
+		return Value{}, err
+	}
+	return StringValue(strings.ToUpper(s)), nil
+}
+
+// LOWER(s)
+func lowerFunc(args []Value) (Value, error) {
+	s, err := stringArg("LOWER", args, 0)
+	if err != nil {
+		return Value{}, err
+	}
+	return StringValue(strings.ToLower(s)), nil
+}
+
+// TRIM(s): leading and trailing white space
+func trimFunc(args []Value) (Value, error) {
+	s, err := stringArg("TRIM", args, 0)
+	if err != nil {
+		return Value{}, err
+	}
+	return StringValue(strings.TrimSpace(s)), nil
+}
+
+// LEN(s): number of characters
+func lenFunc(args []Value) (Value, error) {
+	s, err := stringArg("LEN", args, 0)
+	if err != nil {
+		return Value{}, err
+	}
+	return Int64Value(int64(utf8.RuneCountInString(s))), nil
+}
+
+// SUBSTR(s, start[, length]): start is 0-based, the result stops at the end
+// of s
+func substrFunc(args []Value) (Value, error) {
+	s, err := stringArg("SUBSTR", args, 0)
+	if err != nil {
+		return Value{}, err
+	}
+	start, err := countArg("SUBSTR", args, 1)
+	if err != nil {
+		return Value{}, err
+	}
+	length := uint64(len(s))
+	if len(args) > 2 {
+		if length, err = countArg("SUBSTR", args, 2); err != nil {
+			return Value{}, err
+		}
+	}
+
+	// a string has at most len(s) characters: clamping avoids overflows
+	start, length = min(start, uint64(len(s))), min(length, uint64(len(s)))
+
+	// byte offsets of the start-th and (start+length)-th characters
+	from, to := len(s), len(s)
+	var n uint64
+	for i := range s {
+		if n == start {
+			from = i
+		}
+		if n == start+length {
+			to = i
+			break
+		}
+		n++
+	}
+	return StringValue(s[from:to]), nil
+}
+
+// REPLACE(s, old, new): every occurrence
+func replaceFunc(args []Value) (Value, error) {
+	var parts [3]string
+	for i := range parts {
+		s, err := stringArg("REPLACE", args, i)
+		if err != nil {
+			return Value{}, err
+		}
+		parts[i] = s
+	}
+	return StringValue(strings.ReplaceAll(parts[0], parts[1], parts[2])), nil
+}
+
+// SPLIT(s, sep): array of strings, an empty sep splits every character
+func splitFunc(args []Value) (Value, error) {
+	s, err := stringArg("SPLIT", args, 0)
+	if err != nil {
+		return Value{}, err
+	}
+	sep, err := stringArg("SPLIT", args, 1)
+	if err != nil {
+		return Value{}, err
+	}
+	parts := strings.Split(s, sep)
+	elems := make([]Value, len(parts))
+	for i, part := range parts {
+		elems[i] = StringValue(part)
+	}
+	return ArrayValue(elems), nil
+}
+
+func stringArg(name string, args []Value, i int) (string, error) {
+	if i >= len(args) {
+		return "", fmt.Errorf("%s: missing argument %d", name, i+1)
+	}
+	if args[i].Type != TYPE_STRING {
+		return "", fmt.Errorf("%s argument %d expects string, got %v", name, i+1, args[i].Type)
+	}
+	return args[i].String(), nil
+}
+
+// countArg reads a non negative integer argument
+func countArg(name string, args []Value, i int) (uint64, error) {
+	if i >= len(args) {
+		return 0, fmt.Errorf("%s: missing argument %d", name, i+1)
+	}
+	switch arg := args[i]; {
+	case arg.isUnsigned():
+		return arg.Uint64(), nil
+	case arg.isSigned() && arg.Int64() >= 0:
+		return uint64(arg.Int64()), nil
+	case arg.isSigned():
+		return 0, fmt.Errorf("%s argument %d must not be negative, got %d", name, i+1, arg.Int64())
+	default:
+		return 0, fmt.Errorf("%s argument %d expects integer, got %v", name, i+1, arg.Type)
+	}
+}
@@ -0,0 +1,436 @@
+package vm
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// structPlan maps the field names of a struct type to their indexes, it is
+// built once per type
+type structPlan struct {
+	fields map[string]structField
+}
+
+// structField is the index of a field, through the embedded structs that
+// promote it
+type structField struct {
+	index []int
+}
+
+// structPlans caches the plan of each struct type: reflect.Type → *structPlan
+var structPlans sync.Map
+
+// planOf returns the plan of a struct type. A field is named by its `sel`
+// tag, up to the first ',', or by its Go name; `sel:"-"` ignores it. The
+// fields of an embedded struct without a tag are promoted like in Go: the
+// shallowest wins, and names that are ambiguous at the same depth are left
+// out unless exactly one of them is tagged. Unexported fields are ignored.
+func planOf(t reflect.Type) *structPlan {
+	if plan, ok := structPlans.Load(t); ok {
+		return plan.(*structPlan)
+	}
+	plan, _ := structPlans.LoadOrStore(t, buildPlan(t))
+	return plan.(*structPlan)
+}
+
+func buildPlan(t reflect.Type) *structPlan {
+	type candidate struct {
+		index  []int
+		depth  int
+		tagged bool
+	}
+	found := make(map[string][]candidate)
+
+	var walk func(t reflect.Type, index []int, seen []reflect.Type)
+	walk = func(t reflect.Type, index []int, seen []reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("sel"), ",")
+			if name == "-" {
+				continue
+			}
+			fieldIndex := append(slices.Clone(index), i)
+
+			if f.Anonymous && name == "" {
+				embedded := f.Type
+				if embedded.Kind() == reflect.Pointer {
+					embedded = embedded.Elem()
+				}
+				if embedded.Kind() == reflect.Struct {
+					if !slices.Contains(seen, embedded) {
+						walk(embedded, fieldIndex, append(seen, embedded))
+					}
+					continue
+				}
+			}
+			if !f.IsExported() {
+				continue
+			}
+
+			tagged := name != ""
+			if !tagged {
+				name = f.Name
+			}
+			found[name] = append(found[name], candidate{index: fieldIndex, depth: len(index), tagged: tagged})
+		}
+	}
+	walk(t, nil, []reflect.Type{t})
+
+	plan := &structPlan{fields: make(map[string]structField, len(found))}
+	for name, candidates := range found {
+		depth := candidates[0].depth
+		for _, c := range candidates {
+			depth = min(depth, c.depth)
+		}
+		var shallowest, tagged []candidate
+		for _, c := range candidates {
+			if c.depth == depth {
+				shallowest = append(shallowest, c)
+				if c.tagged {
+					tagged = append(tagged, c)
+				}
+			}
+		}
+		switch {
+		case len(shallowest) == 1:
+			plan.fields[name] = structField{index: shallowest[0].index}
+		case len(tagged) == 1:
+			plan.fields[name] = structField{index: tagged[0].index}
+		}
+	}
+	return plan
+}
+
+// value returns the field in rv, false when a nil embedded pointer is on
+// the way
+func (f structField) value(rv reflect.Value) (reflect.Value, bool) {
+	for i, x := range f.index {
+		if i > 0 && rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				return reflect.Value{}, false
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, true
+}
+
+// LoadStruct converts the fields of a struct, or a pointer to a struct,
+// referenced by the program into globals, like LoadRecords does for a
+// record. Paths go through nested structs, pointers, slices and maps,
+// a nil pointer on the way leaves the field undefined and a path that
+// cannot be resolved is reported when the field is read. Fields holding
+// scalars are loaded without allocating.
+func (vm *VM) LoadStruct(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return fmt.Errorf("cannot load fields of a nil %T", v)
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("expected a struct or a pointer to a struct, got %T", v)
+	}
+
+	plan := planOf(rv.Type())
+	for slot, key := range vm.program.fields {
+		member, exists, err := structMember(rv, plan, key)
+		if err != nil {
+			vm.errs[slot] = fmt.Errorf("field %s: %v", key, err)
+			continue
+		}
+		if !exists {
+			continue
+		}
+		value, exists, err := vm.reflectValue(member)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", key, err)
+		}
+		if !exists {
+			continue
+		}
+		vm.globals[slot] = value
+		vm.defined[slot] = true
+	}
+	return nil
+}
+
+// structMember resolves a key in a struct: a field name first, then a path
+// whose first segment is a field. What it goes through is not converted.
+func structMember(rv reflect.Value, plan *structPlan, key string) (reflect.Value, bool, error) {
+	if f, ok := plan.fields[key]; ok {
+		member, ok := f.value(rv)
+		return member, ok, nil
+	}
+	if !strings.ContainsAny(key, ".[") || CheckPath(key) != nil {
+		return reflect.Value{}, false, nil
+	}
+	return reflectPath(rv, key, 0, false)
+}
+
+// reflectPath resolves the steps of path from i in rv like lookupPath does
+// in records, through struct fields, map keys and indexes, without
+// converting what it goes through. A wildcard returns the matches converted
+// into a []interface{}.
+func reflectPath(rv reflect.Value, path string, i int, lenient bool) (reflect.Value, bool, error) {
+	for i < len(path) {
+		rv = indirect(rv)
+		if !rv.IsValid() {
+			return reflect.Value{}, false, nil
+		}
+		step, next, err := nextStep(path, i)
+		if err != nil {
+			return reflect.Value{}, false, err
+		}
+
+		switch {
+		case step.name != "":
+			// the rest of the path may be a flattened key of this object
+			if rest := path[next-len(step.name):]; i > 0 && rest != step.name {
+				if member, exists := memberOf(rv, rest); exists {
+					return member, true, nil
+				}
+			}
+			if kind := rv.Kind(); kind != reflect.Struct && (kind != reflect.Map || rv.Type().Key().Kind() != reflect.String) {
+				return reflect.Value{}, false, fmt.Errorf("%s is %s, not an object", path[:i], rv.Type())
+			}
+			member, exists := memberOf(rv, step.name)
+			if !exists {
+				return reflect.Value{}, false, nil
+			}
+			rv = member
+
+		case step.wildcard:
+			if kind := rv.Kind(); kind != reflect.Slice && kind != reflect.Array {
+				return reflect.Value{}, false, fmt.Errorf("%s is %s, not an array", path[:i], rv.Type())
+			}
+			flatten := strings.Contains(path[next:], "[*]")
+			items := make([]interface{}, 0, rv.Len())
+			for k := range rv.Len() {
+				found, exists, err := reflectPath(rv.Index(k), path, next, true)
+				if err != nil {
+					return reflect.Value{}, false, err
+				}
+				if !exists {
+					continue
+				}
+				val, err := toInterface(found, nil)
+				if err != nil {
+					return reflect.Value{}, false, fmt.Errorf("%s: %w", path[:next], err)
+				}
+				if nested, ok := val.([]interface{}); ok && flatten {
+					items = append(items, nested...)
+				} else if val != nil {
+					items = append(items, val)
+				}
+			}
+			return reflect.ValueOf(items), true, nil
+
+		default:
+			if kind := rv.Kind(); kind != reflect.Slice && kind != reflect.Array {
+				return reflect.Value{}, false, fmt.Errorf("%s is %s, not an array", path[:i], rv.Type())
+			}
+			n := step.index
+			if n < 0 {
+				n += rv.Len()
+			}
+			if n < 0 || n >= rv.Len() {
+				if lenient {
+					return reflect.Value{}, false, nil
+				}
+				return reflect.Value{}, false, fmt.Errorf("%s: index %d out of range, %d elements", path[:next], step.index, rv.Len())
+			}
+			rv = rv.Index(n)
+		}
+		i = next
+	}
+	return rv, true, nil
+}
+
+// indirect goes through pointers and interfaces, a nil pointer, interface,
+// slice or map returns the zero Value
+func indirect(rv reflect.Value) reflect.Value {
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return reflect.Value{}
+		}
+		rv = rv.Elem()
+	}
+	if (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Map) && rv.IsNil() {
+		return reflect.Value{}
+	}
+	return rv
+}
+
+// memberOf returns the field of a struct, through its plan, or the entry of
+// a map with string keys
+func memberOf(rv reflect.Value, name string) (reflect.Value, bool) {
+	switch rv.Kind() {
+	case reflect.Struct:
+		f, ok := planOf(rv.Type()).fields[name]
+		if !ok {
+			return reflect.Value{}, false
+		}
+		return f.value(rv)
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}, false
+		}
+		member := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+		return member, member.IsValid()
+	}
+	return reflect.Value{}, false
+}
+
+// reflectValue converts a field, scalars without boxing them. A nil pointer
+// or interface is undefined.
+func (vm *VM) reflectValue(rv reflect.Value) (Value, bool, error) {
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return Value{}, false, nil
+		}
+		return vm.reflectValue(rv.Elem())
+	case reflect.String:
+		return StringValue(rv.String()), true, nil
+	case reflect.Bool:
+		return BoolValue(rv.Bool()), true, nil
+	case reflect.Int:
+		return intToValue(int(rv.Int())), true, nil
+	case reflect.Int8:
+		return Int8Value(int8(rv.Int())), true, nil
+	case reflect.Int16:
+		return Int16Value(int16(rv.Int())), true, nil
+	case reflect.Int32:
+		return Int32Value(int32(rv.Int())), true, nil
+	case reflect.Int64:
+		return Int64Value(rv.Int()), true, nil
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return Uint64Value(rv.Uint()), true, nil
+	case reflect.Uint8:
+		return Uint8Value(uint8(rv.Uint())), true, nil
+	case reflect.Uint16:
+		return Uint16Value(uint16(rv.Uint())), true, nil
+	case reflect.Uint32:
+		return Uint32Value(uint32(rv.Uint())), true, nil
+	case reflect.Float32, reflect.Float64:
+		return Float64Value(rv.Float()), true, nil
+	}
+
+	val, err := toInterface(rv, nil)
+	if err != nil || val == nil {
+		return Value{}, false, err
+	}
+	value, err := vm.convertInterfaceToValue(val)
+	if err != nil {
+		return Value{}, false, fmt.Errorf("unsupported type: %v", err)
+	}
+	return value, true, nil
+}
+
+// visit is a pointer, map or slice being converted by toInterface
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// toInterface converts a struct to a record, map[string]interface{}, and a
+// slice or an array to []interface{}, recursively, so that quantifiers and
+// paths go through them. Named scalar types become their underlying type,
+// nil pointers, interfaces, slices and maps become nil. parents holds the
+// pointers, maps and slices being converted: meeting one of them again is
+// a cycle, which is an error.
+func toInterface(rv reflect.Value, parents []visit) (interface{}, error) {
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		if rv.Kind() != reflect.Interface {
+			v := visit{ptr: rv.Pointer(), typ: rv.Type()}
+			if slices.Contains(parents, v) {
+				return nil, fmt.Errorf("cycle through %s", rv.Type())
+			}
+			parents = append(parents, v)
+		}
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return toInterface(rv.Elem(), parents)
+	case reflect.Struct:
+		plan := planOf(rv.Type())
+		obj := make(map[string]interface{}, len(plan.fields))
+		for name, f := range plan.fields {
+			member, ok := f.value(rv)
+			if !ok {
+				continue
+			}
+			val, err := toInterface(member, parents)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			if val != nil {
+				obj[name] = val
+			}
+		}
+		return obj, nil
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			val, err := toInterface(rv.Index(i), parents)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			items[i] = val
+		}
+		return items, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		obj := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			val, err := toInterface(iter.Value(), parents)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", iter.Key().String(), err)
+			}
+			obj[iter.Key().String()] = val
+		}
+		return obj, nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int:
+		return int(rv.Int()), nil
+	case reflect.Int8:
+		return int8(rv.Int()), nil
+	case reflect.Int16:
+		return int16(rv.Int()), nil
+	case reflect.Int32:
+		return int32(rv.Int()), nil
+	case reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), nil
+	case reflect.Uint8:
+		return uint8(rv.Uint()), nil
+	case reflect.Uint16:
+		return uint16(rv.Uint()), nil
+	case reflect.Uint32:
+		return uint32(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+	if !rv.CanInterface() {
+		return nil, fmt.Errorf("unsupported type: %s", rv.Type())
+	}
+	return rv.Interface(), nil
+}
@@ -0,0 +1,98 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Tracer observes the execution of a program instruction by instruction.
+// Before is called before each handler runs and After once it returned,
+// with its error. The stack of a step is a deep copy the tracer may keep,
+// arrays included: it does not change when the VM is reset and reused.
+type Tracer interface {
+	Before(step Step)
+	After(step Step, err error)
+}
+
+// Step is the instruction being executed and the data stack, before or
+// after its handler
+type Step struct {
+	Instruction
+	Stack   []Value
+	program *Program
+}
+
+// String formats the instruction like Disassemble
+func (s Step) String() string {
+	return fmt.Sprintf("%04d  %s", s.PC, s.program.formatInstruction(s.Instruction))
+}
+
+// SetTracer sets the tracer called by Execute, nil disables tracing. Reset
+// keeps it.
+func (vm *VM) SetTracer(tracer Tracer) {
+	vm.tracer = tracer
+}
+
+// runTraced is run with the tracer calls, kept apart so that run stays as
+// fast without a tracer. Each instruction is decoded for the tracer, then
+// its handler reads the operands again.
+func (vm *VM) runTraced() error {
+	for vm.pc < len(vm.bytecode) {
+		start := vm.pc
+		ins, err := vm.decode()
+		if err != nil {
+			return err
+		}
+		vm.pc = start + 1 // skip op code
+
+		step := Step{Instruction: ins, Stack: snapshot(vm.dataStack), program: vm.program}
+		vm.tracer.Before(step)
+		err = handlers[ins.Op](vm)
+		step.Stack = snapshot(vm.dataStack)
+		vm.tracer.After(step, err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshot copies the stack and the elements of its arrays, which live in
+// the arena of the VM until the next Reset
+func snapshot(stack []Value) []Value {
+	copied := slices.Clone(stack)
+	for i, v := range copied {
+		if v.Type == TYPE_ARRAY {
+			copied[i] = ArrayValue(snapshot(v.Array()))
+		}
+	}
+	return copied
+}
+
+// NewWriterTracer returns a tracer that prints every executed instruction
+// to w, followed by the stack after it or by its error:
+//
+//	0000  LOAD_GLOBAL "status"                [STRING "active"]
+func NewWriterTracer(w io.Writer) Tracer {
+	return writerTracer{w: w}
+}
+
+type writerTracer struct {
+	w io.Writer
+}
+
+func (t writerTracer) Before(Step) {}
+
+func (t writerTracer) After(step Step, err error) {
+	if err != nil {
+		fmt.Fprintf(t.w, "%-40s  error: %v\n", step, err)
+		return
+	}
+	stack := make([]string, len(step.Stack))
+	for i, v := range step.Stack {
+		stack[i] = formatValue(v)
+	}
+	fmt.Fprintf(t.w, "%-40s  [%s]\n", step, strings.Join(stack, ", "))
+}
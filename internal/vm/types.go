@@ -3,6 +3,12 @@ package vm
 import (
 	"cmp"
 	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unsafe"
 )
 
 type NativeFunc func(args []Value) (Value, error)
@@ -12,62 +18,218 @@ type OpCode byte
 type Type byte
 
 const (
-	TYPE_BOOL   Type = 0x00
-	TYPE_INT8   Type = 0x01
-	TYPE_INT16  Type = 0x02
-	TYPE_INT32  Type = 0x03
-	TYPE_STRING Type = 0x04
-	TYPE_ARRAY  Type = 0x05
+	TYPE_BOOL    Type = 0x00
+	TYPE_INT8    Type = 0x01
+	TYPE_INT16   Type = 0x02
+	TYPE_INT32   Type = 0x03
+	TYPE_STRING  Type = 0x04
+	TYPE_ARRAY   Type = 0x05
+	TYPE_INT64   Type = 0x06
+	TYPE_UINT8   Type = 0x07
+	TYPE_UINT16  Type = 0x08
+	TYPE_UINT32  Type = 0x09
+	TYPE_UINT64  Type = 0x0A
+	TYPE_FLOAT64 Type = 0x0B
+	TYPE_OBJECT  Type = 0x0C // record only, never encoded in bytecode
 )
 
 type Handler func(*VM) error
 
+// Value is a compact tagged union (24 bytes). Integers, floats and bools keep
+// their bits in num, strings and arrays keep their data pointer in ptr and
+// their length in num. Build values with the XxxValue constructors and read
+// them with the accessor of their type.
 type Value struct {
-	Type   Type
-	Int8   int8
-	Int16  int16
-	Int32  int32
-	String string
-	Bool   bool
-	Array  []Value
+	Type Type
+	num  uint64
+	ptr  unsafe.Pointer
 }
 
-func (v Value) Compare(other Value) (int, error) {
-	if v.Type != other.Type {
-		return 0, fmt.Errorf("cannot compare different types")
+func BoolValue(b bool) Value {
+	var n uint64
+	if b {
+		n = 1
 	}
+	return Value{Type: TYPE_BOOL, num: n}
+}
+
+func Int8Value(n int8) Value   { return Value{Type: TYPE_INT8, num: uint64(n)} }
+func Int16Value(n int16) Value { return Value{Type: TYPE_INT16, num: uint64(n)} }
+func Int32Value(n int32) Value { return Value{Type: TYPE_INT32, num: uint64(n)} }
+func Int64Value(n int64) Value { return Value{Type: TYPE_INT64, num: uint64(n)} }
+
+func Uint8Value(n uint8) Value   { return Value{Type: TYPE_UINT8, num: uint64(n)} }
+func Uint16Value(n uint16) Value { return Value{Type: TYPE_UINT16, num: uint64(n)} }
+func Uint32Value(n uint32) Value { return Value{Type: TYPE_UINT32, num: uint64(n)} }
+func Uint64Value(n uint64) Value { return Value{Type: TYPE_UINT64, num: n} }
+
+func Float64Value(f float64) Value {
+	return Value{Type: TYPE_FLOAT64, num: math.Float64bits(f)}
+}
+
+// StringValue does not copy s
+func StringValue(s string) Value {
+	return Value{Type: TYPE_STRING, num: uint64(len(s)), ptr: unsafe.Pointer(unsafe.StringData(s))}
+}
+
+// ArrayValue does not copy elems, the slice must not be modified afterwards
+func ArrayValue(elems []Value) Value {
+	return Value{Type: TYPE_ARRAY, num: uint64(len(elems)), ptr: unsafe.Pointer(unsafe.SliceData(elems))}
+}
+
+// ObjectValue does not copy m: members are converted when they are read.
+// A map is a single pointer, kept in ptr.
+func ObjectValue(m map[string]interface{}) Value {
+	return Value{Type: TYPE_OBJECT, num: uint64(len(m)), ptr: *(*unsafe.Pointer)(unsafe.Pointer(&m))}
+}
+
+// Bool returns false for non bool values
+func (v Value) Bool() bool {
+	return v.Type == TYPE_BOOL && v.num != 0
+}
+
+// Int64 returns a signed integer of any width, 0 for other types
+func (v Value) Int64() int64 {
+	if !v.isSigned() {
+		return 0
+	}
+	return int64(v.num)
+}
+
+// Uint64 returns an unsigned integer of any width, 0 for other types
+func (v Value) Uint64() uint64 {
+	if !v.isUnsigned() {
+		return 0
+	}
+	return v.num
+}
+
+// Float64 returns 0 for non float values
+func (v Value) Float64() float64 {
+	if v.Type != TYPE_FLOAT64 {
+		return 0
+	}
+	return math.Float64frombits(v.num)
+}
+
+// Array returns nil for non array values
+func (v Value) Array() []Value {
+	if v.Type != TYPE_ARRAY || v.ptr == nil {
+		return nil
+	}
+	return unsafe.Slice((*Value)(v.ptr), v.num)
+}
+
+// Object returns nil for non object values
+func (v Value) Object() map[string]interface{} {
+	if v.Type != TYPE_OBJECT {
+		return nil
+	}
+	return *(*map[string]interface{})(unsafe.Pointer(&v.ptr))
+}
 
+// Interface returns the value as the Go type ToValue accepts for its type:
+// bool, intN, uintN, float64, string, []interface{} or
+// map[string]interface{}
+func (v Value) Interface() interface{} {
 	switch v.Type {
 	case TYPE_BOOL:
-		if v.Bool == other.Bool {
-			return 0, nil
-		} else if !v.Bool && other.Bool {
-			return -1, nil
+		return v.Bool()
+	case TYPE_INT8:
+		return int8(v.num)
+	case TYPE_INT16:
+		return int16(v.num)
+	case TYPE_INT32:
+		return int32(v.num)
+	case TYPE_INT64:
+		return int64(v.num)
+	case TYPE_UINT8:
+		return uint8(v.num)
+	case TYPE_UINT16:
+		return uint16(v.num)
+	case TYPE_UINT32:
+		return uint32(v.num)
+	case TYPE_UINT64:
+		return v.num
+	case TYPE_FLOAT64:
+		return v.Float64()
+	case TYPE_STRING:
+		return v.String()
+	case TYPE_ARRAY:
+		elems := v.Array()
+		items := make([]interface{}, len(elems))
+		for i, elem := range elems {
+			items[i] = elem.Interface()
 		}
+		return items
+	case TYPE_OBJECT:
+		return v.Object()
+	}
+	return nil
+}
 
-		return 1, nil
-	case TYPE_INT8:
-		return cmpGeneric(v.Int8, other.Int8), nil
+// String returns the content of a string value, other values are formatted
+func (v Value) String() string {
+	switch {
+	case v.Type == TYPE_STRING:
+		if v.ptr == nil {
+			return ""
+		}
+		return unsafe.String((*byte)(v.ptr), v.num)
+	case v.Type == TYPE_BOOL:
+		return strconv.FormatBool(v.Bool())
+	case v.isNumber():
+		return v.numberString()
+	case v.Type == TYPE_ARRAY:
+		parts := make([]string, 0, v.num)
+		for _, elem := range v.Array() {
+			parts = append(parts, elem.String())
+		}
+		return "[" + strings.Join(parts, " ") + "]"
+	case v.Type == TYPE_OBJECT:
+		obj := v.Object()
+		parts := make([]string, 0, len(obj))
+		for _, key := range slices.Sorted(maps.Keys(obj)) {
+			member, err := ToValue(obj[key])
+			if err != nil {
+				parts = append(parts, fmt.Sprintf("%s:%v", key, obj[key]))
+				continue
+			}
+			parts = append(parts, key+":"+member.String())
+		}
+		return "{" + strings.Join(parts, " ") + "}"
+	}
+	return fmt.Sprintf("<type 0x%02x>", byte(v.Type))
+}
 
-	case TYPE_INT16:
-		return cmpGeneric(v.Int16, other.Int16), nil
+func (v Value) Compare(other Value) (int, error) {
+	// integers of every width and floats share one numeric domain
+	if v.isNumber() && other.isNumber() {
+		return compareNumbers(v, other)
+	}
 
-	case TYPE_INT32:
-		return cmpGeneric(v.Int32, other.Int32), nil
+	if v.Type != other.Type {
+		return 0, fmt.Errorf("cannot compare different types")
+	}
+
+	switch v.Type {
+	case TYPE_BOOL:
+		return cmpGeneric(v.num, other.num), nil
 
 	case TYPE_STRING:
-		return cmpGeneric(v.String, other.String), nil
+		return cmpGeneric(v.String(), other.String()), nil
 
 	case TYPE_ARRAY:
-		minLen := len(v.Array)
-		if len(other.Array) < minLen {
-			minLen = len(other.Array)
+		left, right := v.Array(), other.Array()
+		minLen := len(left)
+		if len(right) < minLen {
+			minLen = len(right)
 		}
 
 		for i := 0; i < minLen; i++ {
-			elem := v.Array[i]
+			elem := left[i]
 
-			res, err := elem.Compare(other.Array[i])
+			res, err := elem.Compare(right[i])
 			if err != nil { //  [1,3,-2] [1,2,0] = [0, 1, -1]
 				return 0, err
 			}
@@ -77,9 +239,9 @@ func (v Value) Compare(other Value) (int, error) {
 			}
 		}
 
-		if len(v.Array) < len(other.Array) {
+		if len(left) < len(right) {
 			return -1, nil
-		} else if len(v.Array) > len(other.Array) {
+		} else if len(left) > len(right) {
 			return 1, nil
 		}
 
@@ -98,3 +260,198 @@ func cmpGeneric[T cmp.Ordered](a, b T) int {
 	}
 	return 0
 }
+
+func (v Value) isInteger() bool {
+	return v.isSigned() || v.isUnsigned()
+}
+
+func (v Value) isSigned() bool {
+	switch v.Type {
+	case TYPE_INT8, TYPE_INT16, TYPE_INT32, TYPE_INT64:
+		return true
+	}
+	return false
+}
+
+func (v Value) isUnsigned() bool {
+	switch v.Type {
+	case TYPE_UINT8, TYPE_UINT16, TYPE_UINT32, TYPE_UINT64:
+		return true
+	}
+	return false
+}
+
+func (v Value) isNumber() bool {
+	return v.isInteger() || v.Type == TYPE_FLOAT64
+}
+
+func (v Value) numberString() string {
+	switch {
+	case v.Type == TYPE_FLOAT64:
+		return strconv.FormatFloat(v.Float64(), 'g', -1, 64)
+	case v.isUnsigned():
+		return strconv.FormatUint(v.Uint64(), 10)
+	}
+	return strconv.FormatInt(v.Int64(), 10)
+}
+
+// compareIntegers compares any two integer values without truncation,
+// a negative signed value is lower than every unsigned value
+func compareIntegers(a, b Value) int {
+	switch {
+	case a.isUnsigned() && b.isUnsigned():
+		return cmpGeneric(a.Uint64(), b.Uint64())
+	case a.isUnsigned():
+		if b.Int64() < 0 {
+			return 1
+		}
+		return cmpGeneric(a.Uint64(), uint64(b.Int64()))
+	case b.isUnsigned():
+		return -compareIntegers(b, a)
+	}
+	return cmpGeneric(a.Int64(), b.Int64())
+}
+
+// compareNumbers compares integers and floats exactly: an integer is never
+// rounded to float64. NaN is not ordered and returns an error.
+func compareNumbers(a, b Value) (int, error) {
+	switch {
+	case a.isInteger() && b.isInteger():
+		return compareIntegers(a, b), nil
+	case a.Type == TYPE_FLOAT64 && b.Type == TYPE_FLOAT64:
+		af, bf := a.Float64(), b.Float64()
+		if math.IsNaN(af) || math.IsNaN(bf) {
+			return 0, fmt.Errorf("cannot compare NaN")
+		}
+		return cmpGeneric(af, bf), nil
+	case a.Type == TYPE_FLOAT64:
+		res, err := compareIntegerFloat(b, a.Float64())
+		return -res, err
+	}
+	return compareIntegerFloat(a, b.Float64())
+}
+
+func compareIntegerFloat(i Value, f float64) (int, error) {
+	if math.IsNaN(f) {
+		return 0, fmt.Errorf("cannot compare NaN")
+	}
+
+	trunc := math.Trunc(f)
+	var res int
+	if i.isUnsigned() {
+		switch {
+		case trunc < 0:
+			return 1, nil
+		case trunc >= math.Exp2(64):
+			return -1, nil
+		}
+		res = cmpGeneric(i.Uint64(), uint64(trunc))
+	} else {
+		switch {
+		case trunc < -math.Exp2(63):
+			return 1, nil
+		case trunc >= math.Exp2(63):
+			return -1, nil
+		}
+		res = cmpGeneric(i.Int64(), int64(trunc))
+	}
+
+	if res != 0 {
+		return res, nil
+	}
+	// same integral part, the fractional part decides
+	return cmpGeneric(trunc, f), nil
+}
+
+// IsDecimal reports whether s is written in the decimal grammar shared by
+// literals and record strings: an optional sign, digits with an optional
+// '.', and an optional exponent (19.99, -0.5, .5, 1e3). Inf, NaN, hex floats
+// and '_' separators are not numbers.
+func IsDecimal(s string) bool {
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits := 0
+	for ; i < len(s) && isDigit(s[i]); i++ {
+		digits++
+	}
+	if i < len(s) && s[i] == '.' {
+		for i++; i < len(s) && isDigit(s[i]); i++ {
+			digits++
+		}
+	}
+	if digits == 0 {
+		return false
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		start := i
+		for ; i < len(s) && isDigit(s[i]); i++ {
+		}
+		if i == start {
+			return false
+		}
+	}
+	return i == len(s)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// parseNumber parses a decimal string as INT64, UINT64 beyond MaxInt64, or
+// FLOAT64. Strings outside the grammar of IsDecimal are not numbers.
+func parseNumber(s string) (Value, bool) {
+	if !IsDecimal(s) {
+		return Value{}, false
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return Int64Value(n), true
+	}
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return Uint64Value(n), true
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return Float64Value(f), true
+	}
+	return Value{}, false
+}
+
+// compareValues is the ordering used by >, <, >=, <=, MIN and MAX. A string
+// compared with a number must hold a number, any other string is an error.
+func compareValues(left, right Value) (int, error) {
+	if left.Type == TYPE_STRING && right.isNumber() {
+		n, ok := parseNumber(left.String())
+		if !ok {
+			return 0, fmt.Errorf("cannot compare string %q with a number", left.String())
+		}
+		return compareNumbers(n, right)
+	}
+	if left.isNumber() && right.Type == TYPE_STRING {
+		res, err := compareValues(right, left)
+		return -res, err
+	}
+	return left.Compare(right)
+}
+
+// equalValues is the equality used by = and IN. A string equals a number
+// when it holds that number, a string that is not a number never does.
+func equalValues(left, right Value) (bool, error) {
+	if left.isNumber() && right.Type == TYPE_STRING {
+		left, right = right, left
+	}
+	if left.Type == TYPE_STRING && right.isNumber() {
+		n, ok := parseNumber(left.String())
+		if !ok {
+			return false, nil
+		}
+		res, err := compareNumbers(n, right)
+		return res == 0, err
+	}
+	res, err := left.Compare(right)
+	return res == 0, err
+}
@@ -4,58 +4,129 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math"
+	"reflect"
+	"slices"
+	"unsafe"
 )
 
+// inferRuntimeValue decodes the value at pc without allocating: strings
+// point into the bytecode, which a Program never modifies, and array
+// elements are decoded into the VM arena
 func (vm *VM) inferRuntimeValue() (Value, error) {
+	if vm.pc >= len(vm.bytecode) {
+		return Value{}, fmt.Errorf("truncated value at pc=%d", vm.pc)
+	}
 	codeType := Type(vm.bytecode[vm.pc])
 	vm.pc++ // skip type
 
-	length := int(vm.bytecode[vm.pc])
-	vm.pc++ // skip length
+	length, err := vm.readVarint()
+	if err != nil {
+		return Value{}, err
+	}
+
+	// length is the size of the data, or the item count of an array whose
+	// items take at least 2 bytes each
+	size := length
+	if codeType == TYPE_ARRAY {
+		size = 2 * length
+	}
+	if vm.pc+size > len(vm.bytecode) {
+		return Value{}, fmt.Errorf("truncated value at pc=%d: need %d bytes, have %d", vm.pc, size, len(vm.bytecode)-vm.pc)
+	}
+	if fixed := typeSize(codeType); fixed != 0 && length != fixed {
+		return Value{}, fmt.Errorf("invalid length %d for type 0x%02x at pc=%d", length, codeType, vm.pc)
+	}
 
 	switch codeType {
 	case TYPE_INT8:
 		val := int8(vm.bytecode[vm.pc])
 		vm.pc++ // skip data
-		return Value{Type: TYPE_INT8, Int8: val}, nil
+		return Int8Value(val), nil
 
 	case TYPE_INT16:
 		val := binary.BigEndian.Uint16(vm.bytecode[vm.pc : vm.pc+2])
 		vm.pc += 2 // skip data
-		return Value{Type: TYPE_INT16, Int16: int16(val)}, nil
+		return Int16Value(int16(val)), nil
 
 	case TYPE_INT32:
 		val := binary.BigEndian.Uint32(vm.bytecode[vm.pc : vm.pc+4])
 		vm.pc += 4 // skip data
-		return Value{Type: TYPE_INT32, Int32: int32(val)}, nil
+		return Int32Value(int32(val)), nil
+
+	case TYPE_INT64:
+		val := binary.BigEndian.Uint64(vm.bytecode[vm.pc : vm.pc+8])
+		vm.pc += 8 // skip data
+		return Int64Value(int64(val)), nil
+
+	case TYPE_UINT8:
+		val := vm.bytecode[vm.pc]
+		vm.pc++ // skip data
+		return Uint8Value(val), nil
+
+	case TYPE_UINT16:
+		val := binary.BigEndian.Uint16(vm.bytecode[vm.pc : vm.pc+2])
+		vm.pc += 2 // skip data
+		return Uint16Value(val), nil
+
+	case TYPE_UINT32:
+		val := binary.BigEndian.Uint32(vm.bytecode[vm.pc : vm.pc+4])
+		vm.pc += 4 // skip data
+		return Uint32Value(val), nil
+
+	case TYPE_UINT64:
+		val := binary.BigEndian.Uint64(vm.bytecode[vm.pc : vm.pc+8])
+		vm.pc += 8 // skip data
+		return Uint64Value(val), nil
+
+	case TYPE_FLOAT64:
+		val := binary.BigEndian.Uint64(vm.bytecode[vm.pc : vm.pc+8])
+		vm.pc += 8 // skip data
+		return Float64Value(math.Float64frombits(val)), nil
 
 	case TYPE_STRING:
 		strBytes := vm.bytecode[vm.pc : vm.pc+length]
 		vm.pc += length // skip data
-		return Value{Type: TYPE_STRING, String: string(strBytes)}, nil
+		return StringValue(unsafe.String(unsafe.SliceData(strBytes), length)), nil
 
 	case TYPE_BOOL:
 		boolVal := vm.bytecode[vm.pc] != 0
 		vm.pc++ // skip data
-		return Value{Type: TYPE_BOOL, Bool: boolVal}, nil
+		return BoolValue(boolVal), nil
 
 	case TYPE_ARRAY:
-		// [TYPE_ARRAY][length: 1 byte][elements...]
-		array := make([]Value, length)
+		// [TYPE_ARRAY][count: uvarint][elements...]
+		// reserve the slots first, nested arrays are appended after them
+		start := len(vm.arena)
+		vm.arena = slices.Grow(vm.arena, length)[:start+length]
 		for i := 0; i < length; i++ {
 			elem, err := vm.inferRuntimeValue()
 			if err != nil {
 				return Value{}, err
 			}
-			array[i] = elem
+			vm.arena[start+i] = elem
 		}
-		return Value{Type: TYPE_ARRAY, Array: array}, nil
+		return ArrayValue(vm.arena[start : start+length : start+length]), nil
 
 	default:
 		return Value{}, fmt.Errorf("unsupported type in runtime value inference: 0x%02x", codeType)
 	}
 }
 
+// typeSize returns the data size of fixed size types, 0 for strings and arrays
+func typeSize(t Type) int {
+	switch t {
+	case TYPE_BOOL, TYPE_INT8, TYPE_UINT8:
+		return 1
+	case TYPE_INT16, TYPE_UINT16:
+		return 2
+	case TYPE_INT32, TYPE_UINT32:
+		return 4
+	case TYPE_INT64, TYPE_UINT64, TYPE_FLOAT64:
+		return 8
+	}
+	return 0
+}
+
 func determineIntType(v int) (Type, interface{}) {
 	if v >= math.MinInt8 && v <= math.MaxInt8 {
 		return TYPE_INT8, int8(v)
@@ -63,7 +134,71 @@ func determineIntType(v int) (Type, interface{}) {
 	if v >= math.MinInt16 && v <= math.MaxInt16 {
 		return TYPE_INT16, int16(v)
 	}
-	return TYPE_INT32, int32(v)
+	if v >= math.MinInt32 && v <= math.MaxInt32 {
+		return TYPE_INT32, int32(v)
+	}
+	return TYPE_INT64, int64(v)
+}
+
+// intToValue stores an int in the smallest signed type holding it
+func intToValue(v int) Value {
+	typ, _ := determineIntType(v)
+	return Value{Type: typ, num: uint64(v)}
+}
+
+// readVarint decodes a uvarint operand: a length, an item count or an index
+func (vm *VM) readVarint() (int, error) {
+	n, size := binary.Uvarint(vm.bytecode[min(vm.pc, len(vm.bytecode)):])
+	if size <= 0 {
+		return 0, fmt.Errorf("invalid varint at pc=%d", vm.pc)
+	}
+	if n > MaxOperandLength {
+		return 0, fmt.Errorf("operand %d exceeds the limit of %d at pc=%d", n, MaxOperandLength, vm.pc)
+	}
+	vm.pc += size // skip varint
+	return int(n), nil
+}
+
+// readSlot decodes a global slot index and checks it against the field table
+func (vm *VM) readSlot() (int, error) {
+	if vm.pc+2 > len(vm.bytecode) {
+		return 0, fmt.Errorf("truncated global slot at pc=%d", vm.pc)
+	}
+	slot := int(binary.BigEndian.Uint16(vm.bytecode[vm.pc : vm.pc+2]))
+	vm.pc += 2 // skip slot
+
+	if slot >= len(vm.globals) {
+		return 0, fmt.Errorf("global slot %d out of bounds at pc=%d", slot, vm.pc)
+	}
+	return slot, nil
+}
+
+// readJumpTarget decodes a jump offset and returns the absolute target
+func (vm *VM) readJumpTarget() (int, error) {
+	if vm.pc+4 > len(vm.bytecode) {
+		return 0, fmt.Errorf("truncated jump offset at pc=%d", vm.pc)
+	}
+	offset := int32(binary.BigEndian.Uint32(vm.bytecode[vm.pc : vm.pc+4]))
+	vm.pc += 4 // skip offset
+
+	target := vm.pc + int(offset)
+	if target < 0 || target > len(vm.bytecode) {
+		return 0, fmt.Errorf("jump target %d out of bounds at pc=%d", target, vm.pc)
+	}
+	return target, nil
+}
+
+// readComparison decodes the comparison operand of MATCH_ANY
+func (vm *VM) readComparison() (OpCode, error) {
+	if vm.pc >= len(vm.bytecode) {
+		return 0, fmt.Errorf("truncated comparison at pc=%d", vm.pc)
+	}
+	compare := OpCode(vm.bytecode[vm.pc])
+	if !compare.isComparison() {
+		return 0, fmt.Errorf("invalid comparison 0x%02x at pc=%d", byte(compare), vm.pc)
+	}
+	vm.pc++ // skip comparison
+	return compare, nil
 }
 
 // pop
@@ -76,15 +211,14 @@ func (vm *VM) pop() (Value, error) {
 	return val, nil
 }
 
-// pop n elements
+// pop n elements, the returned slice aliases the stack and is only valid
+// until the next push
 func (vm *VM) popN(n int) ([]Value, error) {
 	if len(vm.dataStack) < n {
 		return nil, fmt.Errorf("stack underflow: need %d, have %d", n, len(vm.dataStack))
 	}
 
-	args := make([]Value, n)
-	copy(args, vm.dataStack[len(vm.dataStack)-n:])
-
+	args := vm.dataStack[len(vm.dataStack)-n : len(vm.dataStack) : len(vm.dataStack)]
 	vm.dataStack = vm.dataStack[:len(vm.dataStack)-n]
 
 	return args, nil
@@ -95,41 +229,151 @@ func (vm *VM) push(val Value) {
 	vm.dataStack = append(vm.dataStack, val)
 }
 
+// convertInterfaceToValue converts a record value like ToValue, but array
+// elements go to the VM arena so that loading records does not allocate.
+// []interface{}, []string, []int, []float64 and []bool are converted
+// without boxing their elements.
 func (vm *VM) convertInterfaceToValue(val interface{}) (Value, error) {
+	switch v := val.(type) {
+	case []interface{}:
+		return arenaArray(vm, v, vm.convertInterfaceToValue)
+	case []string:
+		return arenaArray(vm, v, func(s string) (Value, error) { return StringValue(s), nil })
+	case []int:
+		return arenaArray(vm, v, func(n int) (Value, error) { return intToValue(n), nil })
+	case []float64:
+		return arenaArray(vm, v, func(f float64) (Value, error) { return Float64Value(f), nil })
+	case []bool:
+		return arenaArray(vm, v, func(b bool) (Value, error) { return BoolValue(b), nil })
+	}
+
+	rv := reflect.ValueOf(val)
+	if kind := rv.Kind(); kind != reflect.Slice && kind != reflect.Array {
+		return ToValue(val)
+	}
+	start := len(vm.arena)
+	vm.arena = slices.Grow(vm.arena, rv.Len())[:start+rv.Len()]
+	for i := 0; i < rv.Len(); i++ {
+		converted, err := vm.convertInterfaceToValue(rv.Index(i).Interface())
+		if err != nil {
+			return Value{}, fmt.Errorf("failed to convert array element %d: %v", i, err)
+		}
+		vm.arena[start+i] = converted
+	}
+	return ArrayValue(vm.arena[start : start+rv.Len() : start+rv.Len()]), nil
+}
+
+// arenaArray converts the items of a slice into the VM arena
+func arenaArray[T any](vm *VM, items []T, convert func(T) (Value, error)) (Value, error) {
+	// reserve the slots first, nested arrays are appended after them
+	start := len(vm.arena)
+	vm.arena = slices.Grow(vm.arena, len(items))[:start+len(items)]
+	for i, item := range items {
+		converted, err := convert(item)
+		if err != nil {
+			return Value{}, fmt.Errorf("failed to convert array element %d: %v", i, err)
+		}
+		vm.arena[start+i] = converted
+	}
+	return ArrayValue(vm.arena[start : start+len(items) : start+len(items)]), nil
+}
+
+// ToValue converts a Go value to a VM value. Plain ints are shrunk to the
+// smallest width, sized integer types keep their own width. Slices and
+// arrays of any element type are arrays, maps with string keys are objects
+// and named types of a scalar kind (type Role string) are converted like
+// their kind.
+func ToValue(val interface{}) (Value, error) {
 	switch v := val.(type) {
 	case string:
-		return Value{Type: TYPE_STRING, String: v}, nil
+		return StringValue(v), nil
 	case int:
-		typ, intVal := determineIntType(v)
-		switch typ {
-		case TYPE_INT8:
-			return Value{Type: TYPE_INT8, Int8: intVal.(int8)}, nil
-		case TYPE_INT16:
-			return Value{Type: TYPE_INT16, Int16: intVal.(int16)}, nil
-		case TYPE_INT32:
-			return Value{Type: TYPE_INT32, Int32: intVal.(int32)}, nil
-		}
+		return intToValue(v), nil
+	case int8:
+		return Int8Value(v), nil
+	case int16:
+		return Int16Value(v), nil
+	case int32:
+		return Int32Value(v), nil
+	case int64:
+		return Int64Value(v), nil
+	case uint:
+		return Uint64Value(uint64(v)), nil
+	case uint8:
+		return Uint8Value(v), nil
+	case uint16:
+		return Uint16Value(v), nil
+	case uint32:
+		return Uint32Value(v), nil
+	case uint64:
+		return Uint64Value(v), nil
+	case float32:
+		return Float64Value(float64(v)), nil
+	case float64:
+		return Float64Value(v), nil
 	case bool:
-		return Value{Type: TYPE_BOOL, Bool: v}, nil
+		return BoolValue(v), nil
 	case []interface{}:
 		array := make([]Value, len(v))
 		for i, item := range v {
-			converted, err := vm.convertInterfaceToValue(item)
+			converted, err := ToValue(item)
 			if err != nil {
-				return Value{}, err
+				return Value{}, fmt.Errorf("failed to convert array element %d: %v", i, err)
 			}
 			array[i] = converted
 		}
-		return Value{Type: TYPE_ARRAY, Array: array}, nil
+		return ArrayValue(array), nil
+	case map[string]interface{}:
+		return ObjectValue(v), nil
+	}
+	return reflectToValue(val)
+}
+
+// reflectToValue converts the values ToValue does not list by type
+func reflectToValue(val interface{}) (Value, error) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		array := make([]Value, rv.Len())
+		for i := range array {
+			converted, err := ToValue(rv.Index(i).Interface())
+			if err != nil {
+				return Value{}, fmt.Errorf("failed to convert array element %d: %v", i, err)
+			}
+			array[i] = converted
+		}
+		return ArrayValue(array), nil
+	case reflect.Map:
+		if obj, ok := asObject(val); ok {
+			return ObjectValue(obj), nil
+		}
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		scalar, err := toInterface(rv, nil)
+		if err != nil {
+			return Value{}, err
+		}
+		return ToValue(scalar)
 	}
 	return Value{}, fmt.Errorf("unsupported type: %T", val)
 }
 
-func SerializeLoadGlobal(name string) []byte {
-	length := byte(len(name))
-	bytes := []byte{byte(LOAD_GLOBAL), length}
-	bytes = append(bytes, []byte(name)...)
-	return bytes
+// MaxOperandLength bounds the lengths and counts encoded in bytecode: the
+// bytes of a string and the items of an array
+const MaxOperandLength = 1 << 20
+
+func SerializeLoadGlobal(slot uint16) []byte {
+	return binary.BigEndian.AppendUint16([]byte{byte(LOAD_GLOBAL)}, slot)
+}
+
+// SerializeStoreGlobal encodes a constant stored in the given global slot
+func SerializeStoreGlobal(slot uint16, val interface{}) ([]byte, error) {
+	valueBytes, err := serializeValue(val)
+	if err != nil {
+		return nil, err
+	}
+	return append(binary.BigEndian.AppendUint16([]byte{byte(STORE_GLOBAL)}, slot), valueBytes...), nil
 }
 
 func SerializePush(val interface{}) ([]byte, error) {
@@ -144,68 +388,76 @@ func SerializeOperator(op OpCode) []byte {
 	return []byte{byte(op)}
 }
 
-// format : [type][len][data]
+// SerializeMatchAny encodes the comparison of every element of an array
+func SerializeMatchAny(compare OpCode) []byte {
+	return []byte{byte(MATCH_ANY), byte(compare)}
+}
+
+// SerializeCallNative encodes a call to the native function at index with
+// argc arguments taken from the stack
+func SerializeCallNative(index, argc int) []byte {
+	bytes := binary.AppendUvarint([]byte{byte(CALL_NATIVE)}, uint64(index))
+	return binary.AppendUvarint(bytes, uint64(argc))
+}
+
+// SerializeJump encodes a jump, offset is relative to the next instruction
+func SerializeJump(op OpCode, offset int32) []byte {
+	return binary.BigEndian.AppendUint32([]byte{byte(op)}, uint32(offset))
+}
+
+// format : [type][len: uvarint][data]
 func serializeValue(val interface{}) ([]byte, error) {
-	typ, err := inferType(val)
+	v, err := ToValue(val)
 	if err != nil {
 		return nil, err
 	}
+	return appendValue(nil, v)
+}
 
-	switch v := val.(type) {
-	case int:
-		_, intVal := determineIntType(v)
-		switch typ {
-		case TYPE_INT8:
-			return []byte{byte(typ), 1, byte(intVal.(int8))}, nil
-		case TYPE_INT16:
-			buf := make([]byte, 2)
-			binary.BigEndian.PutUint16(buf, uint16(intVal.(int16)))
-			return append([]byte{byte(typ), 2}, buf...), nil
-		case TYPE_INT32:
-			buf := make([]byte, 4)
-			binary.BigEndian.PutUint32(buf, uint32(intVal.(int32)))
-			return append([]byte{byte(typ), 4}, buf...), nil
-		}
+// appendValue encodes v at the end of buf. Arrays are [type][count][items].
+func appendValue(buf []byte, v Value) ([]byte, error) {
+	buf = append(buf, byte(v.Type))
 
-	case string:
-		payload := []byte(v)
-		return append([]byte{byte(typ), byte(len(payload))}, payload...), nil
+	switch v.Type {
+	case TYPE_BOOL, TYPE_INT8, TYPE_UINT8:
+		return append(buf, 1, byte(v.num)), nil
+	case TYPE_INT16, TYPE_UINT16:
+		return binary.BigEndian.AppendUint16(append(buf, 2), uint16(v.num)), nil
+	case TYPE_INT32, TYPE_UINT32:
+		return binary.BigEndian.AppendUint32(append(buf, 4), uint32(v.num)), nil
+	case TYPE_INT64, TYPE_UINT64, TYPE_FLOAT64:
+		return binary.BigEndian.AppendUint64(append(buf, 8), v.num), nil
 
-	case bool:
-		var b byte
-		if v {
-			b = 1
+	case TYPE_STRING:
+		str := v.String()
+		if len(str) > MaxOperandLength {
+			return nil, fmt.Errorf("string of %d bytes exceeds the limit of %d bytes", len(str), MaxOperandLength)
 		}
-		return []byte{byte(typ), 1, b}, nil
+		buf = binary.AppendUvarint(buf, uint64(len(str)))
+		return append(buf, str...), nil
 
-	case []interface{}:
+	case TYPE_ARRAY:
 		// array nested — récursif
-		buf := []byte{byte(typ), byte(len(v))}
-		for _, elem := range v {
-			elemBytes, err := serializeValue(elem)
-			if err != nil {
-				return nil, err
+		elems := v.Array()
+		if len(elems) > MaxOperandLength {
+			return nil, fmt.Errorf("array of %d items exceeds the limit of %d items", len(elems), MaxOperandLength)
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(elems)))
+		for i, elem := range elems {
+			var err error
+			if buf, err = appendValue(buf, elem); err != nil {
+				return nil, fmt.Errorf("array item %d: %w", i, err)
 			}
-			buf = append(buf, elemBytes...)
 		}
 		return buf, nil
 	}
-
-	return nil, fmt.Errorf("unsupported type: %T", val)
+	return nil, fmt.Errorf("unsupported type for serialization: 0x%02x", byte(v.Type))
 }
 
 func inferType(val interface{}) (Type, error) {
-	switch v := val.(type) {
-	case int:
-		typ, _ := determineIntType(v)
-		return typ, nil
-	case string:
-		return TYPE_STRING, nil
-	case bool:
-		return TYPE_BOOL, nil
-	case []interface{}:
-		return TYPE_ARRAY, nil
-	default:
-		return 0, fmt.Errorf("unsupported type: %T", val)
+	v, err := ToValue(val)
+	if err != nil {
+		return 0, err
 	}
+	return v.Type, nil
 }
@@ -0,0 +1,261 @@
+package vm
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Instruction is one decoded instruction of a program, only the operands of
+// its opcode are set
+type Instruction struct {
+	Op      OpCode
+	PC      int    // offset of the op code
+	Value   Value  // PUSH, STORE_GLOBAL
+	Slot    int    // LOAD_GLOBAL, STORE_GLOBAL
+	Target  int    // jumps: absolute target
+	Index   int    // CALL_NATIVE, quantifiers: nested program
+	Argc    int    // CALL_NATIVE
+	Compare OpCode // MATCH_ANY
+}
+
+// decode reads the instruction at pc and moves pc to the next one. Operands
+// are checked against the bytecode bounds, the field table and the natives.
+func (vm *VM) decode() (Instruction, error) {
+	ins := Instruction{Op: OpCode(vm.bytecode[vm.pc]), PC: vm.pc}
+	vm.pc++ // skip op code
+
+	var err error
+	switch {
+	case ins.Op == PUSH:
+		ins.Value, err = vm.inferRuntimeValue()
+	case ins.Op == STORE_GLOBAL:
+		if ins.Slot, err = vm.readSlot(); err == nil {
+			ins.Value, err = vm.inferRuntimeValue()
+		}
+	case ins.Op == LOAD_GLOBAL:
+		ins.Slot, err = vm.readSlot()
+	case ins.Op == CALL_NATIVE:
+		if ins.Index, err = vm.readVarint(); err == nil {
+			ins.Argc, err = vm.readVarint()
+		}
+		if err == nil && ins.Index >= len(vm.nativeFuncs) {
+			err = fmt.Errorf("native function index out of bounds: %d at pc=%d", ins.Index, ins.PC)
+		}
+	case ins.Op.isJump():
+		ins.Target, err = vm.readJumpTarget()
+	case ins.Op == MATCH_ANY:
+		ins.Compare, err = vm.readComparison()
+	case ins.Op.isQuantifier():
+		ins.Index, err = vm.readVarint()
+		if err == nil && ins.Index >= len(vm.program.programs) {
+			err = fmt.Errorf("nested program index out of bounds: %d at pc=%d", ins.Index, ins.PC)
+		}
+	case int(ins.Op) >= len(handlers) || handlers[ins.Op] == nil:
+		err = fmt.Errorf("unknown opcode: 0x%02x at pc=%d", byte(ins.Op), ins.PC)
+	}
+	return ins, err
+}
+
+// typeUnknown marks a stack slot whose type is only known at runtime
+const typeUnknown Type = 0xFF
+
+// Verify checks the program once, before it is executed: every opcode is
+// known, operands fit in the bytecode, slots and natives exist, jumps go
+// forward to an instruction, and every path ends with exactly one boolean
+// on the stack. Nested programs are verified the same way. Execute refuses
+// a program that was not verified. Verify must be called before the program
+// is shared between goroutines.
+func (p *Program) Verify() error {
+	for i, nested := range p.programs {
+		if err := nested.Verify(); err != nil {
+			return fmt.Errorf("nested program %d: %w", i, err)
+		}
+	}
+
+	code, err := p.decodeAll()
+	if err != nil {
+		return err
+	}
+
+	// index of the instruction starting at each offset, the end of the
+	// bytecode is a valid target
+	at := make(map[int]int, len(code)+1)
+	for i, ins := range code {
+		at[ins.PC] = i
+	}
+	at[len(p.bytecode)] = len(code)
+
+	// entry stack of each instruction, nil when unreachable
+	states := make([][]Type, len(code)+1)
+	states[0] = []Type{}
+	maxDepth := 0
+
+	merge := func(i int, stack []Type, from int) error {
+		if states[i] == nil {
+			states[i] = slices.Clone(stack)
+			return nil
+		}
+		if len(states[i]) != len(stack) {
+			return fmt.Errorf("inconsistent stack depth at pc=%d: %d and %d", from, len(states[i]), len(stack))
+		}
+		for j, typ := range stack {
+			if states[i][j] != typ {
+				states[i][j] = typeUnknown
+			}
+		}
+		return nil
+	}
+
+	for i, ins := range code {
+		if states[i] == nil {
+			continue // dead code
+		}
+		stack := slices.Clone(states[i])
+		pop := func(n int) error {
+			if len(stack) < n {
+				return fmt.Errorf("stack underflow at pc=%d: need %d, have %d", ins.PC, n, len(stack))
+			}
+			stack = stack[:len(stack)-n]
+			return nil
+		}
+		popBool := func() error {
+			if len(stack) > 0 {
+				if typ := stack[len(stack)-1]; typ != TYPE_BOOL && typ != typeUnknown {
+					return fmt.Errorf("%v at pc=%d requires a boolean, got %v", ins.Op, ins.PC, typ)
+				}
+			}
+			return pop(1)
+		}
+
+		var err error
+		fallThrough := true
+		switch {
+		case ins.Op == PUSH:
+			stack = append(stack, ins.Value.Type)
+		case ins.Op == POP:
+			err = pop(1)
+		case ins.Op == STORE_GLOBAL:
+		case ins.Op == LOAD_GLOBAL:
+			stack = append(stack, typeUnknown)
+		case ins.Op == CALL_NATIVE:
+			if err = p.natives[ins.Index].CheckArgs(ins.Argc); err != nil {
+				err = fmt.Errorf("at pc=%d: %w", ins.PC, err)
+			} else if err = pop(ins.Argc); err == nil {
+				stack = append(stack, typeUnknown)
+			}
+		case ins.Op.isComparison():
+			if err = pop(2); err == nil {
+				stack = append(stack, TYPE_BOOL)
+			}
+		case ins.Op == MATCH_ANY:
+			if len(stack) > 1 {
+				if typ := stack[len(stack)-2]; typ != TYPE_ARRAY && typ != typeUnknown {
+					return fmt.Errorf("%v at pc=%d requires an array, got %v", ins.Op, ins.PC, typ)
+				}
+			}
+			if err = pop(2); err == nil {
+				stack = append(stack, TYPE_BOOL)
+			}
+		case ins.Op.isQuantifier():
+			if len(stack) > 0 {
+				if typ := stack[len(stack)-1]; typ != TYPE_ARRAY && typ != typeUnknown {
+					return fmt.Errorf("%v at pc=%d requires an array, got %v", ins.Op, ins.PC, typ)
+				}
+			}
+			if err = pop(1); err == nil {
+				stack = append(stack, TYPE_BOOL)
+			}
+		case ins.Op == OP_NOT:
+			if err = popBool(); err == nil {
+				stack = append(stack, TYPE_BOOL)
+			}
+		case ins.Op.isLogical():
+			if err = popBool(); err == nil {
+				if err = popBool(); err == nil {
+					stack = append(stack, TYPE_BOOL)
+				}
+			}
+		case ins.Op.isArithmetic():
+			// strings may hold numbers, they are checked at runtime
+			for _, typ := range stack[max(0, len(stack)-2):] {
+				switch KindOf(typ) {
+				case KindInteger, KindFloat, KindString, KindAny:
+				default:
+					return fmt.Errorf("%v at pc=%d requires numbers, got %v", ins.Op, ins.PC, typ)
+				}
+			}
+			if err = pop(2); err == nil {
+				stack = append(stack, typeUnknown)
+			}
+		case ins.Op.isAggregate():
+			if len(stack) > 0 {
+				if typ := stack[len(stack)-1]; typ != TYPE_ARRAY && typ != typeUnknown {
+					return fmt.Errorf("%v at pc=%d requires an array, got %v", ins.Op, ins.PC, typ)
+				}
+			}
+			if err = pop(1); err == nil {
+				stack = append(stack, aggregateTypes[ins.Op])
+			}
+		case ins.Op.isJump():
+			next := ins.PC + 5
+			if ins.Target < next {
+				return fmt.Errorf("backward jump at pc=%d: loops are not allowed", ins.PC)
+			}
+			target, ok := at[ins.Target]
+			if !ok {
+				return fmt.Errorf("jump target %d at pc=%d is not an instruction", ins.Target, ins.PC)
+			}
+
+			switch ins.Op {
+			case JUMP:
+				fallThrough = false
+			case JUMP_IF_FALSE_OR_POP, JUMP_IF_TRUE_OR_POP:
+				// the condition stays on the stack when the jump is taken
+				if err = popBool(); err == nil {
+					err = merge(target, append(stack, TYPE_BOOL), ins.PC)
+				}
+			default:
+				err = popBool()
+			}
+			if err == nil && ins.Op != JUMP_IF_FALSE_OR_POP && ins.Op != JUMP_IF_TRUE_OR_POP {
+				err = merge(target, stack, ins.PC)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		maxDepth = max(maxDepth, len(stack))
+		if fallThrough {
+			if err := merge(i+1, stack, ins.PC); err != nil {
+				return err
+			}
+		}
+	}
+
+	end := states[len(code)]
+	if len(end) != 1 {
+		return fmt.Errorf("program must end with exactly one boolean on the stack, got %d values", len(end))
+	}
+	if end[0] != TYPE_BOOL {
+		return fmt.Errorf("program must end with a boolean, got %v", end[0])
+	}
+
+	p.maxStack = max(p.maxStack, maxDepth)
+	p.verified = true
+	return nil
+}
+
+// decodeAll decodes the instruction stream in order
+func (p *Program) decodeAll() ([]Instruction, error) {
+	vm := p.NewVM()
+	var code []Instruction
+	for vm.pc < len(vm.bytecode) {
+		ins, err := vm.decode()
+		if err != nil {
+			return nil, err
+		}
+		code = append(code, ins)
+	}
+	return code, nil
+}
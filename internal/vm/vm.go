@@ -4,12 +4,20 @@ import (
 	"fmt"
 )
 
+// VM is the execution state of a Program: program counter, globals and data
+// stack. A VM must not be used by several goroutines at the same time.
 type VM struct {
+	program     *Program
 	bytecode    []byte
 	pc          int
-	globals     map[string]Value
+	globals     []Value // indexed by slot, see Program.Fields
+	defined     []bool  // globals[slot] was loaded or stored
+	errs        []error // lookup error of an undefined slot, raised by LOAD_GLOBAL
 	dataStack   []Value
-	nativeFuncs []NativeFunc // O(1) native funcs access with index
+	arena       []Value // elements of the arrays decoded or loaded since the last Reset
+	nativeFuncs []NativeFunc
+	tracer      Tracer // nil unless tracing, see SetTracer
+	nested      []*VM  // VM of each nested program, created on first use
 }
 
 func (vm *VM) DataStack() []Value {
@@ -17,61 +25,112 @@ func (vm *VM) DataStack() []Value {
 }
 
 func NewVM(bytecode []byte, nativeFuncs []NativeFunc) *VM {
-	return &VM{bytecode: bytecode, globals: make(map[string]Value), dataStack: make([]Value, 0), nativeFuncs: nativeFuncs}
+	return NewProgram(bytecode, nil, 0, anonymous(nativeFuncs)).NewVM()
 }
 
+// ApplyAny returns whether compare holds between one element of array and
+// right, like MATCH_ANY
+func ApplyAny(compare OpCode, array, right Value) (Value, error) {
+	if !compare.isComparison() {
+		return Value{}, fmt.Errorf("%v is not a comparison", compare)
+	}
+	vm := NewVM(SerializeMatchAny(compare), nil)
+	vm.pc = 1 // skip op code
+	vm.push(array)
+	vm.push(right)
+	if err := vm.matchAnyHandler(); err != nil {
+		return Value{}, err
+	}
+	return vm.dataStack[0], nil
+}
+
+// Apply runs the handler of op on the operands, pushed in order, and returns
+// its result. It evaluates one operator outside a program with the same
+// semantics as Execute.
+func Apply(op OpCode, operands ...Value) (Value, error) {
+	arity := 2
+	switch {
+	case op == OP_NOT || op.isAggregate():
+		arity = 1
+	case !op.isComparison() && !op.isLogical() && !op.isArithmetic():
+		return Value{}, fmt.Errorf("%v cannot be applied to values", op)
+	}
+	if len(operands) != arity {
+		return Value{}, fmt.Errorf("%v expects %d operands, got %d", op, arity, len(operands))
+	}
+
+	vm := NewVM(nil, nil)
+	for _, operand := range operands {
+		vm.push(operand)
+	}
+	if err := handlers[op](vm); err != nil {
+		return Value{}, err
+	}
+	return vm.dataStack[0], nil
+}
+
+func (vm *VM) Program() *Program {
+	return vm.program
+}
+
+// LoadRecords converts the record fields referenced by the program into
+// globals, other keys of the record are ignored. Dotted fields are resolved
+// through nested objects by Lookup, a field missing from the record stays
+// undefined and LOAD_GLOBAL reports it. A path that cannot be resolved is
+// reported the same way, only if the field is read.
 func (vm *VM) LoadRecords(records map[string]interface{}) error {
-	for key, val := range records {
-		switch v := val.(type) {
-		case string:
-			vm.globals[key] = Value{Type: TYPE_STRING, String: v}
-		case int:
-			// infer precision
-			typ, intVal := determineIntType(v)
-			switch typ {
-			case TYPE_INT8:
-				vm.globals[key] = Value{Type: TYPE_INT8, Int8: intVal.(int8)}
-			case TYPE_INT16:
-				vm.globals[key] = Value{Type: TYPE_INT16, Int16: intVal.(int16)}
-			case TYPE_INT32:
-				vm.globals[key] = Value{Type: TYPE_INT32, Int32: intVal.(int32)}
-			}
-		case bool:
-			vm.globals[key] = Value{Type: TYPE_BOOL, Bool: v}
-		case []interface{}:
-			array := make([]Value, len(v))
-			for i, item := range v {
-				converted, err := vm.convertInterfaceToValue(item)
-				if err != nil {
-					return fmt.Errorf("failed to convert array element %d: %v", i, err)
-				}
-				array[i] = converted
-			}
-			vm.globals[key] = Value{Type: TYPE_ARRAY, Array: array}
-		default:
-			return fmt.Errorf("unsupported type for field %s", key)
+	for slot, key := range vm.program.fields {
+		val, exists, err := Lookup(records, key)
+		if err != nil {
+			vm.errs[slot] = fmt.Errorf("field %s: %v", key, err)
+			continue
+		}
+		if !exists {
+			continue
+		}
+		value, err := vm.convertInterfaceToValue(val)
+		if err != nil {
+			return fmt.Errorf("unsupported type for field %s: %v", key, err)
 		}
+		vm.globals[slot] = value
+		vm.defined[slot] = true
 	}
 	return nil
 }
 
-// Reset the VM state to its initial state (native functions are not reset)
+// Reset the VM state to its initial state (native functions are not reset).
+// Buffers are kept, so a reused VM does not allocate. Values read from a
+// previous run are no longer valid after Reset.
 func (vm *VM) Reset() {
 	vm.pc = 0
-	vm.globals = make(map[string]Value)
-	vm.dataStack = make([]Value, 0)
+	clear(vm.defined)
+	clear(vm.errs)
+	vm.dataStack = vm.dataStack[:0]
+	vm.arena = vm.arena[:0]
 }
 
+// Execute runs a verified program
 func (vm *VM) Execute() error {
+	if !vm.program.verified {
+		return fmt.Errorf("program is not verified")
+	}
+	if vm.tracer != nil {
+		return vm.runTraced()
+	}
+	return vm.run()
+}
+
+// run executes the bytecode without checking it, handler tests run fragments
+// that are not complete programs
+func (vm *VM) run() error {
 	for vm.pc < len(vm.bytecode) {
 		opCode := OpCode(vm.bytecode[vm.pc])
 		vm.pc++ // skip op code
-		handler := handlers[opCode]
-		if handler == nil {
+		if int(opCode) >= len(handlers) || handlers[opCode] == nil {
 			return fmt.Errorf("unknown opcode: 0x%02x at pc=%d", opCode, vm.pc)
 		}
 
-		if err := handler(vm); err != nil {
+		if err := handlers[opCode](vm); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,131 @@
+package vm
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// Aggregation Tests
+// ============================================================================
+
+// Helper pour construire un tableau
+func values(elems ...Value) Value {
+	return ArrayValue(elems)
+}
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		name         string
+		op           OpCode
+		array        Value
+		expectedType Type
+		expected     string
+	}{
+		{"count", OP_COUNT, values(StringValue("a"), BoolValue(true), Int8Value(1)), TYPE_INT64, "3"},
+		{"count empty", OP_COUNT, values(), TYPE_INT64, "0"},
+		{"sum", OP_SUM, values(Int8Value(100), Uint16Value(300), Int32Value(-50)), TYPE_INT64, "350"},
+		{"sum empty", OP_SUM, values(), TYPE_INT64, "0"},
+		{"sum with float", OP_SUM, values(Int8Value(1), Float64Value(0.5)), TYPE_FLOAT64, "1.5"},
+		{"sum numeric strings", OP_SUM, values(StringValue("10"), Int8Value(5)), TYPE_INT64, "15"},
+		{"sum above int64", OP_SUM, values(Int64Value(math.MaxInt64), Int8Value(1)), TYPE_UINT64, "9223372036854775808"},
+		{"avg", OP_AVG, values(Int8Value(1), Int8Value(2)), TYPE_FLOAT64, "1.5"},
+		{"avg numeric strings", OP_AVG, values(StringValue("4"), Float64Value(2)), TYPE_FLOAT64, "3"},
+		{"min", OP_MIN, values(Int8Value(3), Float64Value(-1.5), Uint8Value(2)), TYPE_FLOAT64, "-1.5"},
+		{"max", OP_MAX, values(Int8Value(3), Uint64Value(math.MaxUint64), Int8Value(2)), TYPE_UINT64, "18446744073709551615"},
+		{"max strings", OP_MAX, values(StringValue("apple"), StringValue("pear")), TYPE_STRING, "pear"},
+		{"min numeric string", OP_MIN, values(Int8Value(10), StringValue("9")), TYPE_STRING, "9"},
+		{"single element", OP_MIN, values(BoolValue(true)), TYPE_BOOL, "true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Apply(tt.op, tt.array)
+			if err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+			if result.Type != tt.expectedType || result.String() != tt.expected {
+				t.Errorf("got %v %s, want %v %s", result.Type, result.String(), tt.expectedType, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAggregate_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		op          OpCode
+		array       Value
+		errContains string
+	}{
+		{"not an array", OP_COUNT, StringValue("a,b"), "COUNT requires an array, got STRING"},
+		{"avg empty", OP_AVG, values(), "AVG of an empty array"},
+		{"min empty", OP_MIN, values(), "MIN of an empty array"},
+		{"max empty", OP_MAX, values(), "MAX of an empty array"},
+		{"sum bool", OP_SUM, values(Int8Value(1), BoolValue(true)), "SUM requires numbers, element 1 is BOOL"},
+		{"avg text", OP_AVG, values(StringValue("abc")), `AVG requires numbers, element 0 is string "abc"`},
+		{"sum overflow", OP_SUM, values(Uint64Value(math.MaxUint64), Int8Value(1)), "SUM: integer overflow"},
+		{"avg overflow", OP_AVG, values(Float64Value(math.MaxFloat64), Float64Value(math.MaxFloat64)), "AVG: float overflow"},
+		{"max mixed", OP_MAX, values(Int8Value(1), BoolValue(true)), "MAX element 1: cannot compare different types"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Apply(tt.op, tt.array)
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestAggregate_Execute(t *testing.T) {
+	// SUM(line_totals)>=500
+	vm := assembleVM(t, `
+		LOAD_GLOBAL "line_totals"
+		OP_SUM
+		PUSH INT16 500
+		OP_GTE
+	`)
+	if err := vm.Program().Verify(); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if err := vm.LoadRecords(map[string]interface{}{"line_totals": []interface{}{250, 199.5, "50.5"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	assertStackValue(t, vm, TYPE_BOOL, func(v Value) bool { return v.Bool() })
+}
+
+func TestVerify_Aggregate(t *testing.T) {
+	valid := NewProgram(concat(
+		mustPush(t, []interface{}{1, 2}), SerializeOperator(OP_COUNT), mustPush(t, 2), SerializeOperator(OP_EQ),
+	), nil, 0, nil)
+	if err := valid.Verify(); err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+
+	invalid := NewProgram(concat(
+		mustPush(t, "a"), SerializeOperator(OP_MAX), mustPush(t, 0), SerializeOperator(OP_EQ),
+	), nil, 0, nil)
+	if err := invalid.Verify(); err == nil || !strings.Contains(err.Error(), "OP_MAX at pc=4 requires an array, got STRING") {
+		t.Errorf("Expected type error, got %v", err)
+	}
+
+	// COUNT est un entier, pas un booléen
+	count := NewProgram(concat(mustPush(t, []interface{}{}), SerializeOperator(OP_COUNT)), nil, 0, nil)
+	if err := count.Verify(); err == nil {
+		t.Error("Expected error for a program ending with a number")
+	}
+}
+
+func TestRegister_Aggregate(t *testing.T) {
+	funcs := NewFunctions()
+	err := funcs.Register(Function{Name: "SUM", MinArgs: 1, MaxArgs: 1, Func: upperFunc})
+	if err == nil || !strings.Contains(err.Error(), "SUM is a built-in aggregation") {
+		t.Errorf("Expected reserved name error, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package vm
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// Arithmetic Tests
+// ============================================================================
+
+func TestArithmetic(t *testing.T) {
+	tests := []struct {
+		name         string
+		op           OpCode
+		left, right  Value
+		expectedType Type
+		expected     string
+	}{
+		{"int8 + int8 widens", OP_ADD, Int8Value(127), Int8Value(1), TYPE_INT64, "128"},
+		{"int16 - uint8", OP_SUB, Int16Value(3), Uint8Value(5), TYPE_INT64, "-2"},
+		{"uint8 - uint8 below zero", OP_SUB, Uint8Value(3), Uint8Value(5), TYPE_INT64, "-2"},
+		{"int32 * int64", OP_MUL, Int32Value(-4), Int64Value(5), TYPE_INT64, "-20"},
+		{"division truncates", OP_DIV, Int8Value(-7), Int8Value(2), TYPE_INT64, "-3"},
+		{"modulo sign of dividend", OP_MOD, Int8Value(-7), Int8Value(3), TYPE_INT64, "-1"},
+		{"uint64 above int64", OP_ADD, Uint64Value(math.MaxInt64), Int8Value(1), TYPE_UINT64, "9223372036854775808"},
+		{"uint64 max", OP_SUB, Uint64Value(math.MaxUint64), Int8Value(0), TYPE_UINT64, "18446744073709551615"},
+		{"uint64 with negative", OP_ADD, Uint64Value(1 << 63), Int8Value(-1), TYPE_INT64, "9223372036854775807"},
+		{"min int64", OP_SUB, Int64Value(math.MinInt64 + 1), Int8Value(1), TYPE_INT64, "-9223372036854775808"},
+		{"min int64 / -1 in uint64", OP_DIV, Int64Value(math.MinInt64), Int8Value(-1), TYPE_UINT64, "9223372036854775808"},
+		{"negative zero", OP_MUL, Int8Value(-3), Int8Value(0), TYPE_INT64, "0"},
+		{"float", OP_MUL, Float64Value(19.99), Int8Value(3), TYPE_FLOAT64, "59.97"},
+		{"float modulo", OP_MOD, Float64Value(7.5), Int8Value(2), TYPE_FLOAT64, "1.5"},
+		{"numeric string", OP_SUB, StringValue("3600"), Int16Value(600), TYPE_INT64, "3000"},
+		{"decimal string", OP_ADD, StringValue("1.5"), StringValue("1"), TYPE_FLOAT64, "2.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Apply(tt.op, tt.left, tt.right)
+			if err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+			if result.Type != tt.expectedType || result.String() != tt.expected {
+				t.Errorf("got %v %s, want %v %s", result.Type, result.String(), tt.expectedType, tt.expected)
+			}
+		})
+	}
+}
+
+func TestArithmetic_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		op          OpCode
+		left, right Value
+		errContains string
+	}{
+		{"int64 overflow", OP_ADD, Int64Value(math.MinInt64), Int8Value(-1), "integer overflow: -9223372036854775808 + -1"},
+		{"uint64 overflow", OP_ADD, Uint64Value(math.MaxUint64), Uint8Value(1), "integer overflow"},
+		{"mul overflow", OP_MUL, Int64Value(1 << 40), Int64Value(1 << 40), "integer overflow"},
+		{"negative below min int64", OP_SUB, Int8Value(-2), Uint64Value(math.MaxUint64), "integer overflow"},
+		{"division by zero", OP_DIV, Int8Value(1), Uint8Value(0), "division by zero"},
+		{"modulo by zero", OP_MOD, Int8Value(1), Int8Value(0), "division by zero"},
+		{"float division by zero", OP_DIV, Float64Value(1), Float64Value(0), "division by zero"},
+		{"float overflow", OP_MUL, Float64Value(math.MaxFloat64), Int8Value(2), "float overflow"},
+		{"bool", OP_ADD, BoolValue(true), Int8Value(1), "+ requires numbers, got BOOL"},
+		{"text", OP_MUL, Int8Value(1), StringValue("abc"), `* requires numbers, got string "abc"`},
+		{"array", OP_SUB, ArrayValue(nil), Int8Value(1), "- requires numbers, got ARRAY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Apply(tt.op, tt.left, tt.right)
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestArithmetic_Execute(t *testing.T) {
+	// price*quantity>1000
+	vm := assembleVM(t, `
+		LOAD_GLOBAL "price"
+		LOAD_GLOBAL "quantity"
+		OP_MUL
+		PUSH INT16 1000
+		OP_GT
+	`)
+	if err := vm.Program().Verify(); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if err := vm.LoadRecords(map[string]interface{}{"price": 19.99, "quantity": uint16(60)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	assertStackValue(t, vm, TYPE_BOOL, func(v Value) bool { return v.Bool() })
+
+	vm.Reset()
+	if err := vm.LoadRecords(map[string]interface{}{"price": 1, "quantity": "many"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Execute(); err == nil || !strings.Contains(err.Error(), "requires numbers") {
+		t.Errorf("Expected type error, got %v", err)
+	}
+}
+
+func TestVerify_Arithmetic(t *testing.T) {
+	valid := NewProgram(concat(
+		mustPush(t, "12"), mustPush(t, 3), SerializeOperator(OP_MOD), mustPush(t, 0), SerializeOperator(OP_EQ),
+	), nil, 0, nil)
+	if err := valid.Verify(); err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+
+	invalid := NewProgram(concat(
+		mustPush(t, true), mustPush(t, 1), SerializeOperator(OP_ADD), mustPush(t, 0), SerializeOperator(OP_EQ),
+	), nil, 0, nil)
+	if err := invalid.Verify(); err == nil || !strings.Contains(err.Error(), "OP_ADD at pc=8 requires numbers, got BOOL") {
+		t.Errorf("Expected type error, got %v", err)
+	}
+
+	// le résultat n'est pas un booléen
+	number := NewProgram(concat(mustPush(t, 1), mustPush(t, 1), SerializeOperator(OP_ADD)), nil, 0, nil)
+	if err := number.Verify(); err == nil {
+		t.Error("Expected error for a program ending with a number")
+	}
+}
@@ -0,0 +1,234 @@
+package vm
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// Assembler Tests
+// ============================================================================
+
+func TestAssemble(t *testing.T) {
+	source := `
+		; status=active ^ tags IN a,2
+		LOAD_GLOBAL "status"
+		PUSH STRING "active"
+		OP_EQ
+		JUMP_IF_FALSE_OR_POP end
+		LOAD_GLOBAL "tags"
+		PUSH ARRAY [STRING "a", INT8 2]   ; IN list
+		OP_IN
+	end:
+	`
+	program, err := Assemble(source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := concat(
+		SerializeLoadGlobal(0), mustPush(t, "active"), SerializeOperator(OP_EQ),
+		SerializeJump(JUMP_IF_FALSE_OR_POP, 13),
+		SerializeLoadGlobal(1), mustPush(t, []interface{}{"a", 2}), SerializeOperator(OP_IN),
+	)
+	assertBytecodeEqual(t, program.Bytecode(), expected)
+	if !slices.Equal(program.Fields(), []string{"status", "tags"}) {
+		t.Errorf("expected fields [status tags], got %v", program.Fields())
+	}
+	if err := program.Verify(); err != nil {
+		t.Errorf("assembled program should verify: %v", err)
+	}
+}
+
+func TestAssemble_Operands(t *testing.T) {
+	store, err := SerializeStoreGlobal(0, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		source   string
+		expected []byte
+	}{
+		{"PUSH BOOL false", mustPush(t, false)},
+		{"PUSH INT8 -128", mustPush(t, int8(-128))},
+		{"PUSH INT16 0x7fff", mustPush(t, int16(0x7fff))},
+		{"PUSH INT32 7", mustPush(t, int32(7))},
+		{"PUSH INT64 -1", mustPush(t, int64(-1))},
+		{"PUSH UINT8 255", mustPush(t, uint8(255))},
+		{"PUSH UINT16 7", mustPush(t, uint16(7))},
+		{"PUSH UINT32 7", mustPush(t, uint32(7))},
+		{"PUSH UINT64 18446744073709551615", mustPush(t, uint64(1<<64-1))},
+		{"PUSH FLOAT64 -2.5e3", mustPush(t, -2.5e3)},
+		{`PUSH STRING "a;b \"c\""`, mustPush(t, `a;b "c"`)},
+		{"PUSH ARRAY []", mustPush(t, []interface{}{})},
+		{"PUSH ARRAY [ARRAY [BOOL true], STRING \"x\"]", mustPush(t, []interface{}{[]interface{}{true}, "x"})},
+		{`STORE_GLOBAL "field" STRING "x"`, store},
+		{"CALL_NATIVE 0 2", SerializeCallNative(0, 2)},
+		{"JUMP -5", SerializeJump(JUMP, -5)},
+		{"JUMP +0", SerializeJump(JUMP, 0)},
+		{"self: JUMP self", SerializeJump(JUMP, -5)},
+		{"0042  POP", SerializeOperator(POP)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			program, err := Assemble(tt.source, []NativeFunc{nil})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertBytecodeEqual(t, program.Bytecode(), tt.expected)
+		})
+	}
+}
+
+func TestAssemble_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		errMsg string
+	}{
+		{"unknown mnemonic", "OP_EQ\nOP_NOPE", "line 2: unknown mnemonic"},
+		{"missing value", "PUSH", "line 1: PUSH: expected a type"},
+		{"unknown type", "PUSH INT128 1", "unknown type"},
+		{"int overflow", "PUSH INT8 128", "invalid INT8"},
+		{"negative unsigned", "PUSH UINT8 -1", "invalid UINT8"},
+		{"unquoted string", "PUSH STRING abc", "expected a quoted string"},
+		{"unterminated string", `PUSH STRING "abc`, "unterminated string"},
+		{"unterminated array", "PUSH ARRAY [INT8 1", "unterminated array"},
+		{"array without comma", "PUSH ARRAY [INT8 1 INT8 2]", "expected ','"},
+		{"bad array item", "PUSH ARRAY [INT8 x]", "array item 0"},
+		{"trailing operand", "OP_NOT 1", "unexpected \"1\""},
+		{"unquoted field", "LOAD_GLOBAL status", "expected a quoted field name"},
+		{"native out of bounds", "CALL_NATIVE 1 0", "invalid native function index"},
+		{"missing argc", "CALL_NATIVE 0", "expected a function index"},
+		{"missing jump target", "JUMP", "expected a label or an offset"},
+		{"bad offset", "JUMP +x", "invalid jump offset"},
+		{"undefined label", "PUSH BOOL true\nJUMP nowhere", "line 2: undefined label"},
+		{"duplicate label", "a:\na: POP", "line 2: label \"a\" already defined"},
+		{"invalid label", "1a: POP", "invalid label"},
+		{"program out of order", "POP\n; program 1\nPOP", "program 1 does not follow"},
+		{"nested without parent", "POP\n; program 0.0\nPOP", "program 0.0 does not follow"},
+		{"duplicate program", "POP\n; program 0\nPOP\n; program 0\nPOP", "line 4: program 0 already defined"},
+		{"label in nested program", "POP\n; program 0\nJUMP end", "program 0: line 3: undefined label"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Assemble(tt.source, []NativeFunc{nil})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestAssemble_DisassembleRoundTrip(t *testing.T) {
+	store, err := SerializeStoreGlobal(0, []interface{}{uint16(3), -1.5, "é\t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bytecode := concat(
+		store,
+		SerializeLoadGlobal(0), mustPush(t, int64(-1)), SerializeOperator(OP_GTE),
+		SerializeJump(JUMP_IF_TRUE_OR_POP, 11),
+		SerializeLoadGlobal(1), mustPush(t, uint32(9)), SerializeOperator(OP_CONTAINS),
+		SerializeOperator(OP_NOT),
+		mustPush(t, 8), mustPush(t, 9), SerializeCallNative(0, 2), SerializeOperator(POP),
+	)
+	program := NewProgram(bytecode, []string{"a", "b"}, 0, []Function{{}})
+
+	listing, err := program.Disassemble()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assembled, err := Assemble(listing, []NativeFunc{nil})
+	if err != nil {
+		t.Fatalf("Assemble failed on:\n%s\n%v", listing, err)
+	}
+	if !bytes.Equal(assembled.Bytecode(), bytecode) {
+		t.Errorf("round trip changed the bytecode:\n%s", listing)
+	}
+	if !slices.Equal(assembled.Fields(), program.Fields()) {
+		t.Errorf("round trip changed the fields: %v", assembled.Fields())
+	}
+}
+
+func TestAssemble_NestedProgramsRoundTrip(t *testing.T) {
+	// ANY(orders, ALL(lines, price>0)) ^ ANY(items, sku="X" ^ qty>2)
+	source := `
+		LOAD_GLOBAL "orders"
+		ANY_OF 0
+		JUMP_IF_FALSE_OR_POP end
+		LOAD_GLOBAL "items"
+		ANY_OF 1
+	end:
+	; program 0
+		LOAD_GLOBAL "lines"
+		ALL_OF 0
+	; program 0.0
+		LOAD_GLOBAL "price"
+		PUSH INT8 0
+		OP_GT
+	; program 1
+		LOAD_GLOBAL "sku"
+		PUSH STRING "X"
+		OP_EQ
+		JUMP_IF_FALSE_OR_POP done
+		LOAD_GLOBAL "qty"
+		PUSH INT8 2
+		OP_GT
+	done:
+	`
+	program, err := Assemble(source, nil)
+	if err != nil {
+		t.Fatalf("Assemble failed: %v", err)
+	}
+	if err := program.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if len(program.Programs()) != 2 || len(program.Programs()[0].Programs()) != 1 {
+		t.Fatalf("unexpected nested programs: %v", program.Programs())
+	}
+	if fields := program.Programs()[1].Fields(); !slices.Equal(fields, []string{"sku", "qty"}) {
+		t.Errorf("nested program 1 has fields %v", fields)
+	}
+
+	// le listing réassemblé redonne le même programme
+	listing, err := program.Disassemble()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assembled, err := Assemble(listing, nil)
+	if err != nil {
+		t.Fatalf("Assemble failed on:\n%s\n%v", listing, err)
+	}
+	again, err := assembled.Disassemble()
+	if err != nil || again != listing {
+		t.Errorf("round trip changed the listing:\n%s\nwant:\n%s", again, listing)
+	}
+	if !bytes.Equal(assembled.Bytecode(), program.Bytecode()) {
+		t.Error("round trip changed the root bytecode")
+	}
+
+	if err := assembled.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	vm := assembled.NewVM()
+	records := map[string]interface{}{
+		"orders": []interface{}{map[string]interface{}{"lines": []interface{}{map[string]interface{}{"price": 3}}}},
+		"items":  []interface{}{item("Y", 5), item("X", 3)},
+	}
+	if err := vm.LoadRecords(records); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	assertStackValue(t, vm, TYPE_BOOL, func(v Value) bool { return v.Bool() })
+}
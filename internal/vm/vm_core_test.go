@@ -42,11 +42,11 @@ func TestLoadRecords(t *testing.T) {
 			records: map[string]interface{}{"name": "Alice"},
 			checks: []func(*testing.T, *VM){
 				func(t *testing.T, vm *VM) {
-					val, exists := vm.globals["name"]
+					val, exists := lookupGlobal(vm, "name")
 					if !exists {
 						t.Fatal("Expected 'name' to be in globals")
 					}
-					if val.Type != TYPE_STRING || val.String != "Alice" {
+					if val.Type != TYPE_STRING || val.String() != "Alice" {
 						t.Errorf("Expected string(Alice), got %+v", val)
 					}
 				},
@@ -57,8 +57,8 @@ func TestLoadRecords(t *testing.T) {
 			records: map[string]interface{}{"age": 42},
 			checks: []func(*testing.T, *VM){
 				func(t *testing.T, vm *VM) {
-					val := vm.globals["age"]
-					if val.Type != TYPE_INT8 || val.Int8 != 42 {
+					val, _ := lookupGlobal(vm, "age")
+					if val.Type != TYPE_INT8 || val.Int64() != 42 {
 						t.Errorf("Expected int8(42), got %+v", val)
 					}
 				},
@@ -69,8 +69,8 @@ func TestLoadRecords(t *testing.T) {
 			records: map[string]interface{}{"port": 8080},
 			checks: []func(*testing.T, *VM){
 				func(t *testing.T, vm *VM) {
-					val := vm.globals["port"]
-					if val.Type != TYPE_INT16 || val.Int16 != 8080 {
+					val, _ := lookupGlobal(vm, "port")
+					if val.Type != TYPE_INT16 || val.Int64() != 8080 {
 						t.Errorf("Expected int16(8080), got %+v", val)
 					}
 				},
@@ -81,20 +81,44 @@ func TestLoadRecords(t *testing.T) {
 			records: map[string]interface{}{"population": 1000000},
 			checks: []func(*testing.T, *VM){
 				func(t *testing.T, vm *VM) {
-					val := vm.globals["population"]
-					if val.Type != TYPE_INT32 || val.Int32 != 1000000 {
+					val, _ := lookupGlobal(vm, "population")
+					if val.Type != TYPE_INT32 || val.Int64() != 1000000 {
 						t.Errorf("Expected int32(1000000), got %+v", val)
 					}
 				},
 			},
 		},
+		{
+			name:    "int64 from int",
+			records: map[string]interface{}{"ts": 1700000000000},
+			checks: []func(*testing.T, *VM){
+				func(t *testing.T, vm *VM) {
+					val, _ := lookupGlobal(vm, "ts")
+					if val.Type != TYPE_INT64 || val.Int64() != 1700000000000 {
+						t.Errorf("Expected int64(1700000000000), got %+v", val)
+					}
+				},
+			},
+		},
+		{
+			name:    "uint32",
+			records: map[string]interface{}{"id": uint32(4000000000)},
+			checks: []func(*testing.T, *VM){
+				func(t *testing.T, vm *VM) {
+					val, _ := lookupGlobal(vm, "id")
+					if val.Type != TYPE_UINT32 || val.Uint64() != 4000000000 {
+						t.Errorf("Expected uint32(4000000000), got %+v", val)
+					}
+				},
+			},
+		},
 		{
 			name:    "bool",
 			records: map[string]interface{}{"active": true},
 			checks: []func(*testing.T, *VM){
 				func(t *testing.T, vm *VM) {
-					val := vm.globals["active"]
-					if val.Type != TYPE_BOOL || val.Bool != true {
+					val, _ := lookupGlobal(vm, "active")
+					if val.Type != TYPE_BOOL || val.Bool() != true {
 						t.Errorf("Expected bool(true), got %+v", val)
 					}
 				},
@@ -105,12 +129,12 @@ func TestLoadRecords(t *testing.T) {
 			records: map[string]interface{}{"tags": []interface{}{"admin", "user"}},
 			checks: []func(*testing.T, *VM){
 				func(t *testing.T, vm *VM) {
-					val := vm.globals["tags"]
-					if val.Type != TYPE_ARRAY || len(val.Array) != 2 {
+					val, _ := lookupGlobal(vm, "tags")
+					if val.Type != TYPE_ARRAY || len(val.Array()) != 2 {
 						t.Errorf("Expected array[2], got %+v", val)
 					}
-					if val.Array[0].String != "admin" || val.Array[1].String != "user" {
-						t.Errorf("Expected [admin, user], got %+v", val.Array)
+					if val.Array()[0].String() != "admin" || val.Array()[1].String() != "user" {
+						t.Errorf("Expected [admin, user], got %+v", val.Array())
 					}
 				},
 			},
@@ -120,13 +144,13 @@ func TestLoadRecords(t *testing.T) {
 			records: map[string]interface{}{"numbers": []interface{}{1, 2, 3}},
 			checks: []func(*testing.T, *VM){
 				func(t *testing.T, vm *VM) {
-					val := vm.globals["numbers"]
-					if val.Type != TYPE_ARRAY || len(val.Array) != 3 {
+					val, _ := lookupGlobal(vm, "numbers")
+					if val.Type != TYPE_ARRAY || len(val.Array()) != 3 {
 						t.Errorf("Expected array[3], got %+v", val)
 					}
-					for i, expected := range []int8{1, 2, 3} {
-						if val.Array[i].Int8 != expected {
-							t.Errorf("Expected numbers[%d]=%d, got %d", i, expected, val.Array[i].Int8)
+					for i, expected := range []int64{1, 2, 3} {
+						if val.Array()[i].Int64() != expected {
+							t.Errorf("Expected numbers[%d]=%d, got %d", i, expected, val.Array()[i].Int64())
 						}
 					}
 				},
@@ -137,8 +161,10 @@ func TestLoadRecords(t *testing.T) {
 			records: map[string]interface{}{"name": "Bob", "age": 30, "active": false},
 			checks: []func(*testing.T, *VM){
 				func(t *testing.T, vm *VM) {
-					if len(vm.globals) != 3 {
-						t.Errorf("Expected 3 globals, got %d", len(vm.globals))
+					for _, name := range []string{"name", "age", "active"} {
+						if _, exists := lookupGlobal(vm, name); !exists {
+							t.Errorf("Expected '%s' to be in globals", name)
+						}
 					}
 				},
 			},
@@ -147,7 +173,11 @@ func TestLoadRecords(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			vm := NewVM([]byte{}, nil)
+			fields := make([]string, 0, len(tt.records))
+			for name := range tt.records {
+				fields = append(fields, name)
+			}
+			vm := newTestVM([]byte{}, fields...)
 			err := vm.LoadRecords(tt.records)
 			if err != nil {
 				t.Fatalf("LoadRecords failed: %v", err)
@@ -159,19 +189,46 @@ func TestLoadRecords(t *testing.T) {
 	}
 }
 
+func TestLoadRecords_OnlyReferencedFields(t *testing.T) {
+	vm := newTestVM([]byte{}, "status")
+	records := map[string]interface{}{
+		"status":  "active",
+		"payload": struct{}{}, // unsupported, but not referenced
+	}
+	if err := vm.LoadRecords(records); err != nil {
+		t.Fatalf("LoadRecords failed: %v", err)
+	}
+
+	if val, exists := lookupGlobal(vm, "status"); !exists || val.String() != "active" {
+		t.Errorf("Expected status=active, got %+v", val)
+	}
+	if len(vm.globals) != 1 {
+		t.Errorf("Expected 1 global slot, got %d", len(vm.globals))
+	}
+}
+
+func TestLoadRecords_UnsupportedReferencedField(t *testing.T) {
+	vm := newTestVM([]byte{}, "payload")
+	err := vm.LoadRecords(map[string]interface{}{"payload": struct{}{}})
+	if err == nil {
+		t.Fatal("Expected error for unsupported referenced field, got nil")
+	}
+}
+
 func TestReset(t *testing.T) {
-	vm := NewVM([]byte{1, 2, 3}, nil)
+	vm := newTestVM([]byte{1, 2, 3}, "test")
 	vm.pc = 2
-	vm.globals["test"] = Value{Type: TYPE_STRING, String: "value"}
-	vm.dataStack = append(vm.dataStack, Value{Type: TYPE_INT8, Int8: 42})
+	vm.globals[0] = StringValue("value")
+	vm.defined[0] = true
+	vm.dataStack = append(vm.dataStack, Int8Value(42))
 
 	vm.Reset()
 
 	if vm.pc != 0 {
 		t.Errorf("Expected pc to be 0 after reset, got %d", vm.pc)
 	}
-	if len(vm.globals) != 0 {
-		t.Errorf("Expected empty globals after reset, got %d entries", len(vm.globals))
+	if _, exists := lookupGlobal(vm, "test"); exists {
+		t.Error("Expected no defined globals after reset")
 	}
 	if len(vm.dataStack) != 0 {
 		t.Errorf("Expected empty dataStack after reset, got %d entries", len(vm.dataStack))
@@ -182,26 +239,152 @@ func TestExecute_SimplePush(t *testing.T) {
 	bytecode := []byte{byte(PUSH), byte(TYPE_INT8), 1, 42}
 	vm := NewVM(bytecode, nil)
 
-	if err := vm.Execute(); err != nil {
-		t.Fatalf("Execute failed: %v", err)
+	if err := vm.run(); err != nil {
+		t.Fatalf("run failed: %v", err)
 	}
 
 	assertStackValue(t, vm, TYPE_INT8, func(v Value) bool {
-		return v.Int8 == 42
+		return v.Int64() == 42
 	})
 }
 
 func TestDataStack(t *testing.T) {
 	vm := NewVM([]byte{}, nil)
-	vm.dataStack = append(vm.dataStack, Value{Type: TYPE_INT8, Int8: 1})
-	vm.dataStack = append(vm.dataStack, Value{Type: TYPE_INT8, Int8: 2})
+	vm.dataStack = append(vm.dataStack, Int8Value(1))
+	vm.dataStack = append(vm.dataStack, Int8Value(2))
 
 	stack := vm.DataStack()
 
 	if len(stack) != 2 {
 		t.Errorf("Expected 2 values on stack, got %d", len(stack))
 	}
-	if stack[0].Int8 != 1 || stack[1].Int8 != 2 {
+	if stack[0].Int64() != 1 || stack[1].Int64() != 2 {
 		t.Error("Stack values don't match expected values")
 	}
 }
+
+func TestProgram_NewVM(t *testing.T) {
+	bytecode := []byte{byte(PUSH), byte(TYPE_BOOL), 1, 1}
+	program := NewProgram(bytecode, nil, 0, nil)
+	if err := program.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	vm1 := program.NewVM()
+	vm2 := program.NewVM()
+	if vm1 == vm2 {
+		t.Fatal("NewVM should return distinct execution states")
+	}
+	if vm1.Program() != program || vm2.Program() != program {
+		t.Error("VMs should reference their program")
+	}
+
+	if err := vm1.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if len(vm1.dataStack) != 1 {
+		t.Errorf("Expected 1 value on vm1 stack, got %d", len(vm1.dataStack))
+	}
+	if len(vm2.dataStack) != 0 || vm2.pc != 0 {
+		t.Errorf("vm2 should be untouched, got pc=%d stack=%d", vm2.pc, len(vm2.dataStack))
+	}
+	if len(program.Bytecode()) != len(bytecode) {
+		t.Errorf("Expected bytecode length %d, got %d", len(bytecode), len(program.Bytecode()))
+	}
+}
+
+func TestExecute_ReusedVMDoesNotAllocate(t *testing.T) {
+	// field IN 'a','b',('c','d') : strings and nested arrays
+	bytecode := []byte{
+		byte(LOAD_GLOBAL), 0, 0,
+		byte(PUSH), byte(TYPE_ARRAY), 3,
+		byte(TYPE_STRING), 1, 'a',
+		byte(TYPE_STRING), 1, 'b',
+		byte(TYPE_ARRAY), 2,
+		byte(TYPE_STRING), 1, 'c',
+		byte(TYPE_STRING), 1, 'd',
+		byte(OP_IN),
+	}
+	program := NewProgram(bytecode, []string{"field"}, 2, nil)
+	if err := program.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	vm := program.NewVM()
+	records := map[string]interface{}{"field": "b"}
+
+	run := func() {
+		vm.Reset()
+		if err := vm.LoadRecords(records); err != nil {
+			t.Fatalf("LoadRecords failed: %v", err)
+		}
+		if err := vm.Execute(); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+	}
+
+	run()
+	assertStackValue(t, vm, TYPE_BOOL, func(v Value) bool { return v.Bool() })
+
+	if allocs := testing.AllocsPerRun(100, run); allocs != 0 {
+		t.Errorf("reused VM allocates %.1f times per run, want 0", allocs)
+	}
+}
+
+func TestExecute_UnknownOpcode(t *testing.T) {
+	vm := NewVM([]byte{0xFF}, nil)
+	if err := vm.run(); err == nil {
+		t.Fatal("Expected error for unknown opcode, got nil")
+	}
+}
+
+// ============================================================================
+// Apply Tests
+// ============================================================================
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       OpCode
+		operands []Value
+		expected bool
+	}{
+		{"eq across widths", OP_EQ, []Value{Int64Value(7), Uint8Value(7)}, true},
+		{"gt", OP_GT, []Value{Int8Value(3), Float64Value(2.5)}, true},
+		{"in", OP_IN, []Value{StringValue("b"), ArrayValue([]Value{StringValue("a"), StringValue("b")})}, true},
+		{"xor", OP_XOR, []Value{BoolValue(true), BoolValue(true)}, false},
+		{"not", OP_NOT, []Value{BoolValue(false)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Apply(tt.op, tt.operands...)
+			if err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+			if result.Type != TYPE_BOOL || result.Bool() != tt.expected {
+				t.Errorf("Apply(%v) = %v, want %v", tt.op, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestApply_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       OpCode
+		operands []Value
+	}{
+		{"not an operator", PUSH, []Value{BoolValue(true)}},
+		{"missing operand", OP_EQ, []Value{BoolValue(true)}},
+		{"too many operands", OP_NOT, []Value{BoolValue(true), BoolValue(true)}},
+		{"type error", OP_CONTAINS, []Value{Int8Value(1), StringValue("a")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Apply(tt.op, tt.operands...); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
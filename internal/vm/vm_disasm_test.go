@@ -0,0 +1,112 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// Disassembler Tests
+// ============================================================================
+
+func TestDisassemble(t *testing.T) {
+	program := NewProgram(concat(
+		SerializeLoadGlobal(0), mustPush(t, "active"), SerializeOperator(OP_EQ),
+		SerializeJump(JUMP_IF_FALSE_OR_POP, 13),
+		SerializeLoadGlobal(1), mustPush(t, []interface{}{"a", 2}), SerializeOperator(OP_IN),
+	), []string{"status", "tags"}, 0, nil)
+
+	listing, err := program.Disassemble()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `0000  LOAD_GLOBAL "status"
+0003  PUSH STRING "active"
+0012  OP_EQ
+0013  JUMP_IF_FALSE_OR_POP +13  ; -> 0031
+0018  LOAD_GLOBAL "tags"
+0021  PUSH ARRAY [STRING "a", INT8 2]
+0030  OP_IN
+`
+	if listing != expected {
+		t.Errorf("unexpected listing:\n%s\nwant:\n%s", listing, expected)
+	}
+}
+
+func TestDisassemble_Operands(t *testing.T) {
+	store, err := SerializeStoreGlobal(0, "x\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		bytecode []byte
+		expected string
+	}{
+		{"bool", mustPush(t, true), "PUSH BOOL true"},
+		{"negative int", mustPush(t, -300), "PUSH INT16 -300"},
+		{"float", mustPush(t, 1.5), "PUSH FLOAT64 1.5"},
+		{"quoted string", mustPush(t, `say "hi"`), `PUSH STRING "say \"hi\""`},
+		{"store global", store, `STORE_GLOBAL "field" STRING "x\n"`},
+		{"call native", SerializeCallNative(0, 2), "CALL_NATIVE 0 2"},
+		{"named native", SerializeCallNative(1, 1), "CALL_NATIVE 1 1  ; LOWER"},
+		{"backward jump", SerializeJump(JUMP, -5), "JUMP -5  ; -> 0000"},
+		{"not", SerializeOperator(OP_NOT), "OP_NOT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := NewProgram(tt.bytecode, []string{"field"}, 0, []Function{{}, {Name: "LOWER"}})
+			listing, err := program.Disassemble()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := "0000  " + tt.expected + "\n"; listing != expected {
+				t.Errorf("got %q, want %q", listing, expected)
+			}
+		})
+	}
+}
+
+func TestDisassemble_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		bytecode []byte
+		listing  string
+		errMsg   string
+	}{
+		{"unknown opcode", []byte{byte(OP_NOT), 0xEE}, "0000  OP_NOT\n", "offset 0001"},
+		{"truncated push", concat(mustPush(t, true), []byte{byte(PUSH), byte(TYPE_STRING), 5, 'a'}), "0000  PUSH BOOL true\n", "offset 0004"},
+		{"bad slot", SerializeLoadGlobal(7), "", "offset 0000"},
+		{"jump out of bounds", SerializeJump(JUMP, 100), "", "out of bounds"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := NewProgram(tt.bytecode, []string{"field"}, 0, nil)
+			listing, err := program.Disassemble()
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+			if listing != tt.listing {
+				t.Errorf("expected partial listing %q, got %q", tt.listing, listing)
+			}
+		})
+	}
+}
+
+func TestOpCode_String(t *testing.T) {
+	if JUMP_IF_TRUE_OR_POP.String() != "JUMP_IF_TRUE_OR_POP" {
+		t.Errorf("got %s", JUMP_IF_TRUE_OR_POP)
+	}
+	if OpCode(0xEE).String() != "OPCODE(0xee)" {
+		t.Errorf("got %s", OpCode(0xEE))
+	}
+	if TYPE_UINT16.String() != "UINT16" || Type(0xEE).String() != "TYPE(0xee)" {
+		t.Errorf("got %s, %s", TYPE_UINT16, Type(0xEE))
+	}
+}
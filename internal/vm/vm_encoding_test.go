@@ -0,0 +1,212 @@
+package vm
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// Binary Format Tests
+// ============================================================================
+
+func testProgram(t *testing.T) *Program {
+	t.Helper()
+	bytecode := concat(
+		SerializeLoadGlobal(0), mustPush(t, "active"), SerializeOperator(OP_EQ),
+		SerializeJump(JUMP_IF_FALSE_OR_POP, 8),
+		SerializeLoadGlobal(1), mustPush(t, 18), SerializeOperator(OP_GT),
+	)
+	p := NewProgram(bytecode, []string{"status", "age"}, 2, nil)
+	if err := p.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	return p
+}
+
+// resign recomputes the checksum after a test modified the body
+func resign(data []byte) []byte {
+	body := data[:len(data)-4]
+	return binary.BigEndian.AppendUint32(append([]byte{}, body...), crc32.ChecksumIEEE(body))
+}
+
+func TestMarshalBinary_RoundTrip(t *testing.T) {
+	p := testProgram(t)
+	data, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+
+	loaded, err := UnmarshalProgram(data, nil)
+	if err != nil {
+		t.Fatalf("UnmarshalProgram failed: %v", err)
+	}
+	assertBytecodeEqual(t, loaded.Bytecode(), p.Bytecode())
+	if strings.Join(loaded.Fields(), ",") != "status,age" {
+		t.Errorf("Expected fields [status age], got %v", loaded.Fields())
+	}
+	if loaded.MaxStack() != p.MaxStack() || !loaded.Verified() {
+		t.Errorf("Expected verified program with max stack %d, got %d", p.MaxStack(), loaded.MaxStack())
+	}
+
+	// the loaded program does not alias the input
+	for i := range data {
+		data[i] = 0
+	}
+	vm := loaded.NewVM()
+	if err := vm.LoadRecords(map[string]interface{}{"status": "active", "age": 30}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	assertStackValue(t, vm, TYPE_BOOL, func(v Value) bool { return v.Bool() })
+}
+
+func TestMarshalBinary_Header(t *testing.T) {
+	data, err := testProgram(t).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data[:4]) != "SEL\x00" {
+		t.Errorf("Expected magic SEL\\x00, got %q", data[:4])
+	}
+	if v := binary.BigEndian.Uint16(data[4:]); v != FormatVersion {
+		t.Errorf("Expected format version %d, got %d", FormatVersion, v)
+	}
+	if v := binary.BigEndian.Uint16(data[6:]); v != OpcodeVersion {
+		t.Errorf("Expected opcode version %d, got %d", OpcodeVersion, v)
+	}
+}
+
+// Helper pour créer un registre avec une fonction IS_EVEN(n)
+func testFunctions(t testing.TB, maxArgs int) *Functions {
+	t.Helper()
+	funcs := NewFunctions()
+	err := funcs.Register(Function{Name: "IS_EVEN", MinArgs: 1, MaxArgs: maxArgs, Func: func(args []Value) (Value, error) {
+		return BoolValue(args[0].Int64()%2 == 0), nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return funcs
+}
+
+func TestMarshalBinary_Natives(t *testing.T) {
+	funcs := testFunctions(t, 1)
+	isEven, _ := funcs.Lookup("IS_EVEN")
+	p := NewProgram(concat(
+		SerializeLoadGlobal(0), SerializeCallNative(0, 1), mustPush(t, true), SerializeOperator(OP_EQ),
+	), []string{"n"}, 2, []Function{isEven})
+	if err := p.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+
+	loaded, err := UnmarshalProgram(data, funcs)
+	if err != nil {
+		t.Fatalf("UnmarshalProgram failed: %v", err)
+	}
+	vm := loaded.NewVM()
+	if err := vm.LoadRecords(map[string]interface{}{"n": 4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	assertStackValue(t, vm, TYPE_BOOL, func(v Value) bool { return v.Bool() })
+
+	if _, err := UnmarshalProgram(data, nil); err == nil || !strings.Contains(err.Error(), `unknown native function "IS_EVEN"`) {
+		t.Errorf("Expected unknown function error without registry, got %v", err)
+	}
+	// same name, incompatible arity
+	strict := NewFunctions()
+	strict.Register(Function{Name: "IS_EVEN", MinArgs: 2, MaxArgs: 2, Func: isEven.Func})
+	if _, err := UnmarshalProgram(data, strict); err == nil || !strings.Contains(err.Error(), "IS_EVEN expects 2 arguments, got 1") {
+		t.Errorf("Expected arity error, got %v", err)
+	}
+
+	unnamed := NewProgram(mustPush(t, true), nil, 1, anonymous([]NativeFunc{isEven.Func}))
+	if _, err := unnamed.MarshalBinary(); err == nil {
+		t.Error("Expected error encoding a native function without name, got nil")
+	}
+}
+
+func TestUnmarshalProgram_Errors(t *testing.T) {
+	valid, err := testProgram(t).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	modify := func(f func([]byte) []byte) []byte {
+		return f(append([]byte{}, valid...))
+	}
+
+	tests := []struct {
+		name        string
+		data        []byte
+		errContains string
+	}{
+		{"empty", nil, "not a compiled SEL expression"},
+		{"bad magic", modify(func(b []byte) []byte { b[0] = 'X'; return b }), "not a compiled SEL expression"},
+		{"corrupted byte", modify(func(b []byte) []byte { b[12] ^= 0xFF; return b }), "checksum mismatch"},
+		{"truncated", valid[:len(valid)-1], "checksum mismatch"},
+		{"format version", modify(func(b []byte) []byte { b[5] = 9; return resign(b) }), "unsupported format version"},
+		{"format version 0", modify(func(b []byte) []byte { b[5] = 0; return resign(b) }), "unsupported format version 0"},
+		{"opcode version", modify(func(b []byte) []byte { b[7] = 9; return resign(b) }), "opcode version"},
+		{"opcode version 0", modify(func(b []byte) []byte { b[7] = 0; return resign(b) }), "opcode version 0"},
+		{"opcode version 2", modify(func(b []byte) []byte { b[7] = 2; return resign(b) }), "compiled with opcode version 2, this VM runs version 1"},
+		{"trailing bytes", resign(append(append([]byte{}, valid[:len(valid)-4]...), 0, 0, 0, 0, 0)), "after bytecode"},
+		{
+			"body too short",
+			resign(append(append([]byte{}, valid[:9]...), 0, 0, 0, 0)),
+			"truncated",
+		},
+		{
+			"invalid bytecode",
+			// the last byte of the bytecode comes before the nested count
+			modify(func(b []byte) []byte { b[len(b)-6] = byte(OP_AND); return resign(b) }),
+			"invalid bytecode",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := UnmarshalProgram(tt.data, nil)
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Expected error containing %q, got: %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func FuzzUnmarshalProgram(f *testing.F) {
+	p := NewProgram(concat(SerializeLoadGlobal(0), mustPush(f, 1), SerializeOperator(OP_EQ)), []string{"a"}, 2, nil)
+	valid, err := p.MarshalBinary()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(valid)
+	f.Add([]byte("SEL\x00"))
+
+	funcs := testFunctions(f, -1)
+	isEven, _ := funcs.Lookup("IS_EVEN")
+	p = NewProgram(concat(mustPush(f, 2), SerializeCallNative(0, 1), mustPush(f, true), SerializeOperator(OP_EQ)), nil, 2, []Function{isEven})
+	if valid, err = p.MarshalBinary(); err != nil {
+		f.Fatal(err)
+	}
+	f.Add(valid)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// must not panic, and a loaded program is always verified
+		if p, err := UnmarshalProgram(data, funcs); err == nil && !p.Verified() {
+			t.Fatal("loaded program is not verified")
+		}
+	})
+}
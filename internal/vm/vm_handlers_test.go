@@ -1,6 +1,7 @@
 package vm
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -18,7 +19,7 @@ func TestPushHandler(t *testing.T) {
 			name:     "push int8",
 			bytecode: []byte{byte(PUSH), byte(TYPE_INT8), 1, 42},
 			check: func(t *testing.T, v Value) {
-				if v.Type != TYPE_INT8 || v.Int8 != 42 {
+				if v.Type != TYPE_INT8 || v.Int64() != 42 {
 					t.Errorf("Expected int8(42), got %+v", v)
 				}
 			},
@@ -27,7 +28,7 @@ func TestPushHandler(t *testing.T) {
 			name:     "push string",
 			bytecode: []byte{byte(PUSH), byte(TYPE_STRING), 5, 'h', 'e', 'l', 'l', 'o'},
 			check: func(t *testing.T, v Value) {
-				if v.Type != TYPE_STRING || v.String != "hello" {
+				if v.Type != TYPE_STRING || v.String() != "hello" {
 					t.Errorf("Expected string(hello), got %+v", v)
 				}
 			},
@@ -36,7 +37,7 @@ func TestPushHandler(t *testing.T) {
 			name:     "push bool true",
 			bytecode: []byte{byte(PUSH), byte(TYPE_BOOL), 1, 1},
 			check: func(t *testing.T, v Value) {
-				if v.Type != TYPE_BOOL || v.Bool != true {
+				if v.Type != TYPE_BOOL || v.Bool() != true {
 					t.Errorf("Expected bool(true), got %+v", v)
 				}
 			},
@@ -45,7 +46,7 @@ func TestPushHandler(t *testing.T) {
 			name:     "push bool false",
 			bytecode: []byte{byte(PUSH), byte(TYPE_BOOL), 1, 0},
 			check: func(t *testing.T, v Value) {
-				if v.Type != TYPE_BOOL || v.Bool != false {
+				if v.Type != TYPE_BOOL || v.Bool() != false {
 					t.Errorf("Expected bool(false), got %+v", v)
 				}
 			},
@@ -55,8 +56,8 @@ func TestPushHandler(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			vm := NewVM(tt.bytecode, nil)
-			if err := vm.Execute(); err != nil {
-				t.Fatalf("Execute failed: %v", err)
+			if err := vm.run(); err != nil {
+				t.Fatalf("run failed: %v", err)
 			}
 			if len(vm.dataStack) != 1 {
 				t.Fatalf("Expected 1 value on stack, got %d", len(vm.dataStack))
@@ -71,13 +72,12 @@ func TestPushHandler(t *testing.T) {
 // ============================================================================
 
 func TestPopHandler(t *testing.T) {
-	bytecode := []byte{
-		byte(PUSH), byte(TYPE_INT8), 1, 42,
-		byte(POP),
-	}
-	vm := NewVM(bytecode, nil)
-	if err := vm.Execute(); err != nil {
-		t.Fatalf("Execute failed: %v", err)
+	vm := assembleVM(t, `
+		PUSH INT8 42
+		POP
+	`)
+	if err := vm.run(); err != nil {
+		t.Fatalf("run failed: %v", err)
 	}
 	if len(vm.dataStack) != 0 {
 		t.Errorf("Expected empty stack after POP, got %d values", len(vm.dataStack))
@@ -85,8 +85,8 @@ func TestPopHandler(t *testing.T) {
 }
 
 func TestPopHandler_Underflow(t *testing.T) {
-	vm := NewVM([]byte{byte(POP)}, nil)
-	if err := vm.Execute(); err == nil {
+	vm := assembleVM(t, "POP")
+	if err := vm.run(); err == nil {
 		t.Fatal("Expected error for stack underflow, got nil")
 	}
 }
@@ -96,48 +96,65 @@ func TestPopHandler_Underflow(t *testing.T) {
 // ============================================================================
 
 func TestStoreGlobalHandler(t *testing.T) {
-	bytecode := []byte{
-		byte(STORE_GLOBAL), 5, 'm', 'y', 'v', 'a', 'r',
-		byte(TYPE_STRING), 5, 'h', 'e', 'l', 'l', 'o',
-	}
-	vm := NewVM(bytecode, nil)
-	if err := vm.Execute(); err != nil {
-		t.Fatalf("Execute failed: %v", err)
+	vm := assembleVM(t, `STORE_GLOBAL "myvar" STRING "hello"`)
+	if err := vm.run(); err != nil {
+		t.Fatalf("run failed: %v", err)
 	}
 
-	val, exists := vm.globals["myvar"]
+	val, exists := lookupGlobal(vm, "myvar")
 	if !exists {
 		t.Fatal("Expected 'myvar' to be in globals")
 	}
-	if val.Type != TYPE_STRING || val.String != "hello" {
+	if val.Type != TYPE_STRING || val.String() != "hello" {
 		t.Errorf("Expected string(hello), got %+v", val)
 	}
 }
 
 func TestLoadGlobalHandler(t *testing.T) {
-	vm := NewVM([]byte{}, nil)
-	vm.globals["myvar"] = Value{Type: TYPE_INT8, Int8: 42}
-
-	bytecode := []byte{byte(LOAD_GLOBAL), 5, 'm', 'y', 'v', 'a', 'r'}
-	vm.bytecode = bytecode
+	bytecode := []byte{byte(LOAD_GLOBAL), 0, 1}
+	vm := newTestVM(bytecode, "other", "myvar")
+	if err := vm.LoadRecords(map[string]interface{}{"myvar": 42}); err != nil {
+		t.Fatalf("LoadRecords failed: %v", err)
+	}
 
-	if err := vm.Execute(); err != nil {
-		t.Fatalf("Execute failed: %v", err)
+	if err := vm.run(); err != nil {
+		t.Fatalf("run failed: %v", err)
 	}
 
 	assertStackValue(t, vm, TYPE_INT8, func(v Value) bool {
-		return v.Int8 == 42
+		return v.Int64() == 42
 	})
 }
 
 func TestLoadGlobalHandler_Undefined(t *testing.T) {
-	bytecode := []byte{
-		byte(LOAD_GLOBAL), 9, 'u', 'n', 'd', 'e', 'f', 'i', 'n', 'e', 'd',
-	}
-	vm := NewVM(bytecode, nil)
-	if err := vm.Execute(); err == nil {
+	bytecode := []byte{byte(LOAD_GLOBAL), 0, 0}
+	vm := newTestVM(bytecode, "undefined")
+	err := vm.run()
+	if err == nil {
 		t.Fatal("Expected error for undefined variable, got nil")
 	}
+	if !strings.Contains(err.Error(), "undefined") {
+		t.Errorf("Expected error to name the field, got: %v", err)
+	}
+}
+
+func TestLoadGlobalHandler_BadSlot(t *testing.T) {
+	tests := []struct {
+		name     string
+		bytecode []byte
+	}{
+		{"slot out of bounds", []byte{byte(LOAD_GLOBAL), 0, 1}},
+		{"truncated slot", []byte{byte(LOAD_GLOBAL), 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := newTestVM(tt.bytecode, "field")
+			if err := vm.run(); err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+		})
+	}
 }
 
 // ============================================================================
@@ -146,21 +163,19 @@ func TestLoadGlobalHandler_Undefined(t *testing.T) {
 
 func TestCallNativeHandler(t *testing.T) {
 	addFunc := func(args []Value) (Value, error) {
-		return Value{Type: TYPE_INT8, Int8: args[0].Int8 + args[1].Int8}, nil
-	}
-
-	bytecode := []byte{
-		byte(PUSH), byte(TYPE_INT8), 1, 10,
-		byte(PUSH), byte(TYPE_INT8), 1, 20,
-		byte(CALL_NATIVE), 0, 2,
+		return Int8Value(int8(args[0].Int64() + args[1].Int64())), nil
 	}
 
-	vm := NewVM(bytecode, []NativeFunc{addFunc})
-	if err := vm.Execute(); err != nil {
-		t.Fatalf("Execute failed: %v", err)
+	vm := assembleVM(t, `
+		PUSH INT8 10
+		PUSH INT8 20
+		CALL_NATIVE 0 2   ; addFunc(10, 20)
+	`, addFunc)
+	if err := vm.run(); err != nil {
+		t.Fatalf("run failed: %v", err)
 	}
 
 	assertStackValue(t, vm, TYPE_INT8, func(v Value) bool {
-		return v.Int8 == 30
+		return v.Int64() == 30
 	})
 }
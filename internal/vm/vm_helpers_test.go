@@ -21,6 +21,31 @@ func assertBytecodeEqual(t *testing.T, got, expected []byte) {
 	}
 }
 
+// Helper pour créer une VM dont la table de champs est fields
+func newTestVM(bytecode []byte, fields ...string) *VM {
+	return NewProgram(bytecode, fields, 0, nil).NewVM()
+}
+
+// Helper pour assembler un programme de test et créer sa VM
+func assembleVM(t *testing.T, source string, nativeFuncs ...NativeFunc) *VM {
+	t.Helper()
+	program, err := Assemble(source, nativeFuncs)
+	if err != nil {
+		t.Fatalf("Assemble failed: %v", err)
+	}
+	return program.NewVM()
+}
+
+// Helper pour lire un global par nom de champ
+func lookupGlobal(vm *VM, name string) (Value, bool) {
+	for slot, field := range vm.program.fields {
+		if field == name && vm.defined[slot] {
+			return vm.globals[slot], true
+		}
+	}
+	return Value{}, false
+}
+
 // Helper pour vérifier une valeur sur la stack
 func assertStackValue(t *testing.T, vm *VM, expectedType Type, checker func(Value) bool) {
 	t.Helper()
@@ -0,0 +1,93 @@
+package vm
+
+import (
+	"testing"
+)
+
+// ============================================================================
+// Jump Opcodes Tests
+// ============================================================================
+
+func TestSerializeJump(t *testing.T) {
+	assertBytecodeEqual(t, SerializeJump(JUMP, 4), []byte{byte(JUMP), 0, 0, 0, 4})
+	assertBytecodeEqual(t, SerializeJump(JUMP_IF_FALSE, -9), []byte{byte(JUMP_IF_FALSE), 0xFF, 0xFF, 0xFF, 0xF7})
+}
+
+func TestJumpHandlers(t *testing.T) {
+	pushTrue := []byte{byte(PUSH), byte(TYPE_BOOL), 1, 1}
+	pushFalse := []byte{byte(PUSH), byte(TYPE_BOOL), 1, 0}
+	pushInt := []byte{byte(PUSH), byte(TYPE_INT8), 1, 7}
+
+	concat := func(parts ...[]byte) []byte {
+		var bytecode []byte
+		for _, p := range parts {
+			bytecode = append(bytecode, p...)
+		}
+		return bytecode
+	}
+
+	tests := []struct {
+		name      string
+		bytecode  []byte
+		stackLen  int
+		topIsInt  bool // the skipped PUSH int8 ran
+		topIsTrue bool
+	}{
+		{"jump skips", concat(pushTrue, SerializeJump(JUMP, 4), pushInt), 1, false, true},
+		{"jump zero", concat(pushTrue, SerializeJump(JUMP, 0), pushInt), 2, true, false},
+		{"if false: taken pops", concat(pushFalse, SerializeJump(JUMP_IF_FALSE, 4), pushInt), 0, false, false},
+		{"if false: not taken pops", concat(pushTrue, SerializeJump(JUMP_IF_FALSE, 4), pushInt), 1, true, false},
+		{"if true: taken pops", concat(pushTrue, SerializeJump(JUMP_IF_TRUE, 4), pushInt), 0, false, false},
+		{"if true: not taken pops", concat(pushFalse, SerializeJump(JUMP_IF_TRUE, 4), pushInt), 1, true, false},
+		{"if false or pop: taken keeps", concat(pushFalse, SerializeJump(JUMP_IF_FALSE_OR_POP, 4), pushInt), 1, false, false},
+		{"if false or pop: not taken pops", concat(pushTrue, SerializeJump(JUMP_IF_FALSE_OR_POP, 4), pushInt), 1, true, false},
+		{"if true or pop: taken keeps", concat(pushTrue, SerializeJump(JUMP_IF_TRUE_OR_POP, 4), pushInt), 1, false, true},
+		{"if true or pop: not taken pops", concat(pushFalse, SerializeJump(JUMP_IF_TRUE_OR_POP, 4), pushInt), 1, true, false},
+		// JUMP to the trailing jump, which goes back to PUSH true, then exit
+		{"backward jump", concat(SerializeJump(JUMP, 9), pushTrue, SerializeJump(JUMP, 5), SerializeJump(JUMP, -14)), 1, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := NewVM(tt.bytecode, nil)
+			if err := vm.run(); err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+			if len(vm.dataStack) != tt.stackLen {
+				t.Fatalf("Expected %d values on stack, got %d", tt.stackLen, len(vm.dataStack))
+			}
+			if tt.stackLen == 0 {
+				return
+			}
+			top := vm.dataStack[len(vm.dataStack)-1]
+			if tt.topIsInt && (top.Type != TYPE_INT8 || top.Int64() != 7) {
+				t.Errorf("Expected int8(7) on top, got %+v", top)
+			}
+			if tt.topIsTrue && (top.Type != TYPE_BOOL || !top.Bool()) {
+				t.Errorf("Expected bool(true) on top, got %+v", top)
+			}
+		})
+	}
+}
+
+func TestJumpHandlers_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		bytecode []byte
+	}{
+		{"truncated offset", []byte{byte(JUMP), 0, 0}},
+		{"target past end", SerializeJump(JUMP, 10)},
+		{"target before start", SerializeJump(JUMP, -10)},
+		{"condition not bool", append([]byte{byte(PUSH), byte(TYPE_INT8), 1, 1}, SerializeJump(JUMP_IF_TRUE, 0)...)},
+		{"empty stack", SerializeJump(JUMP_IF_FALSE_OR_POP, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := NewVM(tt.bytecode, nil)
+			if err := vm.run(); err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+		})
+	}
+}
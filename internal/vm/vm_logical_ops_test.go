@@ -37,10 +37,10 @@ func TestLogicalOperators(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			left := Value{Type: TYPE_BOOL, Bool: tt.left}
-			right := Value{Type: TYPE_BOOL, Bool: tt.right}
+			left := BoolValue(tt.left)
+			right := BoolValue(tt.right)
 			testBinaryHandler(t, tt.handler, left, right, TYPE_BOOL, func(v Value) bool {
-				return v.Bool == tt.expected
+				return v.Bool() == tt.expected
 			})
 		})
 	}
@@ -58,14 +58,14 @@ func TestNotHandler(t *testing.T) {
 	for _, tt := range tests {
 		t.Run("", func(t *testing.T) {
 			vm := NewVM([]byte{}, nil)
-			vm.push(Value{Type: TYPE_BOOL, Bool: tt.input})
+			vm.push(BoolValue(tt.input))
 
 			if err := vm.notHandler(); err != nil {
 				t.Fatalf("notHandler failed: %v", err)
 			}
 
 			assertStackValue(t, vm, TYPE_BOOL, func(v Value) bool {
-				return v.Bool == tt.expected
+				return v.Bool() == tt.expected
 			})
 		})
 	}
@@ -73,7 +73,7 @@ func TestNotHandler(t *testing.T) {
 
 func TestNotHandler_TypeError(t *testing.T) {
 	vm := NewVM([]byte{}, nil)
-	vm.push(Value{Type: TYPE_INT8, Int8: 42})
+	vm.push(Int8Value(42))
 
 	if err := vm.notHandler(); err == nil {
 		t.Fatal("Expected error for NOT on non-boolean, got nil")
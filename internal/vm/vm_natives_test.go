@@ -0,0 +1,115 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// Native Function Registry Tests
+// ============================================================================
+
+func TestFunctions_Register(t *testing.T) {
+	noop := func(args []Value) (Value, error) { return BoolValue(true), nil }
+	funcs := NewFunctions()
+	if err := funcs.Register(Function{Name: "LOWER", MinArgs: 1, MaxArgs: 1, Func: noop}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		fn          Function
+		errContains string
+	}{
+		{"duplicate", Function{Name: "LOWER", MinArgs: 1, MaxArgs: 1, Func: noop}, "already registered"},
+		{"empty name", Function{Func: noop}, "invalid function name"},
+		{"invalid name", Function{Name: "TO-LOWER", Func: noop}, "invalid function name"},
+		{"leading digit", Function{Name: "1ST", Func: noop}, "invalid function name"},
+		{"nil func", Function{Name: "NOOP"}, "no implementation"},
+		{"negative min", Function{Name: "NOOP", MinArgs: -1, Func: noop}, "invalid arity"},
+		{"max below min", Function{Name: "NOOP", MinArgs: 2, MaxArgs: 1, Func: noop}, "invalid arity"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := funcs.Register(tt.fn)
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+
+	if err := funcs.Register(Function{Name: "_concat2", MinArgs: 0, MaxArgs: -1, Func: noop}); err != nil {
+		t.Errorf("Register failed: %v", err)
+	}
+	if names := strings.Join(funcs.Names(), ","); names != "LOWER,_concat2" {
+		t.Errorf("Expected names [LOWER _concat2], got %v", names)
+	}
+	if _, ok := funcs.Lookup("lower"); ok {
+		t.Error("Lookup should be case sensitive")
+	}
+	var none *Functions
+	if _, ok := none.Lookup("LOWER"); ok || none.Names() != nil {
+		t.Error("nil registry should be empty")
+	}
+}
+
+func TestFunction_CheckArgs(t *testing.T) {
+	tests := []struct {
+		fn       Function
+		argc     int
+		expected string
+	}{
+		{Function{Name: "F", MinArgs: 1, MaxArgs: 1}, 1, ""},
+		{Function{Name: "F", MinArgs: 1, MaxArgs: 1}, 2, "F expects 1 arguments, got 2"},
+		{Function{Name: "F", MinArgs: 2, MaxArgs: 3}, 1, "F expects 2 to 3 arguments, got 1"},
+		{Function{Name: "F", MinArgs: 2, MaxArgs: 3}, 3, ""},
+		{Function{Name: "F", MinArgs: 1, MaxArgs: -1}, 0, "F expects at least 1 arguments, got 0"},
+		{Function{Name: "F", MinArgs: 1, MaxArgs: -1}, 10, ""},
+	}
+
+	for _, tt := range tests {
+		err := tt.fn.CheckArgs(tt.argc)
+		if tt.expected == "" && err != nil {
+			t.Errorf("CheckArgs(%d) on %s: unexpected error %v", tt.argc, tt.fn.Arity(), err)
+		}
+		if tt.expected != "" && (err == nil || err.Error() != tt.expected) {
+			t.Errorf("CheckArgs(%d) = %v, want %q", tt.argc, err, tt.expected)
+		}
+	}
+}
+
+func TestVerify_NativeArity(t *testing.T) {
+	funcs := testFunctions(t, 1)
+	isEven, _ := funcs.Lookup("IS_EVEN")
+	p := NewProgram(concat(
+		mustPush(t, 1), mustPush(t, 2), SerializeCallNative(0, 2), mustPush(t, true), SerializeOperator(OP_EQ),
+	), nil, 3, []Function{isEven})
+	err := p.Verify()
+	if err == nil || !strings.Contains(err.Error(), "IS_EVEN expects 1 arguments, got 2") {
+		t.Errorf("Expected arity error, got %v", err)
+	}
+}
+
+func TestValue_Interface(t *testing.T) {
+	tests := []struct {
+		value    Value
+		expected interface{}
+	}{
+		{BoolValue(true), true},
+		{Int8Value(-3), int8(-3)},
+		{Uint32Value(7), uint32(7)},
+		{Float64Value(1.5), 1.5},
+		{StringValue("x"), "x"},
+	}
+	for _, tt := range tests {
+		if got := tt.value.Interface(); got != tt.expected {
+			t.Errorf("Interface() of %v = %#v, want %#v", tt.value.Type, got, tt.expected)
+		}
+	}
+
+	arr := ArrayValue([]Value{Int8Value(1), StringValue("a")}).Interface().([]interface{})
+	if len(arr) != 2 || arr[0] != int8(1) || arr[1] != "a" {
+		t.Errorf("Interface() of ARRAY = %#v", arr)
+	}
+}
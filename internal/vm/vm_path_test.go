@@ -0,0 +1,330 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// Path & Object Tests
+// ============================================================================
+
+func TestLookup(t *testing.T) {
+	records := map[string]interface{}{
+		"status": "active",
+		"caller": map[string]interface{}{
+			"name":       "alice",
+			"department": map[string]interface{}{"name": "IT", "floor": 3},
+			"manager":    nil,
+			"site.code":  "PAR",
+		},
+		"flat.key": "flat",
+		"count":    1,
+		"deleted":  nil,
+	}
+
+	tests := []struct {
+		key      string
+		expected interface{}
+		exists   bool
+	}{
+		{"status", "active", true},
+		{"caller.name", "alice", true},
+		{"caller.department.name", "IT", true},
+		{"caller.department.floor", 3, true},
+		{"flat.key", "flat", true},
+		{"caller.site.code", "PAR", true},
+		{"missing", nil, false},
+		{"missing.name", nil, false},
+		{"caller.missing", nil, false},
+		{"caller.department.missing", nil, false},
+		{"caller.manager.name", nil, false}, // nil en chemin: champ non défini
+		{"caller.manager", nil, false},      // nil à la fin
+		{"deleted", nil, false},             // nil au premier niveau
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			val, exists, err := Lookup(records, tt.key)
+			if err != nil {
+				t.Fatalf("Lookup failed: %v", err)
+			}
+			if exists != tt.exists || val != tt.expected {
+				t.Errorf("got %v, %v, want %v, %v", val, exists, tt.expected, tt.exists)
+			}
+		})
+	}
+
+	_, _, err := Lookup(records, "caller.name.first")
+	if err == nil || !strings.Contains(err.Error(), "caller.name is string, not an object") {
+		t.Errorf("Expected error going through a string, got %v", err)
+	}
+	_, _, err = Lookup(records, "count.value")
+	if err == nil || !strings.Contains(err.Error(), "count is int, not an object") {
+		t.Errorf("Expected error going through an int, got %v", err)
+	}
+}
+
+func TestObjectValue(t *testing.T) {
+	obj := map[string]interface{}{"b": 2, "a": "x", "c": []interface{}{true}}
+	v, err := ToValue(obj)
+	if err != nil {
+		t.Fatalf("ToValue failed: %v", err)
+	}
+	if v.Type != TYPE_OBJECT || len(v.Object()) != 3 {
+		t.Fatalf("got %v with %d members", v.Type, len(v.Object()))
+	}
+	if v.String() != "{a:x b:2 c:[true]}" {
+		t.Errorf("got %s", v.String())
+	}
+	if back, ok := v.Interface().(map[string]interface{}); !ok || back["a"] != "x" {
+		t.Errorf("Interface() = %#v", v.Interface())
+	}
+	if StringValue("x").Object() != nil {
+		t.Error("Object() of a string should be nil")
+	}
+	if _, err := v.Compare(v); err == nil || !strings.Contains(err.Error(), "OBJECT does not support comparison") {
+		t.Errorf("Expected comparison error, got %v", err)
+	}
+	if _, err := serializeValue(obj); err == nil {
+		t.Error("Expected error serializing an object")
+	}
+}
+
+func TestLoadRecords_Paths(t *testing.T) {
+	vm := assembleVM(t, `
+		LOAD_GLOBAL "caller.department.name"
+		PUSH STRING "IT"
+		OP_EQ
+	`)
+	if err := vm.Program().Verify(); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	caller := map[string]interface{}{"department": map[string]interface{}{"name": "IT"}}
+	if err := vm.LoadRecords(map[string]interface{}{"caller": caller}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	assertStackValue(t, vm, TYPE_BOOL, func(v Value) bool { return v.Bool() })
+
+	// clé intermédiaire absente: le champ n'est pas défini
+	vm.Reset()
+	if err := vm.LoadRecords(map[string]interface{}{"caller": map[string]interface{}{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Execute(); err == nil || !strings.Contains(err.Error(), "undefined global variable: caller.department.name") {
+		t.Errorf("Expected undefined field error, got %v", err)
+	}
+
+	// chemin impossible: l'erreur est levée quand le champ est lu
+	vm.Reset()
+	if err := vm.LoadRecords(map[string]interface{}{"caller": map[string]interface{}{"department": "IT"}}); err != nil {
+		t.Fatalf("LoadRecords failed: %v", err)
+	}
+	if err := vm.Execute(); err == nil || !strings.Contains(err.Error(), "field caller.department.name: caller.department is string, not an object") {
+		t.Errorf("Expected path error, got %v", err)
+	}
+
+	// Reset efface l'erreur du chargement précédent
+	vm.Reset()
+	if err := vm.LoadRecords(map[string]interface{}{"caller": caller}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+}
+
+func TestLookup_Indexes(t *testing.T) {
+	records := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"sku": "ABC", "tags": []interface{}{"a", "b"}},
+			map[string]interface{}{"sku": "DEF", "tags": []interface{}{"c"}},
+			map[string]interface{}{"status": "done"},
+			nil,
+		},
+		"matrix": []interface{}{[]interface{}{1, 2}, []interface{}{3}},
+		"empty":  []interface{}{},
+	}
+
+	tests := []struct {
+		key      string
+		expected string // fmt de la valeur, "undefined" si absente
+	}{
+		{"items[0].sku", "ABC"},
+		{"items[1].tags[0]", "c"},
+		{"items[-2].status", "done"},
+		{"items[-1]", "undefined"},
+		{"items[-1].sku", "undefined"},
+		{"items[2].sku", "undefined"},
+		{"items[*].sku", "[ABC DEF]"},
+		{"items[*].tags[0]", "[a c]"},
+		{"items[*].tags[1]", "[b]"},
+		{"items[*].tags[*]", "[a b c]"},
+		{"matrix[*][*]", "[1 2 3]"},
+		{"matrix[*][1]", "[2]"},
+		{"empty[*]", "[]"},
+		{"missing[0]", "undefined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			val, exists, err := Lookup(records, tt.key)
+			if err != nil {
+				t.Fatalf("Lookup failed: %v", err)
+			}
+			got := "undefined"
+			if exists {
+				got = fmt.Sprint(val)
+			}
+			if got != tt.expected {
+				t.Errorf("got %s, want %s", got, tt.expected)
+			}
+		})
+	}
+
+	errors := []struct {
+		key         string
+		errContains string
+	}{
+		{"items[4].sku", "items[4]: index 4 out of range, 4 elements"},
+		{"items[-5]", "items[-5]: index -5 out of range, 4 elements"},
+		{"items[0].tags[2]", "items[0].tags[2]: index 2 out of range, 2 elements"},
+		{"items[0].sku[0]", "items[0].sku is string, not an array"},
+		{"items[0].sku[*]", "items[0].sku is string, not an array"},
+		{"items.sku", "items is []interface {}, not an object"},
+		{"matrix[*].x", "matrix[*] is []interface {}, not an object"},
+	}
+	for _, tt := range errors {
+		t.Run(tt.key, func(t *testing.T) {
+			_, _, err := Lookup(records, tt.key)
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestCheckPath(t *testing.T) {
+	for _, path := range []string{"a", "a.b", "items[0].sku", "items[-1]", "items[*].tags[*]", "m[0][1]"} {
+		if err := CheckPath(path); err != nil {
+			t.Errorf("CheckPath(%q) = %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		path        string
+		errContains string
+	}{
+		{"a..b", "empty segment"},
+		{".a", "empty segment"},
+		{"a.", "empty segment"},
+		{"[0]", "empty segment"},
+		{"a.[0]", "empty segment"},
+		{"a[]", "invalid index []"},
+		{"a[x]", "invalid index [x]"},
+		{"a[+1]", "invalid index [+1]"},
+		{"a[1", "unclosed '['"},
+		{"a[0]b", "expected '.' or '[' after a[0]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if err := CheckPath(tt.path); err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestMatchAny(t *testing.T) {
+	skus := values(StringValue("ABC"), StringValue("DEF"))
+	tests := []struct {
+		name     string
+		compare  OpCode
+		array    Value
+		right    Value
+		expected bool
+	}{
+		{"eq match", OP_EQ, skus, StringValue("DEF"), true},
+		{"eq no match", OP_EQ, skus, StringValue("XYZ"), false},
+		{"gt", OP_GT, values(Int8Value(1), Float64Value(9.5)), Int8Value(9), true},
+		{"startswith", OP_STARTSWITH, skus, StringValue("AB"), true},
+		{"in", OP_IN, skus, values(StringValue("XYZ"), StringValue("ABC")), true},
+		{"empty", OP_EQ, values(), StringValue("ABC"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ApplyAny(tt.compare, tt.array, tt.right)
+			if err != nil {
+				t.Fatalf("ApplyAny failed: %v", err)
+			}
+			if result.Type != TYPE_BOOL || result.Bool() != tt.expected {
+				t.Errorf("got %v, want %v", result, tt.expected)
+			}
+		})
+	}
+
+	if _, err := ApplyAny(OP_EQ, StringValue("ABC"), StringValue("ABC")); err == nil || !strings.Contains(err.Error(), "MATCH_ANY requires an array, got STRING") {
+		t.Errorf("Expected array error, got %v", err)
+	}
+	if _, err := ApplyAny(OP_ADD, skus, Int8Value(1)); err == nil || !strings.Contains(err.Error(), "OP_ADD is not a comparison") {
+		t.Errorf("Expected comparison error, got %v", err)
+	}
+}
+
+func TestMatchAny_Execute(t *testing.T) {
+	// items[*].sku=ABC
+	vm := assembleVM(t, `
+		LOAD_GLOBAL "items[*].sku"
+		PUSH STRING "ABC"
+		MATCH_ANY OP_EQ
+	`)
+	if err := vm.Program().Verify(); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	items := []interface{}{
+		map[string]interface{}{"sku": "XYZ"},
+		map[string]interface{}{"sku": "ABC"},
+	}
+	if err := vm.LoadRecords(map[string]interface{}{"items": items}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	assertStackValue(t, vm, TYPE_BOOL, func(v Value) bool { return v.Bool() })
+
+	listing, err := vm.Program().Disassemble()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(listing, "0009  MATCH_ANY OP_EQ\n") {
+		t.Errorf("unexpected listing:\n%s", listing)
+	}
+}
+
+func TestVerify_MatchAny(t *testing.T) {
+	invalid := NewProgram(concat(mustPush(t, "a"), mustPush(t, "a"), SerializeMatchAny(OP_EQ)), nil, 0, nil)
+	if err := invalid.Verify(); err == nil || !strings.Contains(err.Error(), "MATCH_ANY at pc=8 requires an array, got STRING") {
+		t.Errorf("Expected type error, got %v", err)
+	}
+
+	badCompare := NewProgram(concat(mustPush(t, []interface{}{1}), mustPush(t, 1), SerializeMatchAny(OP_ADD)), nil, 0, nil)
+	if err := badCompare.Verify(); err == nil || !strings.Contains(err.Error(), "invalid comparison 0x17") {
+		t.Errorf("Expected invalid comparison error, got %v", err)
+	}
+
+	truncated := NewProgram([]byte{byte(MATCH_ANY)}, nil, 0, nil)
+	if err := truncated.Verify(); err == nil || !strings.Contains(err.Error(), "truncated comparison") {
+		t.Errorf("Expected truncated error, got %v", err)
+	}
+
+	if _, err := Assemble("MATCH_ANY OP_NOT", nil); err == nil || !strings.Contains(err.Error(), `invalid comparison "OP_NOT"`) {
+		t.Errorf("Expected assembler error, got %v", err)
+	}
+}
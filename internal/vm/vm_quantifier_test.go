@@ -0,0 +1,206 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// Quantifier Tests
+// ============================================================================
+
+// Helper pour construire un programme dont le programme 0 teste
+// sku="X" ^ qty>2 sur chaque élément de items
+func quantifierProgram(t *testing.T, op OpCode) *Program {
+	t.Helper()
+	body, err := Assemble(`
+		LOAD_GLOBAL "sku"
+		PUSH STRING "X"
+		OP_EQ
+		JUMP_IF_FALSE_OR_POP end
+		LOAD_GLOBAL "qty"
+		PUSH INT8 2
+		OP_GT
+	end:
+	`, nil)
+	if err != nil {
+		t.Fatalf("Assemble failed: %v", err)
+	}
+	program, err := Assemble(`
+		LOAD_GLOBAL "items"
+		`+op.String()+` 0
+	`, nil)
+	if err != nil {
+		t.Fatalf("Assemble failed: %v", err)
+	}
+	program.AddProgram(body)
+	if err := program.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	return program
+}
+
+func item(sku string, qty int) map[string]interface{} {
+	return map[string]interface{}{"sku": sku, "qty": qty}
+}
+
+func TestQuantifier(t *testing.T) {
+	items := []interface{}{item("X", 3), item("Y", 5), item("X", 1)}
+	matching := []interface{}{item("X", 3), item("X", 4)}
+	none := []interface{}{item("Y", 3)}
+	empty := []interface{}{}
+
+	tests := []struct {
+		name     string
+		op       OpCode
+		items    []interface{}
+		expected bool
+	}{
+		{"any", ANY_OF, items, true},
+		{"any none", ANY_OF, none, false},
+		{"any empty", ANY_OF, empty, false},
+		{"all", ALL_OF, matching, true},
+		{"all mismatch", ALL_OF, items, false},
+		{"all empty", ALL_OF, empty, true},
+		{"none", NONE_OF, none, true},
+		{"none match", NONE_OF, items, false},
+		{"none empty", NONE_OF, empty, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := quantifierProgram(t, tt.op).NewVM()
+			if err := vm.LoadRecords(map[string]interface{}{"items": tt.items}); err != nil {
+				t.Fatal(err)
+			}
+			if err := vm.Execute(); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+			assertStackValue(t, vm, TYPE_BOOL, func(v Value) bool { return v.Bool() == tt.expected })
+		})
+	}
+}
+
+func TestQuantifier_ShortCircuit(t *testing.T) {
+	// le deuxième élément n'a pas de qty : il n'est jamais évalué
+	vm := quantifierProgram(t, ANY_OF).NewVM()
+	items := []interface{}{item("X", 3), map[string]interface{}{"sku": "X"}}
+	if err := vm.LoadRecords(map[string]interface{}{"items": items}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	assertStackValue(t, vm, TYPE_BOOL, func(v Value) bool { return v.Bool() })
+
+	vm.Reset()
+	if err := vm.LoadRecords(map[string]interface{}{"items": []interface{}{item("Y", 1), items[1]}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Execute(); err == nil || !strings.Contains(err.Error(), "ANY element 1: undefined global variable: qty") {
+		t.Errorf("Expected error on element 1, got %v", err)
+	}
+}
+
+func TestQuantifier_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		items       interface{}
+		errContains string
+	}{
+		{"not an array", "X", "ALL requires an array, got STRING"},
+		{"not objects", []interface{}{item("X", 3), "X"}, "ALL requires objects, element 1 is STRING"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := quantifierProgram(t, ALL_OF).NewVM()
+			if err := vm.LoadRecords(map[string]interface{}{"items": tt.items}); err != nil {
+				t.Fatal(err)
+			}
+			if err := vm.Execute(); err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestVerify_Quantifier(t *testing.T) {
+	// index hors limites
+	missing := NewProgram(concat(mustPush(t, []interface{}{}), SerializeQuantifier(ANY_OF, 0)), nil, 0, nil)
+	if err := missing.Verify(); err == nil || !strings.Contains(err.Error(), "nested program index out of bounds: 0 at pc=3") {
+		t.Errorf("Expected bounds error, got %v", err)
+	}
+
+	// le programme imbriqué doit finir par un booléen
+	program := NewProgram(concat(mustPush(t, []interface{}{}), SerializeQuantifier(ALL_OF, 0)), nil, 0, nil)
+	program.AddProgram(NewProgram(mustPush(t, 1), nil, 0, nil))
+	if err := program.Verify(); err == nil || !strings.Contains(err.Error(), "nested program 0: program must end with a boolean") {
+		t.Errorf("Expected nested program error, got %v", err)
+	}
+
+	scalar := NewProgram(concat(mustPush(t, "a"), SerializeQuantifier(NONE_OF, 0)), nil, 0, nil)
+	scalar.AddProgram(NewProgram(mustPush(t, true), nil, 0, nil))
+	if err := scalar.Verify(); err == nil || !strings.Contains(err.Error(), "NONE_OF at pc=4 requires an array, got STRING") {
+		t.Errorf("Expected type error, got %v", err)
+	}
+}
+
+func TestQuantifier_Disassemble(t *testing.T) {
+	listing, err := quantifierProgram(t, NONE_OF).Disassemble()
+	if err != nil {
+		t.Fatalf("Disassemble failed: %v", err)
+	}
+	for _, line := range []string{"0003  NONE_OF 0\n", "; program 0\n0000  LOAD_GLOBAL \"sku\"\n"} {
+		if !strings.Contains(listing, line) {
+			t.Errorf("listing does not contain %q:\n%s", line, listing)
+		}
+	}
+}
+
+func TestQuantifier_RoundTrip(t *testing.T) {
+	data, err := quantifierProgram(t, ANY_OF).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	loaded, err := UnmarshalProgram(data, nil)
+	if err != nil {
+		t.Fatalf("UnmarshalProgram failed: %v", err)
+	}
+	if len(loaded.Programs()) != 1 || len(loaded.Programs()[0].Fields()) != 2 {
+		t.Fatalf("unexpected nested programs: %v", loaded.Programs())
+	}
+
+	vm := loaded.NewVM()
+	if err := vm.LoadRecords(map[string]interface{}{"items": []interface{}{item("X", 3)}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	assertStackValue(t, vm, TYPE_BOOL, func(v Value) bool { return v.Bool() })
+}
+
+func TestUnmarshalProgram_NestingDepth(t *testing.T) {
+	program := NewProgram(mustPush(t, true), nil, 1, nil)
+	for range MaxNesting + 1 {
+		outer := NewProgram(mustPush(t, true), nil, 1, nil)
+		outer.AddProgram(program)
+		program = outer
+	}
+	data, err := program.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UnmarshalProgram(data, nil); err == nil || !strings.Contains(err.Error(), "nested deeper than 32 levels") {
+		t.Errorf("Expected nesting error, got %v", err)
+	}
+}
+
+func TestRegister_Quantifier(t *testing.T) {
+	funcs := NewFunctions()
+	err := funcs.Register(Function{Name: "ALL", MinArgs: 1, MaxArgs: 1, Func: upperFunc})
+	if err == nil || !strings.Contains(err.Error(), "ALL is a built-in quantifier") {
+		t.Errorf("Expected reserved name error, got %v", err)
+	}
+}
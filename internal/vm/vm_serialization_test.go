@@ -1,6 +1,8 @@
 package vm
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -10,9 +12,8 @@ import (
 
 func TestSerializationFunctions(t *testing.T) {
 	t.Run("SerializeLoadGlobal", func(t *testing.T) {
-		result := SerializeLoadGlobal("myvar")
-		expected := []byte{byte(LOAD_GLOBAL), 5, 'm', 'y', 'v', 'a', 'r'}
-		assertBytecodeEqual(t, result, expected)
+		assertBytecodeEqual(t, SerializeLoadGlobal(0), []byte{byte(LOAD_GLOBAL), 0, 0})
+		assertBytecodeEqual(t, SerializeLoadGlobal(258), []byte{byte(LOAD_GLOBAL), 1, 2})
 	})
 
 	t.Run("SerializePush", func(t *testing.T) {
@@ -84,6 +85,13 @@ func TestInferType(t *testing.T) {
 		{42, TYPE_INT8},
 		{1000, TYPE_INT16},
 		{100000, TYPE_INT32},
+		{1 << 40, TYPE_INT64},
+		{int64(1), TYPE_INT64},
+		{uint8(1), TYPE_UINT8},
+		{uint32(1), TYPE_UINT32},
+		{uint64(1), TYPE_UINT64},
+		{1.5, TYPE_FLOAT64},
+		{float32(1.5), TYPE_FLOAT64},
 		{"hello", TYPE_STRING},
 		{true, TYPE_BOOL},
 		{[]interface{}{1, 2}, TYPE_ARRAY},
@@ -111,6 +119,31 @@ func TestSerializeValue(t *testing.T) {
 			input:    42,
 			expected: []byte{byte(TYPE_INT8), 1, 42},
 		},
+		{
+			name:     "int16",
+			input:    1000,
+			expected: []byte{byte(TYPE_INT16), 2, 0x03, 0xE8},
+		},
+		{
+			name:     "int64",
+			input:    1700000000000,
+			expected: []byte{byte(TYPE_INT64), 8, 0x00, 0x00, 0x01, 0x8B, 0xCF, 0xE5, 0x68, 0x00},
+		},
+		{
+			name:     "uint16",
+			input:    uint16(0xBEEF),
+			expected: []byte{byte(TYPE_UINT16), 2, 0xBE, 0xEF},
+		},
+		{
+			name:     "uint64",
+			input:    uint64(1 << 63),
+			expected: []byte{byte(TYPE_UINT64), 8, 0x80, 0, 0, 0, 0, 0, 0, 0},
+		},
+		{
+			name:     "float64",
+			input:    19.99,
+			expected: []byte{byte(TYPE_FLOAT64), 8, 0x40, 0x33, 0xFD, 0x70, 0xA3, 0xD7, 0x0A, 0x3D},
+		},
 		{
 			name:     "string",
 			input:    "ab",
@@ -130,6 +163,11 @@ func TestSerializeValue(t *testing.T) {
 				byte(TYPE_INT8), 1, 2,
 			},
 		},
+		{
+			name:     "string of 300 bytes: 2 bytes varint length",
+			input:    strings.Repeat("x", 300),
+			expected: append([]byte{byte(TYPE_STRING), 0xAC, 0x02}, strings.Repeat("x", 300)...),
+		},
 	}
 
 	for _, tt := range tests {
@@ -142,3 +180,67 @@ func TestSerializeValue(t *testing.T) {
 		})
 	}
 }
+
+func TestSerializeValue_Limits(t *testing.T) {
+	tooMany := make([]interface{}, MaxOperandLength+1)
+	for i := range tooMany {
+		tooMany[i] = true
+	}
+	tests := []struct {
+		name  string
+		input interface{}
+	}{
+		{"string too long", strings.Repeat("x", MaxOperandLength+1)},
+		{"array too long", tooMany},
+		{"nested array item too long", []interface{}{"ok", strings.Repeat("x", MaxOperandLength+1)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := serializeValue(tt.input); err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+		})
+	}
+}
+
+// values above the old 255 byte limit survive a serialize / decode round trip
+func TestSerializeValue_RoundTrip(t *testing.T) {
+	ids := make([]interface{}, 5000)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("id-%d", i)
+	}
+	tests := []struct {
+		name  string
+		input interface{}
+	}{
+		{"long string", strings.Repeat("abc", 1000)},
+		{"large array", ids},
+		{"nested large array", []interface{}{ids, "x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected, err := ToValue(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bytecode, err := serializeValue(tt.input)
+			if err != nil {
+				t.Fatalf("serializeValue failed: %v", err)
+			}
+
+			vm := NewVM(bytecode, nil)
+			got, err := vm.inferRuntimeValue()
+			if err != nil {
+				t.Fatalf("inferRuntimeValue failed: %v", err)
+			}
+			if vm.pc != len(bytecode) {
+				t.Errorf("Expected to consume %d bytes, consumed %d", len(bytecode), vm.pc)
+			}
+			if res, err := got.Compare(expected); err != nil || res != 0 {
+				t.Errorf("Round trip mismatch: got %v", got)
+			}
+		})
+	}
+}
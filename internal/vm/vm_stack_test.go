@@ -11,14 +11,14 @@ import (
 func TestStackOperations(t *testing.T) {
 	t.Run("pop", func(t *testing.T) {
 		vm := NewVM([]byte{}, nil)
-		vm.push(Value{Type: TYPE_INT8, Int8: 42})
+		vm.push(Int8Value(42))
 
 		val, err := vm.pop()
 		if err != nil {
 			t.Fatalf("pop failed: %v", err)
 		}
-		if val.Int8 != 42 {
-			t.Errorf("Expected 42, got %d", val.Int8)
+		if val.Int64() != 42 {
+			t.Errorf("Expected 42, got %d", val.Int64())
 		}
 		if len(vm.dataStack) != 0 {
 			t.Errorf("Expected empty stack after pop, got %d elements", len(vm.dataStack))
@@ -35,23 +35,23 @@ func TestStackOperations(t *testing.T) {
 
 	t.Run("push", func(t *testing.T) {
 		vm := NewVM([]byte{}, nil)
-		vm.push(Value{Type: TYPE_INT8, Int8: 1})
-		vm.push(Value{Type: TYPE_INT8, Int8: 2})
-		vm.push(Value{Type: TYPE_INT8, Int8: 3})
+		vm.push(Int8Value(1))
+		vm.push(Int8Value(2))
+		vm.push(Int8Value(3))
 
 		if len(vm.dataStack) != 3 {
 			t.Errorf("Expected 3 elements on stack, got %d", len(vm.dataStack))
 		}
-		if vm.dataStack[0].Int8 != 1 {
-			t.Errorf("Expected first element to be 1, got %d", vm.dataStack[0].Int8)
+		if vm.dataStack[0].Int64() != 1 {
+			t.Errorf("Expected first element to be 1, got %d", vm.dataStack[0].Int64())
 		}
 	})
 
 	t.Run("popN", func(t *testing.T) {
 		vm := NewVM([]byte{}, nil)
-		vm.push(Value{Type: TYPE_INT8, Int8: 1})
-		vm.push(Value{Type: TYPE_INT8, Int8: 2})
-		vm.push(Value{Type: TYPE_INT8, Int8: 3})
+		vm.push(Int8Value(1))
+		vm.push(Int8Value(2))
+		vm.push(Int8Value(3))
 
 		vals, err := vm.popN(2)
 		if err != nil {
@@ -60,17 +60,31 @@ func TestStackOperations(t *testing.T) {
 		if len(vals) != 2 {
 			t.Errorf("Expected 2 values, got %d", len(vals))
 		}
-		if vals[0].Int8 != 2 || vals[1].Int8 != 3 {
-			t.Errorf("Expected [2,3], got [%d,%d]", vals[0].Int8, vals[1].Int8)
+		if vals[0].Int64() != 2 || vals[1].Int64() != 3 {
+			t.Errorf("Expected [2,3], got [%d,%d]", vals[0].Int64(), vals[1].Int64())
 		}
 		if len(vm.dataStack) != 1 {
 			t.Errorf("Expected 1 element left on stack, got %d", len(vm.dataStack))
 		}
 	})
 
+	t.Run("popN does not allocate", func(t *testing.T) {
+		vm := NewVM([]byte{}, nil)
+		allocs := testing.AllocsPerRun(100, func() {
+			vm.push(Int8Value(1))
+			vm.push(Int8Value(2))
+			if _, err := vm.popN(2); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if allocs != 0 {
+			t.Errorf("popN allocates %.1f times per run, want 0", allocs)
+		}
+	})
+
 	t.Run("popN underflow", func(t *testing.T) {
 		vm := NewVM([]byte{}, nil)
-		vm.push(Value{Type: TYPE_INT8, Int8: 1})
+		vm.push(Int8Value(1))
 
 		_, err := vm.popN(5)
 		if err == nil {
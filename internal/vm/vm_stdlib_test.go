@@ -0,0 +1,116 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// Standard Library Tests
+// ============================================================================
+
+// Helper pour appeler une fonction du registre standard
+func callBuiltin(t *testing.T, name string, args ...Value) (Value, error) {
+	t.Helper()
+	fn, ok := Builtins().Lookup(name)
+	if !ok {
+		t.Fatalf("builtin %s not found", name)
+	}
+	if err := fn.CheckArgs(len(args)); err != nil {
+		t.Fatal(err)
+	}
+	return fn.Func(args)
+}
+
+func TestBuiltins(t *testing.T) {
+	s := StringValue
+	tests := []struct {
+		name     string
+		args     []Value
+		expected string
+	}{
+		{"UPPER", []Value{s("Zoë smith")}, "ZOË SMITH"},
+		{"LOWER", []Value{s("John@Corp.COM")}, "john@corp.com"},
+		{"TRIM", []Value{s(" \t a b \n")}, "a b"},
+		{"LEN", []Value{s("Zoë")}, "3"},
+		{"LEN", []Value{s("")}, "0"},
+		{"SUBSTR", []Value{s("FR-75-001"), Int8Value(3), Int8Value(2)}, "75"},
+		{"SUBSTR", []Value{s("Zoë Smith"), Uint8Value(2)}, "ë Smith"},
+		{"SUBSTR", []Value{s("Zoë"), Int8Value(2), Int8Value(10)}, "ë"},
+		{"SUBSTR", []Value{s("Zoë"), Int8Value(3)}, ""},
+		{"SUBSTR", []Value{s("Zoë"), Uint64Value(1 << 63), Uint64Value(1<<64 - 1)}, ""},
+		{"SUBSTR", []Value{s("abc"), Int8Value(0), Int8Value(0)}, ""},
+		{"REPLACE", []Value{s("a-b-c"), s("-"), s("")}, "abc"},
+		{"SPLIT", []Value{s("a,b,,c"), s(",")}, "[a b  c]"},
+		{"SPLIT", []Value{s("Zoë"), s("")}, "[Z o ë]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"/"+tt.expected, func(t *testing.T) {
+			result, err := callBuiltin(t, tt.name, tt.args...)
+			if err != nil {
+				t.Fatalf("%s failed: %v", tt.name, err)
+			}
+			if result.String() != tt.expected {
+				t.Errorf("%s = %q, want %q", tt.name, result.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuiltins_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []Value
+		errContains string
+	}{
+		{"UPPER", []Value{Int8Value(1)}, "UPPER argument 1 expects string, got INT8"},
+		{"LEN", []Value{ArrayValue(nil)}, "LEN argument 1 expects string, got ARRAY"},
+		{"SUBSTR", []Value{StringValue("abc"), StringValue("1")}, "SUBSTR argument 2 expects integer, got STRING"},
+		{"SUBSTR", []Value{StringValue("abc"), Int8Value(-1)}, "must not be negative"},
+		{"SUBSTR", []Value{StringValue("abc"), Int8Value(0), Float64Value(1)}, "SUBSTR argument 3 expects integer, got FLOAT64"},
+		{"REPLACE", []Value{StringValue("abc"), StringValue("a"), BoolValue(true)}, "REPLACE argument 3 expects string"},
+		{"SPLIT", []Value{StringValue("abc"), Int8Value(1)}, "SPLIT argument 2 expects string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := callBuiltin(t, tt.name, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestBuiltins_Registry(t *testing.T) {
+	funcs := Builtins()
+	if names := strings.Join(funcs.Names(), ","); names != "LEN,LOWER,REPLACE,SPLIT,SUBSTR,TRIM,UPPER" {
+		t.Errorf("unexpected builtins: %s", names)
+	}
+	// chaque appel retourne un registre indépendant
+	noop := func(args []Value) (Value, error) { return BoolValue(true), nil }
+	if err := funcs.Register(Function{Name: "NOOP", Func: noop}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Builtins().Lookup("NOOP"); ok {
+		t.Error("Register modified the standard library")
+	}
+}
+
+func TestFunction_CheckKinds(t *testing.T) {
+	substr, _ := Builtins().Lookup("SUBSTR")
+	if err := substr.CheckKinds([]Kind{KindString, KindInteger, KindAny}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := substr.CheckKinds([]Kind{KindAny, KindFloat}); err == nil || err.Error() != "SUBSTR argument 2 expects integer, got float" {
+		t.Errorf("expected kind error, got %v", err)
+	}
+	// pas de Params : rien n'est vérifié
+	if err := (Function{Name: "F"}).CheckKinds([]Kind{KindArray}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if KindOf(TYPE_UINT16) != KindInteger || KindOf(typeUnknown) != KindAny {
+		t.Error("unexpected KindOf result")
+	}
+}
@@ -33,10 +33,10 @@ func TestStringOperations(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			left := Value{Type: TYPE_STRING, String: tt.str}
-			right := Value{Type: TYPE_STRING, String: tt.pattern}
+			left := StringValue(tt.str)
+			right := StringValue(tt.pattern)
 			testBinaryHandler(t, tt.handler, left, right, TYPE_BOOL, func(v Value) bool {
-				return v.Bool == tt.expected
+				return v.Bool() == tt.expected
 			})
 		})
 	}
@@ -55,34 +55,44 @@ func TestInHandler(t *testing.T) {
 	}{
 		{
 			name:   "scalar in array - found",
-			needle: Value{Type: TYPE_INT8, Int8: 2},
-			haystack: Value{Type: TYPE_ARRAY, Array: []Value{
-				{Type: TYPE_INT8, Int8: 1},
-				{Type: TYPE_INT8, Int8: 2},
-				{Type: TYPE_INT8, Int8: 3},
-			}},
+			needle: Int8Value(2),
+			haystack: ArrayValue([]Value{
+				Int8Value(1),
+				Int8Value(2),
+				Int8Value(3),
+			}),
 			expected: true,
 		},
 		{
 			name:   "scalar in array - not found",
-			needle: Value{Type: TYPE_INT8, Int8: 5},
-			haystack: Value{Type: TYPE_ARRAY, Array: []Value{
-				{Type: TYPE_INT8, Int8: 1},
-				{Type: TYPE_INT8, Int8: 2},
-			}},
+			needle: Int8Value(5),
+			haystack: ArrayValue([]Value{
+				Int8Value(1),
+				Int8Value(2),
+			}),
 			expected: false,
 		},
 		{
 			name: "array in array - any match",
-			needle: Value{Type: TYPE_ARRAY, Array: []Value{
-				{Type: TYPE_INT8, Int8: 2},
-				{Type: TYPE_INT8, Int8: 3},
-			}},
-			haystack: Value{Type: TYPE_ARRAY, Array: []Value{
-				{Type: TYPE_INT8, Int8: 1},
-				{Type: TYPE_INT8, Int8: 2},
-				{Type: TYPE_INT8, Int8: 4},
-			}},
+			needle: ArrayValue([]Value{
+				Int8Value(2),
+				Int8Value(3),
+			}),
+			haystack: ArrayValue([]Value{
+				Int8Value(1),
+				Int8Value(2),
+				Int8Value(4),
+			}),
+			expected: true,
+		},
+		{
+			name:   "scalar in array - mixed widths",
+			needle: Int16Value(300),
+			haystack: ArrayValue([]Value{
+				Int8Value(1),
+				Int16Value(300),
+				Int32Value(100000),
+			}),
 			expected: true,
 		},
 	}
@@ -90,7 +100,7 @@ func TestInHandler(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			testBinaryHandler(t, (*VM).inHandler, tt.needle, tt.haystack, TYPE_BOOL, func(v Value) bool {
-				return v.Bool == tt.expected
+				return v.Bool() == tt.expected
 			})
 		})
 	}
@@ -0,0 +1,225 @@
+package vm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// Struct Tests
+// ============================================================================
+
+type testStatus string
+
+type testAudit struct {
+	Created int64 `sel:"created"`
+	Author  string
+}
+
+type testBase struct {
+	ID   int `sel:"id"`
+	Name string
+}
+
+type testItem struct {
+	SKU string `sel:"sku"`
+	Qty int    `sel:"qty"`
+}
+
+type testDepartment struct {
+	Name string `sel:"name"`
+}
+
+type testCaller struct {
+	Department *testDepartment `sel:"department"`
+	Groups     []string        `sel:"groups"`
+}
+
+type testRecord struct {
+	testBase
+	*testAudit
+	Status   testStatus        `sel:"status"`
+	Score    float32           `sel:"score,omitempty"`
+	Level    *uint8            `sel:"level"`
+	Secret   string            `sel:"-"`
+	Caller   testCaller        `sel:"caller"`
+	Items    []testItem        `sel:"items"`
+	Labels   map[string]string `sel:"labels"`
+	Name     string            // masque testBase.Name
+	internal int
+}
+
+// Helper pour charger une structure dans un programme qui lit fields
+func loadStruct(t *testing.T, v interface{}, fields ...string) *VM {
+	t.Helper()
+	vm := NewProgram(nil, fields, 0, nil).NewVM()
+	if err := vm.LoadStruct(v); err != nil {
+		t.Fatalf("LoadStruct failed: %v", err)
+	}
+	return vm
+}
+
+func TestLoadStruct(t *testing.T) {
+	level := uint8(3)
+	record := &testRecord{
+		testBase:  testBase{ID: 7, Name: "base"},
+		testAudit: &testAudit{Created: 1700000000, Author: "bob"},
+		Status:    "active",
+		Score:     4.5,
+		Level:     &level,
+		Secret:    "hidden",
+		Caller:    testCaller{Department: &testDepartment{Name: "IT"}, Groups: []string{"a", "b"}},
+		Items:     []testItem{{SKU: "X", Qty: 1}, {SKU: "Y", Qty: 2}},
+		Labels:    map[string]string{"env": "prod"},
+		Name:      "outer",
+	}
+
+	tests := []struct {
+		field    string
+		expected string
+	}{
+		{"status", "active"},
+		{"score", "4.5"},
+		{"level", "3"},
+		{"id", "7"},
+		{"Name", "outer"},
+		{"created", "1700000000"},
+		{"Author", "bob"},
+		{"caller.department.name", "IT"},
+		{"caller.groups[-1]", "b"},
+		{"items[1].sku", "Y"},
+		{"items[*].qty", "[1 2]"},
+		{"labels.env", "prod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			vm := loadStruct(t, record, tt.field)
+			val, ok := lookupGlobal(vm, tt.field)
+			if !ok {
+				t.Fatalf("field %s not loaded", tt.field)
+			}
+			if val.String() != tt.expected {
+				t.Errorf("got %s, want %s", val.String(), tt.expected)
+			}
+		})
+	}
+
+	// les éléments deviennent des objets pour les quantificateurs
+	vm := loadStruct(t, *record, "items")
+	items, _ := lookupGlobal(vm, "items")
+	if elems := items.Array(); len(elems) != 2 || elems[0].Type != TYPE_OBJECT || elems[0].Object()["sku"] != "X" {
+		t.Errorf("unexpected items %v", items)
+	}
+}
+
+func TestLoadStruct_Undefined(t *testing.T) {
+	record := &testRecord{Caller: testCaller{}}
+	for _, field := range []string{"Secret", "internal", "level", "created", "caller.department.name", "missing", "items[*].sku"} {
+		t.Run(field, func(t *testing.T) {
+			vm := loadStruct(t, record, field)
+			if val, ok := lookupGlobal(vm, field); ok {
+				t.Errorf("field %s should be undefined, got %v", field, val)
+			}
+		})
+	}
+}
+
+func TestLoadStruct_Errors(t *testing.T) {
+	var nilRecord *testRecord
+	tests := []struct {
+		name        string
+		value       interface{}
+		field       string
+		errContains string
+	}{
+		{"not a struct", map[string]interface{}{}, "a", "expected a struct or a pointer to a struct, got map[string]interface {}"},
+		{"nil pointer", nilRecord, "a", "cannot load fields of a nil *vm.testRecord"},
+		{"out of range", &testRecord{Items: []testItem{}}, "items[0].sku", "field items[0].sku: items[0]: index 0 out of range, 0 elements"},
+		{"not an object", &testRecord{Status: "a"}, "status.x", "field status.x: status is vm.testStatus, not an object"},
+		{"unsupported", &struct{ C chan int }{C: make(chan int)}, "C", "unsupported type: chan int"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := newTestVM(SerializeLoadGlobal(0), tt.field)
+			err := vm.LoadStruct(tt.value)
+			if err == nil {
+				err = vm.run() // les erreurs de chemin sont levées à la lecture
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+type testNode struct {
+	Name     string
+	Parent   *testNode
+	Children []*testNode
+}
+
+func TestLoadStruct_Cycle(t *testing.T) {
+	root := &testNode{Name: "root"}
+	child := &testNode{Name: "child", Parent: root}
+	root.Children = []*testNode{child, {Name: "leaf", Parent: root}}
+
+	// les chemins ne parcourent que les segments demandés
+	tests := []struct {
+		node     *testNode
+		field    string
+		expected string
+	}{
+		{child, "Parent.Name", "root"},
+		{child, "Parent.Children[-1].Name", "leaf"},
+		{root, "Children[0].Parent.Children[0].Name", "child"},
+		{root, "Children[*].Name", "[child leaf]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			vm := loadStruct(t, tt.node, tt.field)
+			if val, ok := lookupGlobal(vm, tt.field); !ok || val.String() != tt.expected {
+				t.Errorf("got %v, want %s", val, tt.expected)
+			}
+		})
+	}
+
+	// convertir un noeud entier rencontre le cycle
+	for _, field := range []string{"Parent", "Children", "Children[0]"} {
+		t.Run(field, func(t *testing.T) {
+			vm := NewProgram(nil, []string{field}, 0, nil).NewVM()
+			err := vm.LoadStruct(child)
+			if field != "Parent" {
+				err = vm.LoadStruct(root)
+			}
+			if err == nil || !strings.Contains(err.Error(), "cycle through") {
+				t.Errorf("Expected cycle error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPlanOf_Ambiguous(t *testing.T) {
+	type left struct{ Name, City string }
+	type right struct {
+		Name string
+		City string `sel:"City"`
+	}
+	type both struct {
+		left
+		right
+	}
+
+	plan := planOf(reflect.TypeOf(both{}))
+	if _, ok := plan.fields["Name"]; ok {
+		t.Error("Name is ambiguous at the same depth and must be left out")
+	}
+	if f, ok := plan.fields["City"]; !ok || f.index[0] != 1 {
+		t.Errorf("City must come from the tagged field, got %v", f)
+	}
+	if planOf(reflect.TypeOf(both{})) != plan {
+		t.Error("the plan must be cached per type")
+	}
+}
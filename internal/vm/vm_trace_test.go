@@ -0,0 +1,189 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// Tracer Tests
+// ============================================================================
+
+// recordingTracer garde les appels reçus sous forme de texte
+type recordingTracer struct {
+	calls []string
+}
+
+func (r *recordingTracer) Before(step Step) {
+	r.calls = append(r.calls, fmt.Sprintf("before %v %d %v", step.Op, step.PC, len(step.Stack)))
+}
+
+func (r *recordingTracer) After(step Step, err error) {
+	r.calls = append(r.calls, fmt.Sprintf("after %v %d %v %v", step.Op, step.PC, len(step.Stack), err))
+}
+
+// Helper pour assembler et vérifier un programme
+func verifiedVM(t *testing.T, source string, nativeFuncs ...NativeFunc) *VM {
+	t.Helper()
+	program, err := Assemble(source, nativeFuncs)
+	if err != nil {
+		t.Fatalf("Assemble failed: %v", err)
+	}
+	if err := program.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	return program.NewVM()
+}
+
+func TestTracer_Calls(t *testing.T) {
+	vm := verifiedVM(t, `
+		LOAD_GLOBAL "age"
+		PUSH INT8 18
+		OP_GT
+		JUMP_IF_TRUE_OR_POP end
+		PUSH BOOL false
+	end:
+	`)
+	if err := vm.LoadRecords(map[string]interface{}{"age": 30}); err != nil {
+		t.Fatal(err)
+	}
+	tracer := &recordingTracer{}
+	vm.SetTracer(tracer)
+
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	expected := []string{
+		"before LOAD_GLOBAL 0 0", "after LOAD_GLOBAL 0 1 <nil>",
+		"before PUSH 3 1", "after PUSH 3 2 <nil>",
+		"before OP_GT 7 2", "after OP_GT 7 1 <nil>",
+		"before JUMP_IF_TRUE_OR_POP 8 1", "after JUMP_IF_TRUE_OR_POP 8 1 <nil>",
+	}
+	if !slices.Equal(tracer.calls, expected) {
+		t.Errorf("unexpected calls:\n%s", strings.Join(tracer.calls, "\n"))
+	}
+	if len(vm.DataStack()) != 1 || !vm.DataStack()[0].Bool() {
+		t.Errorf("tracing changed the result: %v", vm.DataStack())
+	}
+}
+
+func TestTracer_Operands(t *testing.T) {
+	var steps []Step
+	vm := verifiedVM(t, `
+		PUSH ARRAY [STRING "a", INT8 1]
+		PUSH STRING "a"
+		CALL_NATIVE 0 2
+		OP_NOT
+	`, func(args []Value) (Value, error) { return BoolValue(true), nil })
+	vm.SetTracer(tracerFunc(func(step Step) { steps = append(steps, step) }))
+
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if len(steps) != 4 {
+		t.Fatalf("expected 4 steps, got %d", len(steps))
+	}
+	if array := steps[0].Value; array.Type != TYPE_ARRAY || len(array.Array()) != 2 {
+		t.Errorf("PUSH step should carry the array, got %v", array)
+	}
+	if call := steps[2]; call.Index != 0 || call.Argc != 2 || len(call.Stack) != 1 {
+		t.Errorf("CALL_NATIVE step: index %d argc %d stack %v", call.Index, call.Argc, call.Stack)
+	}
+	if got := steps[1].String(); got != `0009  PUSH STRING "a"` {
+		t.Errorf("Step.String() = %q", got)
+	}
+
+	// la copie de la stack ne bouge plus après l'instruction
+	steps[3].Stack[0] = Int8Value(1)
+	if vm.DataStack()[0].Type != TYPE_BOOL {
+		t.Error("the step stack should be a copy")
+	}
+}
+
+// keepingTracer garde les étapes elles-mêmes
+type keepingTracer struct {
+	steps []Step
+}
+
+func (k *keepingTracer) Before(Step) {}
+
+func (k *keepingTracer) After(step Step, err error) {
+	k.steps = append(k.steps, step)
+}
+
+func TestTracer_KeptSteps(t *testing.T) {
+	vm := verifiedVM(t, `
+		LOAD_GLOBAL "tags"
+		LOAD_GLOBAL "tags"
+		OP_EQ
+	`)
+	tracer := &keepingTracer{}
+	vm.SetTracer(tracer)
+
+	records := []map[string]interface{}{
+		{"tags": []interface{}{"a", []interface{}{"b"}}},
+		{"tags": []interface{}{"c", []interface{}{"d"}}},
+	}
+	for _, record := range records {
+		vm.Reset()
+		if err := vm.LoadRecords(record); err != nil {
+			t.Fatal(err)
+		}
+		if err := vm.Execute(); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+	}
+
+	// les étapes de la première évaluation n'ont pas été écrasées par l'arène
+	if len(tracer.steps) != 6 {
+		t.Fatalf("expected 6 steps, got %d", len(tracer.steps))
+	}
+	for i, expected := range []string{"[a [b]]", "[c [d]]"} {
+		stack := tracer.steps[i*3].Stack
+		if len(stack) != 1 || stack[0].String() != expected {
+			t.Errorf("run %d: kept stack %v, want [%s]", i, stack, expected)
+		}
+	}
+}
+
+func TestTracer_Error(t *testing.T) {
+	failure := errors.New("boom")
+	vm := verifiedVM(t, `
+		PUSH INT8 1
+		CALL_NATIVE 0 1
+		OP_NOT
+	`, func(args []Value) (Value, error) { return Value{}, failure })
+	tracer := &recordingTracer{}
+	vm.SetTracer(tracer)
+
+	if err := vm.Execute(); err == nil || !strings.Contains(err.Error(), failure.Error()) {
+		t.Fatalf("expected the native error, got %v", err)
+	}
+	last := tracer.calls[len(tracer.calls)-1]
+	if !strings.HasPrefix(last, "after CALL_NATIVE 4 0 native function failed: boom") {
+		t.Errorf("After should receive the handler error, got %q", last)
+	}
+}
+
+func TestTracer_Disabled(t *testing.T) {
+	vm := verifiedVM(t, "PUSH BOOL true")
+	tracer := &recordingTracer{}
+	vm.SetTracer(tracer)
+	vm.SetTracer(nil)
+
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if len(tracer.calls) != 0 {
+		t.Errorf("no call expected once the tracer is removed, got %v", tracer.calls)
+	}
+}
+
+// tracerFunc appelle f après chaque instruction
+type tracerFunc func(Step)
+
+func (f tracerFunc) Before(Step)              {}
+func (f tracerFunc) After(step Step, _ error) { f(step) }
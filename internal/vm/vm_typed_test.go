@@ -0,0 +1,149 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// Typed Slices and Maps Tests
+// ============================================================================
+
+type testRole string
+
+type testTags []string
+
+func TestToValue_Typed(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    interface{}
+		expected string
+		typ      Type
+	}{
+		{"[]string", []string{"a", "b"}, "[a b]", TYPE_ARRAY},
+		{"[]int", []int{1, 300}, "[1 300]", TYPE_ARRAY},
+		{"[]int64", []int64{-1}, "[-1]", TYPE_ARRAY},
+		{"[]float64", []float64{0.5}, "[0.5]", TYPE_ARRAY},
+		{"[]bool", []bool{true}, "[true]", TYPE_ARRAY},
+		{"[2]uint8", [2]uint8{1, 2}, "[1 2]", TYPE_ARRAY},
+		{"named slice", testTags{"x"}, "[x]", TYPE_ARRAY},
+		{"[]testRole", []testRole{"admin"}, "[admin]", TYPE_ARRAY},
+		{"[][]string", [][]string{{"a"}, {"b", "c"}}, "[[a] [b c]]", TYPE_ARRAY},
+		{"nil []string", []string(nil), "[]", TYPE_ARRAY},
+		{"named string", testRole("admin"), "admin", TYPE_STRING},
+		{"map[string]string", map[string]string{"env": "prod"}, "{env:prod}", TYPE_OBJECT},
+		{"map[string]int", map[string]int{"a": 1, "b": 2}, "{a:1 b:2}", TYPE_OBJECT},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			val, err := ToValue(tt.input)
+			if err != nil {
+				t.Fatalf("ToValue failed: %v", err)
+			}
+			if val.Type != tt.typ || val.String() != tt.expected {
+				t.Errorf("ToValue = %v %s, want %v %s", val.Type, val.String(), tt.typ, tt.expected)
+			}
+
+			// même résultat dans l'arène du VM
+			vm := NewVM([]byte{}, nil)
+			val, err = vm.convertInterfaceToValue(tt.input)
+			if err != nil {
+				t.Fatalf("convertInterfaceToValue failed: %v", err)
+			}
+			if val.Type != tt.typ || val.String() != tt.expected {
+				t.Errorf("convertInterfaceToValue = %v %s, want %v %s", val.Type, val.String(), tt.typ, tt.expected)
+			}
+		})
+	}
+}
+
+func TestToValue_TypedErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       interface{}
+		errContains string
+	}{
+		{"[]chan", []chan int{make(chan int)}, "failed to convert array element 0: unsupported type: chan int"},
+		{"map[int]string", map[int]string{1: "a"}, "unsupported type: map[int]string"},
+		{"[]*int", []*int{nil}, "unsupported type: *int"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ToValue(tt.input); err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("ToValue: expected error containing %q, got %v", tt.errContains, err)
+			}
+			vm := NewVM([]byte{}, nil)
+			if _, err := vm.convertInterfaceToValue(tt.input); err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("convertInterfaceToValue: expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestLookup_Typed(t *testing.T) {
+	records := map[string]interface{}{
+		"roles":  []string{"admin", "ops"},
+		"labels": map[string]string{"env": "prod"},
+		"teams":  []map[string]interface{}{{"name": "net"}, {"name": "db"}},
+		"scores": map[string][]int{"q1": {1, 2}},
+	}
+
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"roles[1]", "ops"},
+		{"labels.env", "prod"},
+		{"teams[-1].name", "db"},
+		{"teams[*].name", "[net db]"},
+		{"scores.q1[0]", "1"},
+		{"roles[*]", "[admin ops]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			val, exists, err := Lookup(records, tt.key)
+			if err != nil || !exists {
+				t.Fatalf("Lookup = %v, %v, %v", val, exists, err)
+			}
+			converted, err := ToValue(val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if converted.String() != tt.expected {
+				t.Errorf("got %s, want %s", converted.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadRecords_Typed(t *testing.T) {
+	vm := NewProgram(nil, []string{"roles", "labels", "scores"}, 0, nil).NewVM()
+	records := map[string]interface{}{
+		"roles":  []string{"admin", "ops"},
+		"labels": map[string]string{"env": "prod"},
+		"scores": []int{3, 4},
+	}
+	if err := vm.LoadRecords(records); err != nil {
+		t.Fatalf("LoadRecords failed: %v", err)
+	}
+	for field, expected := range map[string]string{"roles": "[admin ops]", "labels": "{env:prod}", "scores": "[3 4]"} {
+		if val, ok := lookupGlobal(vm, field); !ok || val.String() != expected {
+			t.Errorf("%s = %v, want %s", field, val, expected)
+		}
+	}
+
+	// les tranches typées courantes sont chargées sans allocation
+	slices := map[string]interface{}{"roles": records["roles"], "scores": records["scores"]}
+	allocs := testing.AllocsPerRun(100, func() {
+		vm.Reset()
+		if err := vm.LoadRecords(slices); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("LoadRecords allocates %.1f times per run, want 0", allocs)
+	}
+}
@@ -1,9 +1,70 @@
 package vm
 
 import (
+	"fmt"
+	"math"
 	"testing"
+	"unsafe"
 )
 
+// ============================================================================
+// Value Representation Tests
+// ============================================================================
+
+func TestValue_Size(t *testing.T) {
+	if size := unsafe.Sizeof(Value{}); size != 24 {
+		t.Errorf("Value should stay compact, got %d bytes", size)
+	}
+}
+
+func TestValue_Accessors(t *testing.T) {
+	if v := Int8Value(-5); v.Int64() != -5 || v.Uint64() != 0 {
+		t.Errorf("int8(-5): got Int64=%d Uint64=%d", v.Int64(), v.Uint64())
+	}
+	if v := Int64Value(math.MinInt64); v.Int64() != math.MinInt64 {
+		t.Errorf("int64 min: got %d", v.Int64())
+	}
+	if v := Uint64Value(math.MaxUint64); v.Uint64() != math.MaxUint64 || v.Int64() != 0 {
+		t.Errorf("uint64 max: got Uint64=%d Int64=%d", v.Uint64(), v.Int64())
+	}
+	if v := Float64Value(-0.5); v.Float64() != -0.5 {
+		t.Errorf("float64: got %v", v.Float64())
+	}
+	if v := BoolValue(true); !v.Bool() || StringValue("true").Bool() {
+		t.Error("Bool should only be true for a true bool value")
+	}
+	if v := StringValue("héllo"); v.String() != "héllo" {
+		t.Errorf("string: got %q", v.String())
+	}
+	if v := ArrayValue([]Value{Int8Value(1)}); len(v.Array()) != 1 || StringValue("x").Array() != nil {
+		t.Error("Array should only return elements of an array value")
+	}
+	if (Value{}).Bool() {
+		t.Error("zero Value should be false")
+	}
+}
+
+func TestValue_String(t *testing.T) {
+	tests := []struct {
+		value    Value
+		expected string
+	}{
+		{StringValue(""), ""},
+		{StringValue("abc"), "abc"},
+		{BoolValue(false), "false"},
+		{Int16Value(-300), "-300"},
+		{Uint32Value(4000000000), "4000000000"},
+		{Float64Value(19.5), "19.5"},
+		{ArrayValue([]Value{StringValue("a"), Int8Value(2)}), "[a 2]"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.value.String(); got != tt.expected {
+			t.Errorf("String() = %q, want %q", got, tt.expected)
+		}
+	}
+}
+
 // ============================================================================
 // Value.Compare Tests
 // ============================================================================
@@ -16,71 +77,71 @@ func TestValueCompare(t *testing.T) {
 		expected int
 	}{
 		// Bool
-		{"bool: true == true", Value{Type: TYPE_BOOL, Bool: true}, Value{Type: TYPE_BOOL, Bool: true}, 0},
-		{"bool: false == false", Value{Type: TYPE_BOOL, Bool: false}, Value{Type: TYPE_BOOL, Bool: false}, 0},
-		{"bool: false < true", Value{Type: TYPE_BOOL, Bool: false}, Value{Type: TYPE_BOOL, Bool: true}, -1},
-		{"bool: true > false", Value{Type: TYPE_BOOL, Bool: true}, Value{Type: TYPE_BOOL, Bool: false}, 1},
+		{"bool: true == true", BoolValue(true), BoolValue(true), 0},
+		{"bool: false == false", BoolValue(false), BoolValue(false), 0},
+		{"bool: false < true", BoolValue(false), BoolValue(true), -1},
+		{"bool: true > false", BoolValue(true), BoolValue(false), 1},
 
 		// Int8
-		{"int8: 5 == 5", Value{Type: TYPE_INT8, Int8: 5}, Value{Type: TYPE_INT8, Int8: 5}, 0},
-		{"int8: 10 > 5", Value{Type: TYPE_INT8, Int8: 10}, Value{Type: TYPE_INT8, Int8: 5}, 1},
-		{"int8: 3 < 7", Value{Type: TYPE_INT8, Int8: 3}, Value{Type: TYPE_INT8, Int8: 7}, -1},
-		{"int8: -5 < 5", Value{Type: TYPE_INT8, Int8: -5}, Value{Type: TYPE_INT8, Int8: 5}, -1},
+		{"int8: 5 == 5", Int8Value(5), Int8Value(5), 0},
+		{"int8: 10 > 5", Int8Value(10), Int8Value(5), 1},
+		{"int8: 3 < 7", Int8Value(3), Int8Value(7), -1},
+		{"int8: -5 < 5", Int8Value(-5), Int8Value(5), -1},
 
 		// Int16
-		{"int16: 1000 == 1000", Value{Type: TYPE_INT16, Int16: 1000}, Value{Type: TYPE_INT16, Int16: 1000}, 0},
-		{"int16: 2000 > 1000", Value{Type: TYPE_INT16, Int16: 2000}, Value{Type: TYPE_INT16, Int16: 1000}, 1},
-		{"int16: 500 < 1000", Value{Type: TYPE_INT16, Int16: 500}, Value{Type: TYPE_INT16, Int16: 1000}, -1},
+		{"int16: 1000 == 1000", Int16Value(1000), Int16Value(1000), 0},
+		{"int16: 2000 > 1000", Int16Value(2000), Int16Value(1000), 1},
+		{"int16: 500 < 1000", Int16Value(500), Int16Value(1000), -1},
 
 		// Int32
-		{"int32: 100000 == 100000", Value{Type: TYPE_INT32, Int32: 100000}, Value{Type: TYPE_INT32, Int32: 100000}, 0},
-		{"int32: 200000 > 100000", Value{Type: TYPE_INT32, Int32: 200000}, Value{Type: TYPE_INT32, Int32: 100000}, 1},
-		{"int32: 50000 < 100000", Value{Type: TYPE_INT32, Int32: 50000}, Value{Type: TYPE_INT32, Int32: 100000}, -1},
+		{"int32: 100000 == 100000", Int32Value(100000), Int32Value(100000), 0},
+		{"int32: 200000 > 100000", Int32Value(200000), Int32Value(100000), 1},
+		{"int32: 50000 < 100000", Int32Value(50000), Int32Value(100000), -1},
 
 		// String
-		{"string: equal", Value{Type: TYPE_STRING, String: "hello"}, Value{Type: TYPE_STRING, String: "hello"}, 0},
-		{"string: world > hello", Value{Type: TYPE_STRING, String: "world"}, Value{Type: TYPE_STRING, String: "hello"}, 1},
-		{"string: apple < banana", Value{Type: TYPE_STRING, String: "apple"}, Value{Type: TYPE_STRING, String: "banana"}, -1},
-		{"string: empty < hello", Value{Type: TYPE_STRING, String: ""}, Value{Type: TYPE_STRING, String: "hello"}, -1},
+		{"string: equal", StringValue("hello"), StringValue("hello"), 0},
+		{"string: world > hello", StringValue("world"), StringValue("hello"), 1},
+		{"string: apple < banana", StringValue("apple"), StringValue("banana"), -1},
+		{"string: empty < hello", StringValue(""), StringValue("hello"), -1},
 
 		// Array - Equal
 		{
 			"array: equal",
-			Value{Type: TYPE_ARRAY, Array: []Value{{Type: TYPE_INT8, Int8: 1}, {Type: TYPE_INT8, Int8: 2}}},
-			Value{Type: TYPE_ARRAY, Array: []Value{{Type: TYPE_INT8, Int8: 1}, {Type: TYPE_INT8, Int8: 2}}},
+			ArrayValue([]Value{Int8Value(1), Int8Value(2)}),
+			ArrayValue([]Value{Int8Value(1), Int8Value(2)}),
 			0,
 		},
 		// Array - First element greater
 		{
 			"array: [2,1] > [1,2]",
-			Value{Type: TYPE_ARRAY, Array: []Value{{Type: TYPE_INT8, Int8: 2}, {Type: TYPE_INT8, Int8: 1}}},
-			Value{Type: TYPE_ARRAY, Array: []Value{{Type: TYPE_INT8, Int8: 1}, {Type: TYPE_INT8, Int8: 2}}},
+			ArrayValue([]Value{Int8Value(2), Int8Value(1)}),
+			ArrayValue([]Value{Int8Value(1), Int8Value(2)}),
 			1,
 		},
 		// Array - First element lesser
 		{
 			"array: [1,5] < [2,1]",
-			Value{Type: TYPE_ARRAY, Array: []Value{{Type: TYPE_INT8, Int8: 1}, {Type: TYPE_INT8, Int8: 5}}},
-			Value{Type: TYPE_ARRAY, Array: []Value{{Type: TYPE_INT8, Int8: 2}, {Type: TYPE_INT8, Int8: 1}}},
+			ArrayValue([]Value{Int8Value(1), Int8Value(5)}),
+			ArrayValue([]Value{Int8Value(2), Int8Value(1)}),
 			-1,
 		},
 		// Array - Length comparison
 		{
 			"array: [1] < [1,2]",
-			Value{Type: TYPE_ARRAY, Array: []Value{{Type: TYPE_INT8, Int8: 1}}},
-			Value{Type: TYPE_ARRAY, Array: []Value{{Type: TYPE_INT8, Int8: 1}, {Type: TYPE_INT8, Int8: 2}}},
+			ArrayValue([]Value{Int8Value(1)}),
+			ArrayValue([]Value{Int8Value(1), Int8Value(2)}),
 			-1,
 		},
 		{
 			"array: [1,2,3] > [1,2]",
-			Value{Type: TYPE_ARRAY, Array: []Value{{Type: TYPE_INT8, Int8: 1}, {Type: TYPE_INT8, Int8: 2}, {Type: TYPE_INT8, Int8: 3}}},
-			Value{Type: TYPE_ARRAY, Array: []Value{{Type: TYPE_INT8, Int8: 1}, {Type: TYPE_INT8, Int8: 2}}},
+			ArrayValue([]Value{Int8Value(1), Int8Value(2), Int8Value(3)}),
+			ArrayValue([]Value{Int8Value(1), Int8Value(2)}),
 			1,
 		},
 		{
 			"array: empty arrays",
-			Value{Type: TYPE_ARRAY, Array: []Value{}},
-			Value{Type: TYPE_ARRAY, Array: []Value{}},
+			ArrayValue([]Value{}),
+			ArrayValue([]Value{}),
 			0,
 		},
 	}
@@ -104,10 +165,9 @@ func TestValueCompare_TypeMismatch(t *testing.T) {
 		v1   Value
 		v2   Value
 	}{
-		{"int8 vs string", Value{Type: TYPE_INT8, Int8: 42}, Value{Type: TYPE_STRING, String: "42"}},
-		{"bool vs int8", Value{Type: TYPE_BOOL, Bool: true}, Value{Type: TYPE_INT8, Int8: 1}},
-		{"array vs string", Value{Type: TYPE_ARRAY, Array: []Value{}}, Value{Type: TYPE_STRING, String: "[]"}},
-		{"int8 vs int16", Value{Type: TYPE_INT8, Int8: 42}, Value{Type: TYPE_INT16, Int16: 42}},
+		{"int8 vs string", Int8Value(42), StringValue("42")},
+		{"bool vs int8", BoolValue(true), Int8Value(1)},
+		{"array vs string", ArrayValue([]Value{}), StringValue("[]")},
 	}
 
 	for _, tt := range tests {
@@ -120,20 +180,157 @@ func TestValueCompare_TypeMismatch(t *testing.T) {
 	}
 }
 
+func TestValueCompare_IntegerWidths(t *testing.T) {
+	widths := []struct {
+		name     string
+		unsigned bool
+		value    func(n int) Value
+	}{
+		{"int8", false, func(n int) Value { return Int8Value(int8(n)) }},
+		{"int16", false, func(n int) Value { return Int16Value(int16(n)) }},
+		{"int32", false, func(n int) Value { return Int32Value(int32(n)) }},
+		{"int64", false, func(n int) Value { return Int64Value(int64(n)) }},
+		{"uint8", true, func(n int) Value { return Uint8Value(uint8(n)) }},
+		{"uint16", true, func(n int) Value { return Uint16Value(uint16(n)) }},
+		{"uint32", true, func(n int) Value { return Uint32Value(uint32(n)) }},
+		{"uint64", true, func(n int) Value { return Uint64Value(uint64(n)) }},
+	}
+	// values representable in every width
+	pairs := []struct {
+		a, b     int
+		expected int
+	}{
+		{100, 100, 0},
+		{5, 120, -1},
+		{127, 0, 1},
+		{-5, 5, -1},
+		{127, -128, 1},
+	}
+
+	for _, left := range widths {
+		for _, right := range widths {
+			for _, p := range pairs {
+				if (left.unsigned && p.a < 0) || (right.unsigned && p.b < 0) {
+					continue
+				}
+				name := fmt.Sprintf("%s(%d) vs %s(%d)", left.name, p.a, right.name, p.b)
+				t.Run(name, func(t *testing.T) {
+					result, err := left.value(p.a).Compare(right.value(p.b))
+					if err != nil {
+						t.Fatalf("Compare failed: %v", err)
+					}
+					if result != p.expected {
+						t.Errorf("Expected %d, got %d", p.expected, result)
+					}
+				})
+			}
+		}
+	}
+
+	// values that only fit in the wider type
+	t.Run("int8 vs int16 beyond int8 range", func(t *testing.T) {
+		result, err := Int8Value(100).Compare(Int16Value(300))
+		if err != nil {
+			t.Fatalf("Compare failed: %v", err)
+		}
+		if result != -1 {
+			t.Errorf("Expected -1, got %d", result)
+		}
+	})
+	t.Run("int32 vs int16 beyond int16 range", func(t *testing.T) {
+		result, err := Int32Value(-100000).Compare(Int16Value(-300))
+		if err != nil {
+			t.Fatalf("Compare failed: %v", err)
+		}
+		if result != -1 {
+			t.Errorf("Expected -1, got %d", result)
+		}
+	})
+}
+
+func TestValueCompare_SignedUnsigned(t *testing.T) {
+	tests := []struct {
+		name     string
+		v1       Value
+		v2       Value
+		expected int
+	}{
+		{"uint64 max > int64 max", Uint64Value(math.MaxUint64), Int64Value(math.MaxInt64), 1},
+		{"int64 negative < uint8 zero", Int64Value(-1), Uint8Value(0), -1},
+		{"uint32 zero > int8 negative", Uint32Value(0), Int8Value(-128), 1},
+		{"int64 beyond int32", Int64Value(1 << 40), Int32Value(math.MaxInt32), 1},
+		{"uint64 vs int64 equal", Uint64Value(1 << 62), Int64Value(1 << 62), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.v1.Compare(tt.v2)
+			if err != nil {
+				t.Fatalf("Compare failed: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestValueCompare_Float(t *testing.T) {
+	tests := []struct {
+		name     string
+		v1       Value
+		v2       Value
+		expected int
+	}{
+		{"float: equal", Float64Value(1.5), Float64Value(1.5), 0},
+		{"float: less", Float64Value(-2.5), Float64Value(1.5), -1},
+		{"int8 vs float: fraction above", Int8Value(18), Float64Value(18.5), -1},
+		{"int8 vs float: fraction below", Int8Value(-18), Float64Value(-18.5), 1},
+		{"float vs int16: equal", Float64Value(300), Int16Value(300), 0},
+		{"int64 beyond float precision", Int64Value(1<<53 + 1), Float64Value(1 << 53), 1},
+		{"int64 vs huge float", Int64Value(math.MaxInt64), Float64Value(1e19), -1},
+		{"uint64 vs negative float", Uint64Value(0), Float64Value(-0.5), 1},
+		{"uint64 max vs float", Uint64Value(math.MaxUint64), Float64Value(1e20), -1},
+		{"float vs infinity", Float64Value(math.Inf(1)), Int32Value(math.MaxInt32), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.v1.Compare(tt.v2)
+			if err != nil {
+				t.Fatalf("Compare failed: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestValueCompare_NaN(t *testing.T) {
+	nan := Float64Value(math.NaN())
+	if _, err := nan.Compare(Float64Value(1)); err == nil {
+		t.Error("Expected error comparing NaN with float")
+	}
+	if _, err := Int8Value(1).Compare(nan); err == nil {
+		t.Error("Expected error comparing int with NaN")
+	}
+}
+
 func TestValueCompare_NestedArray(t *testing.T) {
-	v1 := Value{Type: TYPE_ARRAY, Array: []Value{
-		{Type: TYPE_ARRAY, Array: []Value{
-			{Type: TYPE_INT8, Int8: 1},
-			{Type: TYPE_INT8, Int8: 2},
-		}},
-	}}
-
-	v2 := Value{Type: TYPE_ARRAY, Array: []Value{
-		{Type: TYPE_ARRAY, Array: []Value{
-			{Type: TYPE_INT8, Int8: 1},
-			{Type: TYPE_INT8, Int8: 2},
-		}},
-	}}
+	v1 := ArrayValue([]Value{
+		ArrayValue([]Value{
+			Int8Value(1),
+			Int8Value(2),
+		}),
+	})
+
+	v2 := ArrayValue([]Value{
+		ArrayValue([]Value{
+			Int8Value(1),
+			Int8Value(2),
+		}),
+	})
 
 	result, err := v1.Compare(v2)
 	if err != nil {
@@ -145,17 +342,17 @@ func TestValueCompare_NestedArray(t *testing.T) {
 }
 
 func TestValueCompare_ArrayMixedElements(t *testing.T) {
-	v1 := Value{Type: TYPE_ARRAY, Array: []Value{
-		{Type: TYPE_INT8, Int8: 1},
-		{Type: TYPE_INT8, Int8: 2},
-		{Type: TYPE_INT8, Int8: 3},
-	}}
-
-	v2 := Value{Type: TYPE_ARRAY, Array: []Value{
-		{Type: TYPE_INT8, Int8: 1},
-		{Type: TYPE_INT8, Int8: 2},
-		{Type: TYPE_INT8, Int8: 4},
-	}}
+	v1 := ArrayValue([]Value{
+		Int8Value(1),
+		Int8Value(2),
+		Int8Value(3),
+	})
+
+	v2 := ArrayValue([]Value{
+		Int8Value(1),
+		Int8Value(2),
+		Int8Value(4),
+	})
 
 	result, err := v1.Compare(v2)
 	if err != nil {
@@ -208,6 +405,10 @@ func TestDetermineIntType(t *testing.T) {
 		{100000, TYPE_INT32},
 		{-100000, TYPE_INT32},
 		{2147483647, TYPE_INT32},
+		{-2147483648, TYPE_INT32},
+		{2147483648, TYPE_INT64},
+		{1700000000000, TYPE_INT64},
+		{math.MinInt64, TYPE_INT64},
 	}
 
 	for _, tt := range tests {
@@ -228,7 +429,7 @@ func TestInferRuntimeValue(t *testing.T) {
 			name:     "int8",
 			bytecode: []byte{byte(TYPE_INT8), 1, 42},
 			check: func(t *testing.T, v Value) {
-				if v.Type != TYPE_INT8 || v.Int8 != 42 {
+				if v.Type != TYPE_INT8 || v.Int64() != 42 {
 					t.Errorf("Expected int8(42), got %+v", v)
 				}
 			},
@@ -237,7 +438,7 @@ func TestInferRuntimeValue(t *testing.T) {
 			name:     "int16",
 			bytecode: []byte{byte(TYPE_INT16), 2, 0x03, 0xE8}, // 1000
 			check: func(t *testing.T, v Value) {
-				if v.Type != TYPE_INT16 || v.Int16 != 1000 {
+				if v.Type != TYPE_INT16 || v.Int64() != 1000 {
 					t.Errorf("Expected int16(1000), got %+v", v)
 				}
 			},
@@ -246,16 +447,52 @@ func TestInferRuntimeValue(t *testing.T) {
 			name:     "int32",
 			bytecode: []byte{byte(TYPE_INT32), 4, 0x00, 0x01, 0x86, 0xA0}, // 100000
 			check: func(t *testing.T, v Value) {
-				if v.Type != TYPE_INT32 || v.Int32 != 100000 {
+				if v.Type != TYPE_INT32 || v.Int64() != 100000 {
 					t.Errorf("Expected int32(100000), got %+v", v)
 				}
 			},
 		},
+		{
+			name:     "int64",
+			bytecode: []byte{byte(TYPE_INT64), 8, 0x00, 0x00, 0x01, 0x8B, 0xCF, 0xE5, 0x68, 0x00}, // 1700000000000
+			check: func(t *testing.T, v Value) {
+				if v.Type != TYPE_INT64 || v.Int64() != 1700000000000 {
+					t.Errorf("Expected int64(1700000000000), got %+v", v)
+				}
+			},
+		},
+		{
+			name:     "uint8",
+			bytecode: []byte{byte(TYPE_UINT8), 1, 0xFF},
+			check: func(t *testing.T, v Value) {
+				if v.Type != TYPE_UINT8 || v.Uint64() != 255 {
+					t.Errorf("Expected uint8(255), got %+v", v)
+				}
+			},
+		},
+		{
+			name:     "uint64",
+			bytecode: []byte{byte(TYPE_UINT64), 8, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+			check: func(t *testing.T, v Value) {
+				if v.Type != TYPE_UINT64 || v.Uint64() != math.MaxUint64 {
+					t.Errorf("Expected uint64(max), got %+v", v)
+				}
+			},
+		},
+		{
+			name:     "float64",
+			bytecode: []byte{byte(TYPE_FLOAT64), 8, 0x40, 0x33, 0xFD, 0x70, 0xA3, 0xD7, 0x0A, 0x3D}, // 19.99
+			check: func(t *testing.T, v Value) {
+				if v.Type != TYPE_FLOAT64 || v.Float64() != 19.99 {
+					t.Errorf("Expected float64(19.99), got %+v", v)
+				}
+			},
+		},
 		{
 			name:     "string",
 			bytecode: []byte{byte(TYPE_STRING), 5, 'h', 'e', 'l', 'l', 'o'},
 			check: func(t *testing.T, v Value) {
-				if v.Type != TYPE_STRING || v.String != "hello" {
+				if v.Type != TYPE_STRING || v.String() != "hello" {
 					t.Errorf("Expected string(hello), got %+v", v)
 				}
 			},
@@ -264,7 +501,7 @@ func TestInferRuntimeValue(t *testing.T) {
 			name:     "bool true",
 			bytecode: []byte{byte(TYPE_BOOL), 1, 1},
 			check: func(t *testing.T, v Value) {
-				if v.Type != TYPE_BOOL || v.Bool != true {
+				if v.Type != TYPE_BOOL || v.Bool() != true {
 					t.Errorf("Expected bool(true), got %+v", v)
 				}
 			},
@@ -273,7 +510,7 @@ func TestInferRuntimeValue(t *testing.T) {
 			name:     "bool false",
 			bytecode: []byte{byte(TYPE_BOOL), 1, 0},
 			check: func(t *testing.T, v Value) {
-				if v.Type != TYPE_BOOL || v.Bool != false {
+				if v.Type != TYPE_BOOL || v.Bool() != false {
 					t.Errorf("Expected bool(false), got %+v", v)
 				}
 			},
@@ -286,11 +523,11 @@ func TestInferRuntimeValue(t *testing.T) {
 				byte(TYPE_INT8), 1, 2,
 			},
 			check: func(t *testing.T, v Value) {
-				if v.Type != TYPE_ARRAY || len(v.Array) != 2 {
+				if v.Type != TYPE_ARRAY || len(v.Array()) != 2 {
 					t.Errorf("Expected array[2], got %+v", v)
 				}
-				if v.Array[0].Int8 != 1 || v.Array[1].Int8 != 2 {
-					t.Errorf("Expected [1,2], got %+v", v.Array)
+				if v.Array()[0].Int64() != 1 || v.Array()[1].Int64() != 2 {
+					t.Errorf("Expected [1,2], got %+v", v.Array())
 				}
 			},
 		},
@@ -306,14 +543,14 @@ func TestInferRuntimeValue(t *testing.T) {
 				byte(TYPE_INT8), 1, 4,
 			},
 			check: func(t *testing.T, v Value) {
-				if v.Type != TYPE_ARRAY || len(v.Array) != 2 {
+				if v.Type != TYPE_ARRAY || len(v.Array()) != 2 {
 					t.Fatalf("Expected array[2], got %+v", v)
 				}
-				if v.Array[0].Type != TYPE_ARRAY || len(v.Array[0].Array) != 2 {
-					t.Errorf("Expected nested array[2], got %+v", v.Array[0])
+				if v.Array()[0].Type != TYPE_ARRAY || len(v.Array()[0].Array()) != 2 {
+					t.Errorf("Expected nested array[2], got %+v", v.Array()[0])
 				}
-				if v.Array[0].Array[0].Int8 != 1 {
-					t.Errorf("Expected [0][0]=1, got %d", v.Array[0].Array[0].Int8)
+				if v.Array()[0].Array()[0].Int64() != 1 {
+					t.Errorf("Expected [0][0]=1, got %d", v.Array()[0].Array()[0].Int64())
 				}
 			},
 		},
@@ -331,6 +568,31 @@ func TestInferRuntimeValue(t *testing.T) {
 	}
 }
 
+func TestInferRuntimeValue_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		bytecode []byte
+	}{
+		{"empty", []byte{}},
+		{"missing length", []byte{byte(TYPE_STRING)}},
+		{"unterminated varint", []byte{byte(TYPE_STRING), 0x80}},
+		{"string longer than bytecode", []byte{byte(TYPE_STRING), 5, 'a', 'b'}},
+		{"array count longer than bytecode", []byte{byte(TYPE_ARRAY), 0xE8, 0x07, byte(TYPE_BOOL), 1, 1}},
+		{"length above limit", []byte{byte(TYPE_STRING), 0x80, 0x80, 0x80, 0x01}},
+		{"wrong size for int16", []byte{byte(TYPE_INT16), 1, 0x01}},
+		{"unknown type", []byte{0x7F, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := NewVM(tt.bytecode, nil)
+			if _, err := vm.inferRuntimeValue(); err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+		})
+	}
+}
+
 // ============================================================================
 // Conversion Tests
 // ============================================================================
@@ -345,7 +607,7 @@ func TestConvertInterfaceToValue(t *testing.T) {
 			name:  "string",
 			input: "hello",
 			check: func(t *testing.T, v Value) {
-				if v.Type != TYPE_STRING || v.String != "hello" {
+				if v.Type != TYPE_STRING || v.String() != "hello" {
 					t.Errorf("Expected string(hello), got %+v", v)
 				}
 			},
@@ -354,16 +616,70 @@ func TestConvertInterfaceToValue(t *testing.T) {
 			name:  "int",
 			input: 42,
 			check: func(t *testing.T, v Value) {
-				if v.Type != TYPE_INT8 || v.Int8 != 42 {
+				if v.Type != TYPE_INT8 || v.Int64() != 42 {
 					t.Errorf("Expected int8(42), got %+v", v)
 				}
 			},
 		},
+		{
+			name:  "int above 32 bits",
+			input: 1 << 40,
+			check: func(t *testing.T, v Value) {
+				if v.Type != TYPE_INT64 || v.Int64() != 1<<40 {
+					t.Errorf("Expected int64(1<<40), got %+v", v)
+				}
+			},
+		},
+		{
+			name:  "int64",
+			input: int64(5),
+			check: func(t *testing.T, v Value) {
+				if v.Type != TYPE_INT64 || v.Int64() != 5 {
+					t.Errorf("Expected int64(5), got %+v", v)
+				}
+			},
+		},
+		{
+			name:  "uint16",
+			input: uint16(60000),
+			check: func(t *testing.T, v Value) {
+				if v.Type != TYPE_UINT16 || v.Uint64() != 60000 {
+					t.Errorf("Expected uint16(60000), got %+v", v)
+				}
+			},
+		},
+		{
+			name:  "uint",
+			input: uint(42),
+			check: func(t *testing.T, v Value) {
+				if v.Type != TYPE_UINT64 || v.Uint64() != 42 {
+					t.Errorf("Expected uint64(42), got %+v", v)
+				}
+			},
+		},
+		{
+			name:  "float64",
+			input: 19.99,
+			check: func(t *testing.T, v Value) {
+				if v.Type != TYPE_FLOAT64 || v.Float64() != 19.99 {
+					t.Errorf("Expected float64(19.99), got %+v", v)
+				}
+			},
+		},
+		{
+			name:  "float32",
+			input: float32(0.5),
+			check: func(t *testing.T, v Value) {
+				if v.Type != TYPE_FLOAT64 || v.Float64() != 0.5 {
+					t.Errorf("Expected float64(0.5), got %+v", v)
+				}
+			},
+		},
 		{
 			name:  "bool",
 			input: true,
 			check: func(t *testing.T, v Value) {
-				if v.Type != TYPE_BOOL || v.Bool != true {
+				if v.Type != TYPE_BOOL || v.Bool() != true {
 					t.Errorf("Expected bool(true), got %+v", v)
 				}
 			},
@@ -372,7 +688,7 @@ func TestConvertInterfaceToValue(t *testing.T) {
 			name:  "array",
 			input: []interface{}{1, 2, 3},
 			check: func(t *testing.T, v Value) {
-				if v.Type != TYPE_ARRAY || len(v.Array) != 3 {
+				if v.Type != TYPE_ARRAY || len(v.Array()) != 3 {
 					t.Errorf("Expected array[3], got %+v", v)
 				}
 			},
@@ -381,11 +697,11 @@ func TestConvertInterfaceToValue(t *testing.T) {
 			name:  "nested array",
 			input: []interface{}{[]interface{}{1, 2}, []interface{}{3, 4}},
 			check: func(t *testing.T, v Value) {
-				if v.Type != TYPE_ARRAY || len(v.Array) != 2 {
+				if v.Type != TYPE_ARRAY || len(v.Array()) != 2 {
 					t.Errorf("Expected array[2], got %+v", v)
 				}
-				if v.Array[0].Type != TYPE_ARRAY {
-					t.Errorf("Expected nested TYPE_ARRAY, got %v", v.Array[0].Type)
+				if v.Array()[0].Type != TYPE_ARRAY {
+					t.Errorf("Expected nested TYPE_ARRAY, got %v", v.Array()[0].Type)
 				}
 			},
 		},
@@ -0,0 +1,199 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// Verify Tests
+// ============================================================================
+
+// Helper pour concaténer des instructions
+func concat(parts ...[]byte) []byte {
+	var bytecode []byte
+	for _, part := range parts {
+		bytecode = append(bytecode, part...)
+	}
+	return bytecode
+}
+
+func mustPush(t testing.TB, val interface{}) []byte {
+	t.Helper()
+	bytes, err := SerializePush(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes
+}
+
+func TestVerify_Valid(t *testing.T) {
+	tests := []struct {
+		name     string
+		bytecode []byte
+		maxStack int
+	}{
+		{"push bool", mustPush(t, true), 1},
+		{
+			"comparison",
+			concat(SerializeLoadGlobal(0), mustPush(t, 1), SerializeOperator(OP_EQ)),
+			2,
+		},
+		{
+			"short-circuit and",
+			concat(
+				SerializeLoadGlobal(0), mustPush(t, "a"), SerializeOperator(OP_EQ),
+				SerializeJump(JUMP_IF_FALSE_OR_POP, 8),
+				SerializeLoadGlobal(0), mustPush(t, "b"), SerializeOperator(OP_EQ),
+			),
+			2,
+		},
+		{
+			"jump to the end",
+			concat(mustPush(t, true), SerializeJump(JUMP, 4), mustPush(t, false)),
+			1,
+		},
+		{
+			"if / else",
+			concat(
+				mustPush(t, true),
+				SerializeJump(JUMP_IF_FALSE, 9),
+				mustPush(t, 1), SerializeJump(JUMP, 4),
+				mustPush(t, "x"),
+				SerializeOperator(POP), mustPush(t, true),
+			),
+			1,
+		},
+		{
+			"store then load",
+			concat(
+				[]byte{byte(STORE_GLOBAL), 0, 0}, mustPush(t, "x")[1:],
+				SerializeLoadGlobal(0), mustPush(t, "x"), SerializeOperator(OP_EQ),
+			),
+			2,
+		},
+		{
+			"xor of comparisons",
+			concat(
+				SerializeLoadGlobal(0), mustPush(t, 1), SerializeOperator(OP_GT),
+				SerializeLoadGlobal(0), mustPush(t, 5), SerializeOperator(OP_LT),
+				SerializeOperator(OP_XOR), SerializeOperator(OP_NOT),
+			),
+			3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProgram(tt.bytecode, []string{"field"}, 0, nil)
+			if err := p.Verify(); err != nil {
+				t.Fatalf("Verify failed: %v", err)
+			}
+			if !p.Verified() {
+				t.Error("Program should be marked verified")
+			}
+			if p.MaxStack() != tt.maxStack {
+				t.Errorf("MaxStack() = %d, want %d", p.MaxStack(), tt.maxStack)
+			}
+		})
+	}
+}
+
+func TestVerify_Invalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		bytecode    []byte
+		errContains string
+	}{
+		{"empty program", []byte{}, "got 0 values"},
+		{"unknown opcode", []byte{0xEE}, "unknown opcode"},
+		{"truncated push", []byte{byte(PUSH), byte(TYPE_STRING), 10, 'a'}, "truncated"},
+		{"push without value", []byte{byte(PUSH)}, "truncated"},
+		{"bad type tag", []byte{byte(PUSH), 0x42, 1, 0}, "unsupported type"},
+		{"truncated slot", []byte{byte(LOAD_GLOBAL), 0}, "truncated"},
+		{"slot out of bounds", concat(SerializeLoadGlobal(1), mustPush(t, 1), SerializeOperator(OP_EQ)), "out of bounds"},
+		{"native out of bounds", concat(mustPush(t, 1), []byte{byte(CALL_NATIVE), 3, 1}), "native function index"},
+		{"truncated native", []byte{byte(CALL_NATIVE)}, "invalid varint"},
+		{"truncated jump", []byte{byte(JUMP), 0, 0}, "truncated"},
+		{"jump past the end", concat(mustPush(t, true), SerializeJump(JUMP, 1)), "out of bounds"},
+		{"jump inside an instruction", concat(SerializeJump(JUMP, 1), mustPush(t, true)), "not an instruction"},
+		{"backward jump", concat(mustPush(t, true), SerializeJump(JUMP, -9)), "backward jump"},
+		{"stack underflow", []byte{byte(OP_NOT)}, "stack underflow"},
+		{"two values left", concat(mustPush(t, true), mustPush(t, true)), "got 2 values"},
+		{"ends with an integer", mustPush(t, 1), "must end with a boolean"},
+		{"ends with a field", SerializeLoadGlobal(0), "must end with a boolean"},
+		{"logical on integer", concat(mustPush(t, 1), mustPush(t, true), SerializeOperator(OP_AND)), "requires a boolean"},
+		{"jump on integer", concat(mustPush(t, 1), SerializeJump(JUMP_IF_TRUE, 0), mustPush(t, true)), "requires a boolean"},
+		{
+			"inconsistent depth at merge",
+			concat(
+				mustPush(t, true), mustPush(t, true),
+				SerializeJump(JUMP_IF_TRUE, 4),
+				mustPush(t, true),
+				SerializeOperator(OP_AND),
+			),
+			"inconsistent stack depth",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProgram(tt.bytecode, []string{"field"}, 0, nil)
+			err := p.Verify()
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Expected error containing %q, got: %v", tt.errContains, err)
+			}
+			if p.Verified() {
+				t.Error("Program should not be marked verified")
+			}
+		})
+	}
+}
+
+func TestExecute_RefusesUnverified(t *testing.T) {
+	p := NewProgram(mustPush(t, true), nil, 0, nil)
+
+	if err := p.NewVM().Execute(); err == nil {
+		t.Fatal("Expected error executing an unverified program, got nil")
+	}
+
+	if err := p.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	vm := p.NewVM()
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	assertStackValue(t, vm, TYPE_BOOL, func(v Value) bool { return v.Bool() })
+}
+
+// A verified program never panics and ends with one boolean, or fails with
+// a runtime error (undefined field, incompatible types)
+func FuzzVerify(f *testing.F) {
+	f.Add(mustPush(f, true))
+	f.Add(concat(SerializeLoadGlobal(0), mustPush(f, 1), SerializeOperator(OP_EQ)))
+	f.Add(concat(SerializeLoadGlobal(0), mustPush(f, []interface{}{"a", 2}), SerializeOperator(OP_IN)))
+	f.Add(concat(mustPush(f, true), SerializeJump(JUMP_IF_FALSE_OR_POP, 4), mustPush(f, false)))
+	f.Add([]byte{byte(PUSH), byte(TYPE_ARRAY), 0xFF, 0xFF, 0x03})
+
+	f.Fuzz(func(t *testing.T, bytecode []byte) {
+		p := NewProgram(bytecode, []string{"field"}, 0, nil)
+		if p.Verify() != nil {
+			return
+		}
+
+		vm := p.NewVM()
+		if err := vm.LoadRecords(map[string]interface{}{"field": 1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := vm.Execute(); err != nil {
+			return
+		}
+		if len(vm.dataStack) != 1 || vm.dataStack[0].Type != TYPE_BOOL {
+			t.Fatalf("verified program ended with %v", vm.dataStack)
+		}
+	})
+}
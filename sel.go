@@ -2,44 +2,217 @@ package sel
 
 import (
 	"fmt"
+	"io"
+	"sync"
 
 	iast "github.com/Daemon0x00000000/sel/internal/ast"
 	"github.com/Daemon0x00000000/sel/internal/vm"
 )
 
+// Expression is a compiled SEL expression. Once parsed, Eval can be called
+// from many goroutines at the same time: the compiled program is shared and
+// every call runs on its own VM taken from a pool. Parse must not be called
+// concurrently with Eval.
+//
+// Expression implements encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler to store compiled expressions.
 type Expression struct {
-	vm *vm.VM
+	ast     *iast.AST
+	program *vm.Program
+	pool    *sync.Pool
 }
 
+// Explanation is the evaluation trace returned by Explain: one node of the
+// expression with its outcome and its children. String renders the tree.
+type Explanation = iast.Explanation
+
+// Tracer observes the instructions run by Trace, Step is one instruction
+// with the data stack. NewWriterTracer prints them.
+type (
+	Tracer = vm.Tracer
+	Step   = vm.Step
+)
+
+// NewWriterTracer returns a tracer that prints every executed instruction
+// and the stack after it to w.
+func NewWriterTracer(w io.Writer) Tracer {
+	return vm.NewWriterTracer(w)
+}
+
+// Value is the VM representation of a value passed to and returned by
+// native functions. NativeFunc receives the evaluated arguments.
+type (
+	Value      = vm.Value
+	NativeFunc = vm.NativeFunc
+)
+
+// Function is a native function callable by name in expressions, with its
+// accepted argument count and kinds. Functions is a registry of them.
+type (
+	Function  = vm.Function
+	Functions = vm.Functions
+	Kind      = vm.Kind
+)
+
+// Argument and result kinds of a Function
+const (
+	KindAny     = vm.KindAny
+	KindBool    = vm.KindBool
+	KindInteger = vm.KindInteger
+	KindFloat   = vm.KindFloat
+	KindString  = vm.KindString
+	KindArray   = vm.KindArray
+)
+
+// NewFunctions returns an empty function registry.
+func NewFunctions() *Functions {
+	return vm.NewFunctions()
+}
+
+// Builtins returns a new registry holding the standard library: UPPER,
+// LOWER, TRIM, LEN, SUBSTR, REPLACE and SPLIT. Register more functions in it
+// to use them with the standard library.
+func Builtins() *Functions {
+	return vm.Builtins()
+}
+
+// builtins is the registry of Parse and UnmarshalBinary, it is never modified
+var builtins = vm.Builtins()
+
+// ValueOf converts a Go value (bool, integers, floats, string, slices and
+// maps with string keys) to a Value, to build the result of a native
+// function.
+func ValueOf(v interface{}) (Value, error) {
+	return vm.ToValue(v)
+}
+
+// Parse parses and compiles an expression, which may call the standard
+// library functions.
 func (expr *Expression) Parse(expression string) error {
-	ast, err := iast.Parse(expression)
+	return expr.ParseWith(expression, builtins)
+}
+
+// ParseWith parses an expression that may call the functions of funcs, as in
+// LOWER(email)ENDSWITH@corp.com, instead of the standard library. Unknown
+// functions, wrong argument counts and arguments of the wrong kind are
+// reported as parse errors. funcs must not be modified afterwards.
+func (expr *Expression) ParseWith(expression string, funcs *Functions) error {
+	ast, err := iast.ParseWith(expression, funcs)
+	if err != nil {
+		return err
+	}
+	program, err := ast.Compile()
 	if err != nil {
 		return err
 	}
-	bytes, err := ast.Compile()
+
+	expr.load(ast, program)
+	return nil
+}
+
+// MarshalBinary encodes the compiled expression, with a format version, the
+// opcode set version and a checksum, so it can be stored or sent to other
+// processes and loaded with UnmarshalBinary without parsing it again.
+func (expr *Expression) MarshalBinary() ([]byte, error) {
+	if expr.program == nil {
+		return nil, fmt.Errorf("expression not parsed yet")
+	}
+	return expr.program.MarshalBinary()
+}
+
+// UnmarshalBinary loads an expression encoded by MarshalBinary. The bytecode
+// is verified before use. Like Parse, it must not be called concurrently
+// with Eval.
+func (expr *Expression) UnmarshalBinary(data []byte) error {
+	return expr.UnmarshalBinaryWith(data, builtins)
+}
+
+// UnmarshalBinaryWith loads an expression that calls native functions: they
+// are resolved by name in funcs.
+func (expr *Expression) UnmarshalBinaryWith(data []byte, funcs *Functions) error {
+	program, err := vm.UnmarshalProgram(data, funcs)
 	if err != nil {
 		return err
 	}
 
-	// TODO: Get Native Funcs from AST
-	expr.vm = vm.NewVM(bytes, []vm.NativeFunc{})
+	expr.load(nil, program)
 	return nil
 }
 
+// Disassemble returns the compiled bytecode as a human-readable listing, one
+// instruction per line with its offset, mnemonic and decoded operands.
+func (expr *Expression) Disassemble() (string, error) {
+	if expr.program == nil {
+		return "", fmt.Errorf("expression not parsed yet")
+	}
+	return expr.program.Disassemble()
+}
+
+func (expr *Expression) load(ast *iast.AST, program *vm.Program) {
+	expr.ast = ast
+	expr.program = program
+	expr.pool = &sync.Pool{New: func() any { return program.NewVM() }}
+}
+
 func (expr *Expression) Eval(data map[string]interface{}) (bool, error) {
-	if expr.vm == nil {
+	return expr.eval(nil, func(machine *vm.VM) error { return machine.LoadRecords(data) })
+}
+
+// EvalStruct evaluates the expression against a struct or a pointer to a
+// struct, without converting it to a map first. Fields are named by their
+// `sel:"name"` tag, or by their Go name; `sel:"-"` hides a field. Fields of
+// embedded structs are promoted, pointers are followed and a nil pointer
+// leaves the field undefined. Nested structs, slices and maps are reached
+// with paths (customer.address.city, items[0].sku) and quantifiers. The
+// fields of each struct type are looked up once and cached.
+func (expr *Expression) EvalStruct(v interface{}) (bool, error) {
+	return expr.eval(nil, func(machine *vm.VM) error { return machine.LoadStruct(v) })
+}
+
+// Trace evaluates the expression like Eval and calls tracer before and after
+// every instruction of the compiled program.
+func (expr *Expression) Trace(data map[string]interface{}, tracer Tracer) (bool, error) {
+	return expr.eval(tracer, func(machine *vm.VM) error { return machine.LoadRecords(data) })
+}
+
+// eval runs the program on a pooled VM, load sets its globals
+func (expr *Expression) eval(tracer Tracer, load func(*vm.VM) error) (bool, error) {
+	if expr.program == nil {
 		return false, fmt.Errorf("expression not parsed yet")
 	}
-	expr.vm.Reset()
+	machine := expr.pool.Get().(*vm.VM)
+	defer expr.pool.Put(machine)
+	machine.Reset()
+	if tracer != nil {
+		machine.SetTracer(tracer)
+		defer machine.SetTracer(nil)
+	}
 
-	err := expr.vm.LoadRecords(data)
+	err := load(machine)
 	if err != nil {
 		return false, err
 	}
 
-	err = expr.vm.Execute()
-	if err != nil || len(expr.vm.DataStack()) != 1 {
+	err = machine.Execute()
+	if err != nil || len(machine.DataStack()) != 1 {
 		return false, err
 	}
-	return expr.vm.DataStack()[0].Bool, nil
+	return machine.DataStack()[0].Bool(), nil
+}
+
+// Explain evaluates the expression against data like Eval and returns the
+// outcome of every node: the field value, operand and result of each
+// comparison and the result of each logical operator. Branches that AND / OR
+// did not need to evaluate are marked as skipped.
+//
+// It needs the parsed expression: an expression loaded with UnmarshalBinary
+// cannot be explained.
+func (expr *Expression) Explain(data map[string]interface{}) (*Explanation, error) {
+	if expr.program == nil {
+		return nil, fmt.Errorf("expression not parsed yet")
+	}
+	if expr.ast == nil {
+		return nil, fmt.Errorf("expression loaded from binary cannot be explained")
+	}
+	return expr.ast.Explain(data)
 }
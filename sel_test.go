@@ -0,0 +1,745 @@
+package sel
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestExpression_EvalNotParsed(t *testing.T) {
+	expr := &Expression{}
+	if _, err := expr.Eval(map[string]interface{}{}); err == nil {
+		t.Fatal("expected error for unparsed expression")
+	}
+}
+
+func TestExpression_ReParse(t *testing.T) {
+	expr := &Expression{}
+	if err := expr.Parse("a=1"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, err := expr.Eval(map[string]interface{}{"a": 1}); err != nil {
+		t.Fatalf("Eval failed: %v", err)
+	}
+
+	if err := expr.Parse("b=2"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	result, err := expr.Eval(map[string]interface{}{"b": 2})
+	if err != nil {
+		t.Fatalf("Eval failed: %v", err)
+	}
+	if !result {
+		t.Error("expected re-parsed expression to match")
+	}
+}
+
+// Run with -race: many goroutines share one parsed Expression
+func TestExpression_ConcurrentEval(t *testing.T) {
+	expr := &Expression{}
+	if err := expr.Parse("status=active^age>18^ORroleINadmin,root"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	records := []struct {
+		data map[string]interface{}
+		want bool
+	}{
+		{map[string]interface{}{"status": "active", "age": 25, "role": "user"}, true},
+		{map[string]interface{}{"status": "active", "age": 12, "role": "user"}, false},
+		{map[string]interface{}{"status": "closed", "age": 40, "role": "admin"}, true},
+		{map[string]interface{}{"status": "closed", "age": 40, "role": "guest"}, false},
+	}
+
+	const goroutines = 32
+	const iterations = 500
+
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				record := records[(g+i)%len(records)]
+				result, err := expr.Eval(record.data)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if result != record.want {
+					errs <- fmt.Errorf("goroutine %d: Eval(%v) = %v, want %v", g, record.data, result, record.want)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestExpression_ConcurrentEvalErrors(t *testing.T) {
+	expr := &Expression{}
+	if err := expr.Parse("a=1^b=2"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				// missing field fails, a failed run must not poison the next one
+				if _, err := expr.Eval(map[string]interface{}{"a": 1}); err == nil {
+					t.Errorf("goroutine %d: expected error for missing field", g)
+					return
+				}
+				result, err := expr.Eval(map[string]interface{}{"a": 1, "b": 2})
+				if err != nil || !result {
+					t.Errorf("goroutine %d: Eval = %v, %v, want true", g, result, err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func benchmarkRecord() map[string]interface{} {
+	return map[string]interface{}{
+		"status": "active",
+		"age":    25,
+		"name":   "alice",
+		"tags":   []interface{}{"a", "b"},
+		"caller": map[string]interface{}{"department": map[string]interface{}{"name": "IT"}},
+		"items":  []interface{}{map[string]interface{}{"sku": "ABC"}},
+	}
+}
+
+// The hot path must not allocate: the VM, its stack and globals are reused
+func TestExpression_EvalZeroAlloc(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not stable under the race detector")
+	}
+	exprs := []string{
+		"status=active^age>18",
+		"status=active^ORage<10",
+		"!(status=inactive)^XORage>=30",
+		"statusINactive,pending^nameSTARTSWITHal",
+		"caller.department.name=IT^age>18",
+		"items[-1].sku=ABC^age>18",
+		"ANY(items, sku=ABC)^age>18",
+	}
+
+	for _, query := range exprs {
+		t.Run(query, func(t *testing.T) {
+			expr := &Expression{}
+			if err := expr.Parse(query); err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			data := benchmarkRecord()
+
+			allocs := testing.AllocsPerRun(100, func() {
+				if _, err := expr.Eval(data); err != nil {
+					t.Fatalf("Eval failed: %v", err)
+				}
+			})
+			if allocs != 0 {
+				t.Errorf("Eval allocates %.1f times per run, want 0", allocs)
+			}
+		})
+	}
+}
+
+type benchmarkItem struct {
+	SKU string `sel:"sku"`
+}
+
+type benchmarkStruct struct {
+	Status string `sel:"status"`
+	Age    int    `sel:"age"`
+	Name   string `sel:"name"`
+	Caller *struct {
+		Level uint8 `sel:"level"`
+	} `sel:"caller"`
+	Items []benchmarkItem `sel:"items"`
+}
+
+// Scalar fields of a struct are loaded without allocating either
+func TestExpression_EvalStructZeroAlloc(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not stable under the race detector")
+	}
+	expr := &Expression{}
+	if err := expr.Parse("status=active^age>18^nameSTARTSWITHal"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	data := &benchmarkStruct{Status: "active", Age: 25, Name: "alice"}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := expr.EvalStruct(data); err != nil {
+			t.Fatalf("EvalStruct failed: %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("EvalStruct allocates %.1f times per run, want 0", allocs)
+	}
+}
+
+func TestExpression_EvalStruct(t *testing.T) {
+	data := benchmarkStruct{
+		Status: "active",
+		Age:    25,
+		Name:   "alice",
+		Caller: &struct {
+			Level uint8 `sel:"level"`
+		}{Level: 2},
+		Items: []benchmarkItem{{SKU: "ABC"}, {SKU: "DEF"}},
+	}
+	tests := []struct {
+		query    string
+		expected bool
+	}{
+		{"status=active^age>18", true},
+		{"caller.level>=2", true},
+		{"ANY(items, sku=DEF)", true},
+		{"items[*].sku=XYZ", false},
+		{"COUNT(items)=2^items[0].sku=ABC", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			expr := &Expression{}
+			if err := expr.Parse(tt.query); err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			for _, v := range []interface{}{data, &data} {
+				if result, err := expr.EvalStruct(v); err != nil || result != tt.expected {
+					t.Errorf("EvalStruct(%T) = %v, %v, want %v", v, result, err, tt.expected)
+				}
+			}
+		})
+	}
+
+	// un pointeur nil laisse le champ indéfini
+	expr := &Expression{}
+	if err := expr.Parse("caller.level>=2"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, err := expr.EvalStruct(&benchmarkStruct{}); err == nil || !strings.Contains(err.Error(), "caller.level") {
+		t.Errorf("expected undefined field error, got %v", err)
+	}
+	if _, err := expr.EvalStruct(42); err == nil {
+		t.Error("expected error evaluating an int")
+	}
+}
+
+func TestExpression_EvalStructCycle(t *testing.T) {
+	type node struct {
+		Name     string
+		Parent   *node
+		Children []*node
+	}
+	root := &node{Name: "root"}
+	child := &node{Name: "child", Parent: root}
+	root.Children = []*node{child}
+
+	expr := &Expression{}
+	if err := expr.Parse("Parent.Name=root^Parent.Children[0].Name=child"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if result, err := expr.EvalStruct(child); err != nil || !result {
+		t.Errorf("EvalStruct = %v, %v, want true", result, err)
+	}
+
+	// un quantificateur convertit chaque noeud : le cycle est une erreur
+	if err := expr.Parse("ANY(Children, Name=child)"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, err := expr.EvalStruct(root); err == nil || !strings.Contains(err.Error(), "cycle through") {
+		t.Errorf("expected cycle error, got %v", err)
+	}
+}
+
+func BenchmarkExpression_EvalStruct(b *testing.B) {
+	expr := &Expression{}
+	if err := expr.Parse("status=active^age>18"); err != nil {
+		b.Fatalf("Parse failed: %v", err)
+	}
+	data := &benchmarkStruct{Status: "active", Age: 25}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := expr.EvalStruct(data); err != nil {
+			b.Fatalf("EvalStruct failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkExpression_Eval(b *testing.B) {
+	expr := &Expression{}
+	if err := expr.Parse("status=active^age>18"); err != nil {
+		b.Fatalf("Parse failed: %v", err)
+	}
+	data := benchmarkRecord()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := expr.Eval(data); err != nil {
+			b.Fatalf("Eval failed: %v", err)
+		}
+	}
+}
+
+func TestExpression_MarshalBinary(t *testing.T) {
+	source := &Expression{}
+	if err := source.Parse("status=active^age>18^ORroleINadmin,root"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	data, err := source.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+
+	loaded := &Expression{}
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+
+	records := []map[string]interface{}{
+		{"status": "active", "age": 30, "role": "user"},
+		{"status": "active", "age": 12, "role": "user"},
+		{"status": "inactive", "age": 12, "role": "root"},
+		{"status": "inactive", "age": 40, "role": "guest"},
+	}
+	for _, record := range records {
+		want, err := source.Eval(record)
+		if err != nil {
+			t.Fatalf("Eval failed: %v", err)
+		}
+		got, err := loaded.Eval(record)
+		if err != nil {
+			t.Fatalf("Eval on loaded expression failed: %v", err)
+		}
+		if got != want {
+			t.Errorf("Eval(%v) = %v after round trip, want %v", record, got, want)
+		}
+	}
+}
+
+func TestExpression_Functions(t *testing.T) {
+	funcs := Builtins()
+	err := funcs.Register(Function{
+		Name: "HOST", MinArgs: 1, MaxArgs: 1, Params: []Kind{KindString}, Result: KindString,
+		Func: func(args []Value) (Value, error) {
+			_, host, _ := strings.Cut(args[0].String(), "@")
+			return ValueOf(host)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expr := &Expression{}
+	if err := expr.ParseWith("LOWER(HOST(email))=corp.com", funcs); err != nil {
+		t.Fatalf("ParseWith failed: %v", err)
+	}
+	record := map[string]interface{}{"email": "John@Corp.COM"}
+	if result, err := expr.Eval(record); err != nil || !result {
+		t.Errorf("Eval = %v, %v, want true", result, err)
+	}
+	if err := (&Expression{}).ParseWith("HOST(email,name)=x", funcs); err == nil {
+		t.Error("expected arity error")
+	}
+	if err := (&Expression{}).ParseWith("HOST(12)=x", funcs); err == nil {
+		t.Error("expected argument kind error")
+	}
+	if err := (&Expression{}).Parse("HOST(email)=x"); err == nil {
+		t.Error("expected error without registry")
+	}
+	if err := (&Expression{}).ParseWith("LOWER(email)=x", nil); err == nil {
+		t.Error("expected error with an empty registry")
+	}
+
+	data, err := expr.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	if err := (&Expression{}).UnmarshalBinary(data); err == nil {
+		t.Error("expected error loading without registry")
+	}
+	loaded := &Expression{}
+	if err := loaded.UnmarshalBinaryWith(data, funcs); err != nil {
+		t.Fatalf("UnmarshalBinaryWith failed: %v", err)
+	}
+	if result, err := loaded.Eval(record); err != nil || !result {
+		t.Errorf("Eval after round trip = %v, %v, want true", result, err)
+	}
+}
+
+func TestExpression_Builtins(t *testing.T) {
+	record := map[string]interface{}{
+		"email": "  John.Doe@Corp.COM ",
+		"name":  "Zoë Smith",
+		"tags":  "vip,eu",
+		"code":  "FR-75-001",
+		"other": "john.doe@corp.com",
+		"tier":  "vip",
+	}
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"LOWER(TRIM(email))=john.doe@corp.com", true},
+		{"UPPER(name)=ZOË SMITH", true},
+		{"LEN(name)=9", true},
+		{"LEN(TRIM(email))>20", false},
+		{"SUBSTR(code, 3, 2)=75", true},
+		{"SUBSTR(name, 2)=ë Smith", true},
+		{"REPLACE(code, '-', '')=FR75001", true},
+		{"SPLIT(tags, ',')INeu,us", true},
+		{"SPLIT(tags, ',')INus", false},
+		{"tierINSPLIT(tags, ',')", true},
+		{"LOWER(TRIM(email))=LOWER(other)", true},
+		{"TRIM(email)=LOWER(other)", false},
+		{"!UPPER(SUBSTR(code, 0, 2))INSPLIT('FR,BE', ',')", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr := &Expression{}
+			if err := expr.Parse(tt.expr); err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			result, err := expr.Eval(record)
+			if err != nil {
+				t.Fatalf("Eval failed: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Eval(%q) = %v, want %v", tt.expr, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExpression_Arithmetic(t *testing.T) {
+	expr := &Expression{}
+	if err := expr.Parse("price*quantity>1000^end-start<=3600"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	data, err := expr.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	loaded := &Expression{}
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+
+	record := map[string]interface{}{"price": 19.99, "quantity": 60, "start": int64(1700000000), "end": 1700003000}
+	for _, e := range []*Expression{expr, loaded} {
+		if result, err := e.Eval(record); err != nil || !result {
+			t.Errorf("Eval = %v, %v, want true", result, err)
+		}
+	}
+
+	record["quantity"] = 0
+	record["price"] = "free"
+	if _, err := expr.Eval(record); err == nil {
+		t.Error("expected error computing with a non numeric string")
+	}
+}
+
+func TestExpression_Aggregations(t *testing.T) {
+	expr := &Expression{}
+	if err := expr.Parse("COUNT(tags)>3^SUM(line_totals)>=500"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	data, err := expr.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	loaded := &Expression{}
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+
+	record := map[string]interface{}{
+		"tags":        []interface{}{"a", "b", "c", "d"},
+		"line_totals": []interface{}{300, 250.5},
+	}
+	for _, e := range []*Expression{expr, loaded} {
+		if result, err := e.Eval(record); err != nil || !result {
+			t.Errorf("Eval = %v, %v, want true", result, err)
+		}
+	}
+
+	record["line_totals"] = []interface{}{300, "n/a"}
+	if _, err := expr.Eval(record); err == nil {
+		t.Error("expected error summing a non numeric string")
+	}
+}
+
+func TestExpression_NestedFields(t *testing.T) {
+	expr := &Expression{}
+	if err := expr.Parse("caller.department.name=IT^caller.level>=2"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	record := map[string]interface{}{
+		"caller": map[string]interface{}{
+			"department": map[string]interface{}{"name": "IT"},
+			"level":      2,
+		},
+	}
+	if result, err := expr.Eval(record); err != nil || !result {
+		t.Errorf("Eval = %v, %v, want true", result, err)
+	}
+
+	// une clé aplatie à la main reste prioritaire
+	record["caller.department.name"] = "HR"
+	if result, err := expr.Eval(record); err != nil || result {
+		t.Errorf("Eval = %v, %v, want false", result, err)
+	}
+
+	if _, err := expr.Eval(map[string]interface{}{"caller": map[string]interface{}{}}); err == nil {
+		t.Error("expected error for a missing nested field")
+	}
+}
+
+func TestExpression_Indexes(t *testing.T) {
+	expr := &Expression{}
+	if err := expr.Parse("items[*].sku=ABC^items[-1].status=done"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	data, err := expr.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	loaded := &Expression{}
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+
+	record := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"sku": "ABC", "status": "open"},
+			map[string]interface{}{"sku": "DEF", "status": "done"},
+		},
+	}
+	for _, e := range []*Expression{expr, loaded} {
+		if result, err := e.Eval(record); err != nil || !result {
+			t.Errorf("Eval = %v, %v, want true", result, err)
+		}
+	}
+
+	// items[-1] n'est lu que si le premier terme est vrai
+	empty := map[string]interface{}{"items": []interface{}{}}
+	if result, err := expr.Eval(empty); err != nil || result {
+		t.Errorf("Eval = %v, %v, want false", result, err)
+	}
+	last := &Expression{}
+	if err := last.Parse("items[-1].status=done"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, err := last.Eval(empty); err == nil || !strings.Contains(err.Error(), "items[-1]: index -1 out of range") {
+		t.Errorf("expected out of range error, got %v", err)
+	}
+}
+
+func TestExpression_Quantifiers(t *testing.T) {
+	expr := &Expression{}
+	if err := expr.Parse("ANY(items, sku=ABC^UPPER(status)=OPEN)^NONE(items, qty<=0)"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	data, err := expr.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	loaded := &Expression{}
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+
+	record := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"sku": "DEF", "status": "done", "qty": 1},
+			map[string]interface{}{"sku": "ABC", "status": "open", "qty": 2},
+		},
+	}
+	for _, e := range []*Expression{expr, loaded} {
+		if result, err := e.Eval(record); err != nil || !result {
+			t.Errorf("Eval = %v, %v, want true", result, err)
+		}
+	}
+
+	record["items"] = []interface{}{map[string]interface{}{"sku": "ABC", "status": "open", "qty": 0}}
+	if result, err := loaded.Eval(record); err != nil || result {
+		t.Errorf("Eval = %v, %v, want false", result, err)
+	}
+}
+
+func TestExpression_TypedValues(t *testing.T) {
+	type role string
+	record := map[string]interface{}{
+		"roles":  []role{"admin", "ops"},
+		"tags":   []string{"vip", "emea"},
+		"scores": []int{3, 5},
+		"labels": map[string]string{"env": "prod"},
+		"items":  []map[string]int{{"qty": 1}, {"qty": 4}},
+	}
+	tests := []struct {
+		query    string
+		expected bool
+	}{
+		{"tags[*]=vip", true},
+		{"roles[0]=admin", true},
+		{"COUNT(tags)=2^SUM(scores)=8", true},
+		{"labels.env=prod", true},
+		{"ANY(items, qty>3)", true},
+		{"ALL(items, qty>3)", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			expr := &Expression{}
+			if err := expr.Parse(tt.query); err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if result, err := expr.Eval(record); err != nil || result != tt.expected {
+				t.Errorf("Eval = %v, %v, want %v", result, err, tt.expected)
+			}
+			explanation, err := expr.Explain(record)
+			if err != nil || explanation.Result != tt.expected {
+				t.Errorf("Explain = %v, %v, want %v", explanation, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExpression_MarshalBinaryErrors(t *testing.T) {
+	if _, err := (&Expression{}).MarshalBinary(); err == nil {
+		t.Error("expected error marshaling an unparsed expression")
+	}
+	if err := (&Expression{}).UnmarshalBinary([]byte("not bytecode")); err == nil {
+		t.Error("expected error unmarshaling garbage")
+	}
+}
+
+func TestExpression_Disassemble(t *testing.T) {
+	if _, err := (&Expression{}).Disassemble(); err == nil {
+		t.Error("expected error disassembling an unparsed expression")
+	}
+
+	expr := &Expression{}
+	if err := expr.Parse("status=active^age>18"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	listing, err := expr.Disassemble()
+	if err != nil {
+		t.Fatalf("Disassemble failed: %v", err)
+	}
+	expected := `0000  LOAD_GLOBAL "status"
+0003  PUSH STRING "active"
+0012  OP_EQ
+0013  JUMP_IF_FALSE_OR_POP +8  ; -> 0026
+0018  LOAD_GLOBAL "age"
+0021  PUSH INT8 18
+0025  OP_GT
+`
+	if listing != expected {
+		t.Errorf("unexpected listing:\n%s\nwant:\n%s", listing, expected)
+	}
+}
+
+func TestExpression_Explain(t *testing.T) {
+	expr := &Expression{}
+	if err := expr.Parse("status=active^age>18^ORroleINadmin,root"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	record := map[string]interface{}{"status": "inactive", "age": 30, "role": "user"}
+	explanation, err := expr.Explain(record)
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	want, _ := expr.Eval(record)
+	if explanation.Result != want {
+		t.Errorf("Explain result %v, Eval result %v", explanation.Result, want)
+	}
+
+	expected := `└── ^OR → false
+    ├── ^ → false
+    │   ├── status = active → false (status: "inactive")
+    │   └── age > 18 → skipped
+    └── role IN [admin root] → false (role: "user")
+`
+	if explanation.String() != expected {
+		t.Errorf("unexpected explanation:\n%s\nwant:\n%s", explanation, expected)
+	}
+}
+
+func TestExpression_ExplainErrors(t *testing.T) {
+	if _, err := (&Expression{}).Explain(nil); err == nil {
+		t.Error("expected error explaining an unparsed expression")
+	}
+
+	expr := &Expression{}
+	if err := expr.Parse("status=active"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, err := expr.Explain(map[string]interface{}{}); err == nil {
+		t.Error("expected error for a missing field, like Eval")
+	}
+
+	data, err := expr.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	loaded := &Expression{}
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	if _, err := loaded.Explain(map[string]interface{}{"status": "active"}); err == nil {
+		t.Error("expected error explaining an expression loaded from binary")
+	}
+}
+
+func TestExpression_Trace(t *testing.T) {
+	expr := &Expression{}
+	if err := expr.Parse("status=active^age>18"); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	var out strings.Builder
+	result, err := expr.Trace(map[string]interface{}{"status": "active", "age": 12}, NewWriterTracer(&out))
+	if err != nil || result {
+		t.Fatalf("Trace = %v, %v, want false", result, err)
+	}
+	expected := `0000  LOAD_GLOBAL "status"                [STRING "active"]
+0003  PUSH STRING "active"                [STRING "active", STRING "active"]
+0012  OP_EQ                               [BOOL true]
+0013  JUMP_IF_FALSE_OR_POP +8  ; -> 0026  []
+0018  LOAD_GLOBAL "age"                   [INT8 12]
+0021  PUSH INT8 18                        [INT8 12, INT8 18]
+0025  OP_GT                               [BOOL false]
+`
+	if out.String() != expected {
+		t.Errorf("unexpected trace:\n%s\nwant:\n%s", out.String(), expected)
+	}
+
+	// le tracer n'est pas conservé par la VM remise dans le pool
+	out.Reset()
+	if _, err := expr.Eval(map[string]interface{}{"status": "active", "age": 30}); err != nil {
+		t.Fatalf("Eval failed: %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Eval after Trace should not trace, got:\n%s", out.String())
+	}
+}